@@ -17,7 +17,9 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -36,6 +38,310 @@ const (
 	DeploymentTypeMicroservices DeploymentType = "MicroservicesDeployment"
 )
 
+// KbsConfigPhase string is the overall state of the KBS deployment
+// +enum
+type KbsConfigPhase string
+
+const (
+	// KbsConfigPhasePending: the KBS deployment hasn't been created yet
+	KbsConfigPhasePending KbsConfigPhase = "Pending"
+
+	// KbsConfigPhaseDeploying: the KBS deployment is being rolled out
+	KbsConfigPhaseDeploying KbsConfigPhase = "Deploying"
+
+	// KbsConfigPhaseReady: all the KBS replicas are up to date and ready
+	KbsConfigPhaseReady KbsConfigPhase = "Ready"
+
+	// KbsConfigPhaseFailed: the last reconciliation of the KBS resources failed
+	KbsConfigPhaseFailed KbsConfigPhase = "Failed"
+)
+
+// RefValuesSchemaVersion string is the version of the RVPS reference values format
+// +enum
+type RefValuesSchemaVersion string
+
+const (
+	// RefValuesSchemaVersionV1: list of reference values with name, expiration and hash values
+	RefValuesSchemaVersionV1 RefValuesSchemaVersion = "v1"
+)
+
+// AsTokenFormat string is the format of the attestation tokens issued by the attestation service
+// +enum
+type AsTokenFormat string
+
+const (
+	// AsTokenFormatJWT: JSON Web Token signed by the attestation service
+	AsTokenFormatJWT AsTokenFormat = "JWT"
+
+	// AsTokenFormatEAR: Entity Attestation Result, carrying an attestation result in the EAR format
+	AsTokenFormatEAR AsTokenFormat = "EAR"
+)
+
+// AuditLogSink string is the destination of the KBS attestation audit log
+// +enum
+type AuditLogSink string
+
+const (
+	// AuditLogSinkStdout: structured records written to the standard output of the KBS container
+	AuditLogSinkStdout AuditLogSink = "Stdout"
+
+	// AuditLogSinkFile: records appended to a file stored on a persistent volume
+	AuditLogSinkFile AuditLogSink = "File"
+
+	// AuditLogSinkRemote: records sent to a remote HTTP(S) endpoint
+	AuditLogSinkRemote AuditLogSink = "Remote"
+)
+
+// KbsStorage is the template of the PersistentVolumeClaim created by the operator for the KBS storage
+type KbsStorage struct {
+	// Size is the requested capacity of the volume, it can only be increased
+	// if the storage class allows the volume expansion
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName is the storage class of the volume, defaulted to the cluster default one
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes are the access modes of the volume. They're immutable and defaulted to ReadWriteOnce
+	// for a single KBS replica, ReadWriteMany otherwise. ReadWriteMany is required to scale KBS to more
+	// than one replica, hence it must be set explicitly if KBS is scaled out later
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+// KbsAuditLog defines where the KBS attestation decisions are recorded
+type KbsAuditLog struct {
+	// Sink is the destination of the audit log, it defaults to Stdout
+	// +kubebuilder:validation:Enum=Stdout;File;Remote
+	Sink AuditLogSink `json:"sink,omitempty"`
+
+	// PersistentVolumeClaimName is the claim of the volume storing the audit log
+	// It's required by the File sink
+	PersistentVolumeClaimName string `json:"persistentVolumeClaimName,omitempty"`
+
+	// Endpoint is the HTTP(S) URL the audit records are sent to
+	// It's required by the Remote sink
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// SecretStoreType string is the type of the external store KBS fetches the resources from
+// +enum
+type SecretStoreType string
+
+const (
+	// SecretStoreTypeVault: HashiCorp Vault KV secrets engine, authenticated by a token
+	SecretStoreTypeVault SecretStoreType = "Vault"
+
+	// SecretStoreTypeAliyunKms: Alibaba Cloud KMS instance, authenticated by a client key
+	SecretStoreTypeAliyunKms SecretStoreType = "AliyunKms"
+)
+
+// KbsExternalSecretStore defines the external store KBS fetches the resources from at runtime
+type KbsExternalSecretStore struct {
+	// Type is the type of the external store
+	// +kubebuilder:validation:Enum=Vault;AliyunKms
+	Type SecretStoreType `json:"type"`
+
+	// Endpoint is the HTTPS URL of the store (e.g. https://vault.example.com:8200)
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Path is the mount path of the Vault KV secrets engine, it defaults to "secret"
+	// It's only accepted by the Vault store
+	Path string `json:"path,omitempty"`
+
+	// InstanceId is the id of the KMS instance, it's required by the AliyunKms store
+	InstanceId string `json:"instanceId,omitempty"`
+
+	// CredentialsSecretName is the name of the secret holding the store credentials:
+	// the "token" key for the Vault store, the "client-key", "password" and "ca.pem" keys for the AliyunKms store
+	// +kubebuilder:validation:MinLength=1
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// KbsGitResources defines a Git repository the KBS resources are synced from
+type KbsGitResources struct {
+	// Repository is the URL of the Git repository, either HTTPS (https://host/org/repo.git)
+	// or SSH (ssh://git@host/org/repo.git or git@host:org/repo.git)
+	// The repository holds the resources in the <repository>/<type>/<tag> layout
+	Repository string `json:"repository"`
+
+	// Ref is the branch, tag or commit synced, it defaults to main
+	Ref string `json:"ref,omitempty"`
+
+	// Interval is the time (e.g. "5m") between two syncs of the repository, at least 10s
+	// If not provided, the repository is synced every minute
+	Interval string `json:"interval,omitempty"`
+
+	// CredentialsSecretName is the name of the secret holding the credentials of a private repository:
+	// the username and password keys for an HTTPS repository, the ssh-privatekey and known_hosts keys
+	// for an SSH repository
+	CredentialsSecretName string `json:"credentialsSecretName,omitempty"`
+}
+
+// KbsMaintenanceWindow is a recurring time window during which the KBS rollouts are applied
+type KbsMaintenanceWindow struct {
+	// Days are the days of the week the window starts on (Mon, Tue, Wed, Thu, Fri, Sat, Sun)
+	// If not provided, the window starts every day
+	// +kubebuilder:validation:items:Enum=Mon;Tue;Wed;Thu;Fri;Sat;Sun
+	Days []string `json:"days,omitempty"`
+
+	// Start is the UTC time the window starts at, in the HH:MM format
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// Duration is the length of the window (e.g. "2h"), at most 24h
+	Duration string `json:"duration"`
+}
+
+// KbsAutoscaling configures the horizontal autoscaling of the KBS pods
+type KbsAutoscaling struct {
+	// MinReplicas is the minimum number of KBS pods, it defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the maximum number of KBS pods
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetCPUUtilizationPercentage is the average CPU utilization of the KBS pods, relative to their
+	// CPU requests, targeted by the autoscaler. It defaults to 80
+	// +kubebuilder:validation:Minimum=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+}
+
+// CertManagerIssuerReference references the cert-manager issuer signing a certificate
+type CertManagerIssuerReference struct {
+	// Name is the name of the issuer
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer, Issuer or ClusterIssuer. It defaults to Issuer
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+
+	// Group is the API group of the issuer. It defaults to cert-manager.io
+	Group string `json:"group,omitempty"`
+}
+
+// KbsHttpsCertManager requests the KBS https certificate from cert-manager
+type KbsHttpsCertManager struct {
+	// IssuerRef is the issuer signing the KBS https certificate
+	IssuerRef CertManagerIssuerReference `json:"issuerRef"`
+}
+
+// KbsIngress exposes the KBS service outside of the cluster through an Ingress
+type KbsIngress struct {
+	// Host is the host name routed to the KBS service
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// IngressClassName is the class of the Ingress. If not provided, the default class of the cluster is used
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// TLSSecretName is the name of the secret holding the TLS certificate of the host
+	// If not provided, the Ingress doesn't terminate TLS
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// Annotations are set on the Ingress, e.g. to configure the ingress controller
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// KbsPort is an additional named port of the KBS container, exposed by the KBS service as well
+type KbsPort struct {
+	// Name is the name of the port in the container and in the service, an IANA service name
+	// of at most 15 lowercase alphanumeric characters or '-'
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// ContainerPort is the port KBS listens on, as set in the KBS configuration
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// Port is the port exposed by the KBS service. It defaults to ContainerPort
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+}
+
+// KbsMetrics configures the scraping of the KBS metrics by Prometheus
+type KbsMetrics struct {
+	// Enabled turns on the KBS metrics endpoint and creates a ServiceMonitor for the Prometheus operator
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Port is the port of the KBS metrics endpoint. It defaults to KbsMetricsPort, if set, or 9090
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// Path is the HTTP path of the metrics scraped by Prometheus. It defaults to /metrics
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path,omitempty"`
+}
+
+// KbsServiceEndpoint defines an additional service exposing the KBS pods
+type KbsServiceEndpoint struct {
+	// NameSuffix is appended to the KBS service name to build the name of the service (<KbsConfig name>-kbs-service-<nameSuffix>)
+	// +kubebuilder:validation:MinLength=1
+	NameSuffix string `json:"nameSuffix"`
+
+	// Type is the type of the service, it defaults to ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Ports is the list of ports exposed by the service, it defaults to the KBS port
+	Ports []corev1.ServicePort `json:"ports,omitempty"`
+}
+
+// KbsResource is a resource served by KBS at kbs:///<repository>/<type>/<tag>
+type KbsResource struct {
+	// Repository is the repository of the resource, it defaults to "default"
+	Repository string `json:"repository,omitempty"`
+
+	// Type is the type of the resource
+	// +kubebuilder:validation:MinLength=1
+	Type string `json:"type"`
+
+	// Tag is the tag of the resource
+	// +kubebuilder:validation:MinLength=1
+	Tag string `json:"tag"`
+
+	// Value is the content of the resource
+	// Either Value or SecretKeyRef must be provided
+	Value string `json:"value,omitempty"`
+
+	// SecretKeyRef references the key of a secret holding the content of the resource
+	// Either Value or SecretKeyRef must be provided
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// KbsSecretResourceMount mounts the keys of a secret as KBS resources
+type KbsSecretResourceMount struct {
+	// SecretName is the name of the secret
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// SubPath is the directory where the secret is mounted, relative to KbsSecretResourcesMountPath
+	// (e.g. the resource type in the default repository). It defaults to the secret name
+	SubPath string `json:"subPath,omitempty"`
+
+	// Items maps the keys of the secret to relative file paths (e.g. the resource tags)
+	// If not provided, every key of the secret is mounted as a file named after the key
+	Items []corev1.KeyToPath `json:"items,omitempty"`
+}
+
+// KbsResourcePolicyRule grants the access to the KBS resources matching a path
+type KbsResourcePolicyRule struct {
+	// Path of the resources, in the <repository>/<type>/<tag> form
+	// Every segment can be "*" to match any value (e.g. "default/key/*")
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+
+	// Tees restricts the rule to the clients attested by the given TEE types (e.g. "snp", "tdx")
+	// If not provided, the clients attested by any TEE type are granted the access
+	Tees []string `json:"tees,omitempty"`
+}
+
 // KbsConfigSpec defines the desired state of KbsConfig
 type KbsConfigSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -44,35 +350,384 @@ type KbsConfigSpec struct {
 	// KbsConfigMapName is the name of the configmap that contains the KBS configuration
 	KbsConfigMapName string `json:"kbsConfigMapName,omitempty"`
 
+	// KbsConfigMountPath is the directory where the KBS configuration is mounted in the KBS container,
+	// for KBS images expecting it in another location. Defaults to /etc/kbs-config
+	KbsConfigMountPath string `json:"kbsConfigMountPath,omitempty"`
+
 	// KbsAsConfigMapName is the name of the configmap that contains the KBS AS configuration
 	KbsAsConfigMapName string `json:"kbsAsConfigMapName,omitempty"`
 
+	// KbsAsConfigMountPath is the directory where the AS configuration is mounted in the AS container,
+	// for AS images expecting it in another location. Defaults to /etc/as-config
+	KbsAsConfigMountPath string `json:"kbsAsConfigMountPath,omitempty"`
+
+	// KbsAsSocket is the address the AS container listens on, as host:port. Defaults to 0.0.0.0:50004
+	// KBS is pointed at the AS port, which must not collide with the other ports of the KBS pods
+	KbsAsSocket string `json:"kbsAsSocket,omitempty"`
+
 	// KbsRvpsConfigMapName is the name of the configmap that contains the KBS RVPS configuration
 	KbsRvpsConfigMapName string `json:"kbsRvpsConfigMapName,omitempty"`
 
+	// KbsRvpsAddress is the IP address the RVPS container listens on. Defaults to 0.0.0.0
+	KbsRvpsAddress string `json:"kbsRvpsAddress,omitempty"`
+
+	// KbsRvpsPort is the port the RVPS container listens on. Defaults to 50003
+	// The AS is pointed at the RVPS port, which must not collide with the other ports of the KBS pods
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	KbsRvpsPort int32 `json:"kbsRvpsPort,omitempty"`
+
 	// kbsRvpsRefValuesConfigMapName is the name of the configmap that contains the RVPS reference values
 	KbsRvpsRefValuesConfigMapName string `json:"kbsRvpsRefValuesConfigMapName,omitempty"`
 
 	// KbsAuthSecretName is the name of the secret that contains the KBS auth secret
 	KbsAuthSecretName string `json:"kbsAuthSecretName,omitempty"`
 
+	// KbsAuthSecretNames is an array of additional secret names holding admin public keys
+	// Every key of the secrets is a public key trusted by KBS, along with the KbsAuthSecretName one
+	KbsAuthSecretNames []string `json:"kbsAuthSecretNames,omitempty"`
+
 	// KbsServiceType is the type of service to create for KBS
 	KbsServiceType corev1.ServiceType `json:"kbsServiceType,omitempty"`
 
+	// KbsNodePort is the node port of the KBS service when KbsServiceType is NodePort
+	// When not provided, the node port is assigned by the cluster
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	KbsNodePort int32 `json:"kbsNodePort,omitempty"`
+
+	// KbsServiceAnnotations are set on the KBS service when KbsServiceType is LoadBalancer,
+	// e.g. to request an internal load balancer from the cloud provider
+	KbsServiceAnnotations map[string]string `json:"kbsServiceAnnotations,omitempty"`
+
+	// KbsLoadBalancerSourceRanges restricts the client IP ranges allowed by the load balancer
+	// when KbsServiceType is LoadBalancer. If not provided, all the clients are allowed
+	KbsLoadBalancerSourceRanges []string `json:"kbsLoadBalancerSourceRanges,omitempty"`
+
+	// KbsIngress exposes the KBS service outside of the cluster through an Ingress
+	// If not provided, no Ingress is created
+	KbsIngress *KbsIngress `json:"kbsIngress,omitempty"`
+
 	// KbsDeploymentType is the type of KBS deployment
 	// It can assume one of the following values:
 	//    AllInOneDeployment: all the KBS components will be deployed in the same container
 	//    MicroservicesDeployment: all the KBS components will be deployed in separate containers
 	KbsDeploymentType DeploymentType `json:"kbsDeploymentType,omitempty"`
 
+	// KbsExternalAsAddress is the URL of an Attestation Service running outside of the KBS pods
+	// (e.g. "http://as.trustee.svc:50004"). When provided with the microservices deployment,
+	// neither the AS nor the RVPS containers are deployed and KBS is pointed at this address.
+	// The settings of the AS of the KBS pods (e.g. KbsAttestationPolicy) don't apply then
+	KbsExternalAsAddress string `json:"kbsExternalAsAddress,omitempty"`
+
+	// KbsExternalRvpsAddress is the URL of an RVPS running outside of the KBS pods
+	// (e.g. "http://rvps.trustee.svc:50003"). When provided with the microservices deployment,
+	// the RVPS container is not deployed and the AS is pointed at this address
+	KbsExternalRvpsAddress string `json:"kbsExternalRvpsAddress,omitempty"`
+
+	// KbsReplicas is the number of KBS pods. If not provided, a single pod is deployed
+	// The replicas are spread across the nodes when possible
+	// +kubebuilder:validation:Minimum=0
+	KbsReplicas *int32 `json:"kbsReplicas,omitempty"`
+
+	// KbsAutoscaling enables the horizontal autoscaling of the KBS pods on their CPU utilization
+	// It can't be combined with KbsReplicas, the number of pods being managed by the autoscaler
+	KbsAutoscaling *KbsAutoscaling `json:"kbsAutoscaling,omitempty"`
+
+	// KbsDeploymentStrategy is the strategy replacing the KBS pods on updates, Recreate or RollingUpdate
+	// If not provided, the pods are rolled out one at a time (RollingUpdate with maxUnavailable 1)
+	KbsDeploymentStrategy *appsv1.DeploymentStrategy `json:"kbsDeploymentStrategy,omitempty"`
+
 	// KbsHttpsKeySecretName is the name of the secret that contains the KBS https private key
 	KbsHttpsKeySecretName string `json:"kbsHttpsKeySecretName,omitempty"`
 
 	// KbsHttpsCertSecretName is the name of the secret that contains the KBS https certificate
 	KbsHttpsCertSecretName string `json:"kbsHttpsCertSecretName,omitempty"`
 
+	// KbsHttpsCertManager makes cert-manager issue the KBS https certificate, for the KbsCertificateDnsNames
+	// and KbsCertificateIpAddresses SANs, instead of providing KbsHttpsKeySecretName and KbsHttpsCertSecretName
+	// The Certificate is only created when the cert-manager CRDs are installed
+	KbsHttpsCertManager *KbsHttpsCertManager `json:"kbsHttpsCertManager,omitempty"`
+
 	// KbsSecretResources is an array of secret names that contain the keys required by clients
 	KbsSecretResources []string `json:"kbsSecretResources,omitempty"`
+
+	// KbsSecretResourceMounts mounts secrets as KBS resources like KbsSecretResources, with an optional
+	// sub-path and key-to-path mapping for each secret
+	KbsSecretResourceMounts []KbsSecretResourceMount `json:"kbsSecretResourceMounts,omitempty"`
+
+	// KbsSecretResourcesMountPath is the directory where the secret resources are mounted in the KBS container,
+	// for KBS images serving the resources from another location.
+	// Defaults to /opt/confidential-containers/kbs/repository/default
+	KbsSecretResourcesMountPath string `json:"kbsSecretResourcesMountPath,omitempty"`
+
+	// KbsSecretResourcesSelector selects the secrets of the namespace to be mounted as KBS secret resources,
+	// along with the ones listed in KbsSecretResources. The secrets added or removed from the selection
+	// are mounted or unmounted
+	KbsSecretResourcesSelector *metav1.LabelSelector `json:"kbsSecretResourcesSelector,omitempty"`
+
+	// KbsExternalSecretStore configures KBS to fetch the resources from an external store (Vault, KMS)
+	// instead of the secrets mounted in the KBS pods. It's exclusive to KbsSecretResources,
+	// KbsSecretResourcesSelector, KbsSecretResourceMounts and KbsResources, which are served by the local repository
+	KbsExternalSecretStore *KbsExternalSecretStore `json:"kbsExternalSecretStore,omitempty"`
+
+	// KbsGitResources configures KBS to serve the resources synced from a Git repository by a sidecar
+	// of the KBS pods. It's exclusive to KbsSecretResources, KbsSecretResourcesSelector, KbsSecretResourceMounts,
+	// KbsResources and KbsExternalSecretStore
+	KbsGitResources *KbsGitResources `json:"kbsGitResources,omitempty"`
+
+	// KbsImageName is the KBS container image, referenced either by tag or by digest (name@sha256:...)
+	// It overrides the KBS_IMAGE_NAME environment variable of the operator
+	KbsImageName string `json:"kbsImageName,omitempty"`
+
+	// KbsAsImageName is the AS container image, referenced either by tag or by digest (name@sha256:...)
+	// It overrides the AS_IMAGE_NAME environment variable of the operator
+	KbsAsImageName string `json:"kbsAsImageName,omitempty"`
+
+	// KbsRvpsImageName is the RVPS container image, referenced either by tag or by digest (name@sha256:...)
+	// It overrides the RVPS_IMAGE_NAME environment variable of the operator
+	KbsRvpsImageName string `json:"kbsRvpsImageName,omitempty"`
+
+	// KbsCommand overrides the command of the KBS container, for KBS images with another entrypoint
+	// If not provided, KBS is started with the configuration file mounted by the operator
+	KbsCommand []string `json:"kbsCommand,omitempty"`
+
+	// KbsArgs are the arguments of the KBS container command
+	KbsArgs []string `json:"kbsArgs,omitempty"`
+
+	// KbsAsCommand overrides the command of the AS container, for AS images with another entrypoint
+	// If not provided, the AS is started with the configuration file mounted by the operator
+	KbsAsCommand []string `json:"kbsAsCommand,omitempty"`
+
+	// KbsAsArgs are the arguments of the AS container command
+	KbsAsArgs []string `json:"kbsAsArgs,omitempty"`
+
+	// KbsRvpsCommand overrides the command of the RVPS container, for RVPS images with another entrypoint
+	// If not provided, the RVPS is started with the configuration file mounted by the operator
+	KbsRvpsCommand []string `json:"kbsRvpsCommand,omitempty"`
+
+	// KbsRvpsArgs are the arguments of the RVPS container command
+	KbsRvpsArgs []string `json:"kbsRvpsArgs,omitempty"`
+
+	// KbsImagePullSecrets are the secrets, in the KbsConfig namespace, used for pulling the images
+	// of the KBS pods from private registries
+	KbsImagePullSecrets []corev1.LocalObjectReference `json:"kbsImagePullSecrets,omitempty"`
+
+	// KbsImagePullPolicy is the pull policy of the images of the KBS pods, it defaults to IfNotPresent
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	KbsImagePullPolicy corev1.PullPolicy `json:"kbsImagePullPolicy,omitempty"`
+
+	// KbsCertificateDnsNames is the list of DNS names (SANs) of the generated KBS https certificate,
+	// e.g. the one issued by cert-manager
+	// If neither DNS names nor IP addresses are provided, the KBS service DNS names are used
+	KbsCertificateDnsNames []string `json:"kbsCertificateDnsNames,omitempty"`
+
+	// KbsCertificateIpAddresses is the list of IP addresses (SANs) of the generated KBS https certificate
+	KbsCertificateIpAddresses []string `json:"kbsCertificateIpAddresses,omitempty"`
+
+	// KbsBindAddress is the IP address of the interface the KBS HTTP server binds to, on the KBS port
+	// (e.g. in hostNetwork or multi-NIC scenarios). If not provided, KBS binds to all the interfaces
+	KbsBindAddress string `json:"kbsBindAddress,omitempty"`
+
+	// KbsMetricsPort enables the KBS metrics endpoint (Prometheus/OpenMetrics format) on the given port
+	// The metrics are served at /metrics and exposed by the KBS service
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	KbsMetricsPort int32 `json:"kbsMetricsPort,omitempty"`
+
+	// KbsMetrics enables the scraping of the KBS metrics by Prometheus through a ServiceMonitor
+	// The ServiceMonitor is only created when the Prometheus operator CRDs are installed
+	KbsMetrics *KbsMetrics `json:"kbsMetrics,omitempty"`
+
+	// KbsPorts are additional named ports of the KBS container, exposed by the KBS service as well
+	// (e.g. a separate HTTP API). KBS must be configured to listen on them
+	KbsPorts []KbsPort `json:"kbsPorts,omitempty"`
+
+	// KbsAttestationPolicy is the attestation policy (rego) evaluated by the attestation service
+	// The operator stores it in a ConfigMap which is mounted as the default AS policy
+	KbsAttestationPolicy string `json:"kbsAttestationPolicy,omitempty"`
+
+	// KbsResourcePolicyRules is the list of rules granting the access to the KBS resources
+	// The operator renders them into the KBS resource policy (rego): a resource is released
+	// when at least one rule matches its path, otherwise the access is denied
+	KbsResourcePolicyRules []KbsResourcePolicyRule `json:"kbsResourcePolicyRules,omitempty"`
+
+	// KbsPolicyConfigMapName is the name of a ConfigMap holding the KBS resource policy (rego) in the
+	// policy.rego key, for policies that can't be expressed with KbsResourcePolicyRules
+	// It's mounted as the KBS resource policy and is mutually exclusive with KbsResourcePolicyRules
+	KbsPolicyConfigMapName string `json:"kbsPolicyConfigMapName,omitempty"`
+
+	// KbsServiceEndpoints is a list of additional services exposing the KBS pods
+	// (e.g. a LoadBalancer for the clients outside the cluster, in addition to the ClusterIP KBS service)
+	KbsServiceEndpoints []KbsServiceEndpoint `json:"kbsServiceEndpoints,omitempty"`
+
+	// KbsRvpsRefValuesSchemaVersion enables the validation of the RVPS reference values
+	// against the schema of the given version before they're mounted
+	// If not provided, the reference values are not validated
+	// +kubebuilder:validation:Enum=v1
+	KbsRvpsRefValuesSchemaVersion RefValuesSchemaVersion `json:"kbsRvpsRefValuesSchemaVersion,omitempty"`
+
+	// KbsWorkerThreads is the number of worker threads of the async runtime of the trustee components
+	// If not provided, it's derived from the CPU limit of each container (when set)
+	// +kubebuilder:validation:Minimum=1
+	KbsWorkerThreads int32 `json:"kbsWorkerThreads,omitempty"`
+
+	// KbsLogLevel is the log level (RUST_LOG) of the trustee components, it defaults to info
+	// +kubebuilder:validation:Enum=error;warn;info;debug;trace
+	KbsLogLevel string `json:"kbsLogLevel,omitempty"`
+
+	// KbsEnvVars are environment variables (e.g. proxy settings) set in every trustee container,
+	// overriding the ones set by the operator. Their values can be taken from ConfigMaps and Secrets
+	KbsEnvVars []corev1.EnvVar `json:"kbsEnvVars,omitempty"`
+
+	// KbsInitContainers are init containers run before the trustee containers, e.g. to seed the KBS resources
+	// The KBS storage is mounted in them at the same paths as in the KBS container
+	KbsInitContainers []corev1.Container `json:"kbsInitContainers,omitempty"`
+
+	// KbsExtraVolumes are additional volumes (e.g. host paths, CSI volumes or projected tokens)
+	// of the KBS pods, not modeled by the operator
+	KbsExtraVolumes []corev1.Volume `json:"kbsExtraVolumes,omitempty"`
+
+	// KbsExtraVolumeMounts are additional volume mounts of the KBS container,
+	// each of them referencing one of the KbsExtraVolumes
+	KbsExtraVolumeMounts []corev1.VolumeMount `json:"kbsExtraVolumeMounts,omitempty"`
+
+	// KbsContainerResources are the resources of the KBS container, replacing the default ones
+	// In the DeploymentTypeAllInOne case, the KBS container also runs the AS and the RVPS
+	KbsContainerResources *corev1.ResourceRequirements `json:"kbsContainerResources,omitempty"`
+
+	// KbsAsContainerResources are the resources of the AS container, replacing the default ones
+	// They only apply to the DeploymentTypeMicroservices case
+	KbsAsContainerResources *corev1.ResourceRequirements `json:"kbsAsContainerResources,omitempty"`
+
+	// KbsRvpsContainerResources are the resources of the RVPS container, replacing the default ones
+	// They only apply to the DeploymentTypeMicroservices case
+	KbsRvpsContainerResources *corev1.ResourceRequirements `json:"kbsRvpsContainerResources,omitempty"`
+
+	// KbsRuntimeClassName is the name of the RuntimeClass used to run the KBS pods (e.g. kata)
+	// If not provided, the cluster default runtime is used
+	// The pod overhead defined by the RuntimeClass is added to the pods at admission, on top of the container resources
+	KbsRuntimeClassName *string `json:"kbsRuntimeClassName,omitempty"`
+
+	// KbsResources is a list of resources to be served by KBS
+	// The operator stores them in secrets mounted in the KBS resource repository,
+	// rather than registering them through the KBS admin API
+	KbsResources []KbsResource `json:"kbsResources,omitempty"`
+
+	// KbsAsTokenFormat is the format of the attestation tokens issued by the attestation service
+	// It can assume one of the following values:
+	//    JWT: JSON Web Token
+	//    EAR: Entity Attestation Result
+	// If not provided, the AS built-in format is used
+	// +kubebuilder:validation:Enum=JWT;EAR
+	KbsAsTokenFormat AsTokenFormat `json:"kbsAsTokenFormat,omitempty"`
+
+	// KbsChallengeTtl is the time (e.g. "5m") a client has to complete the attestation
+	// after receiving the challenge, in whole minutes and at most 1h to limit the replay window
+	// If not provided, the KBS built-in value is used
+	KbsChallengeTtl string `json:"kbsChallengeTtl,omitempty"`
+
+	// KbsHostAliases is a list of hostname to IP mappings added to the hosts file of the KBS pods
+	// (e.g. for resolving external endpoints in environments without DNS)
+	KbsHostAliases []corev1.HostAlias `json:"kbsHostAliases,omitempty"`
+
+	// KbsSchedulerName is the name of the scheduler dispatching the KBS pods (e.g. a TEE-aware scheduler)
+	// If not provided, the pods are dispatched by the cluster default scheduler
+	KbsSchedulerName string `json:"kbsSchedulerName,omitempty"`
+
+	// KbsPriorityClassName is the name of the PriorityClass assigned to the KBS pods
+	// If not provided, the pods get the cluster default priority
+	KbsPriorityClassName string `json:"kbsPriorityClassName,omitempty"`
+
+	// KbsServiceAccountName is the name of the service account of the KBS pods
+	// If not provided, the operator creates the <KbsConfig name>-kbs-service-account service account
+	KbsServiceAccountName string `json:"kbsServiceAccountName,omitempty"`
+
+	// KbsNodeSelector restricts the KBS pods to the nodes with the given labels
+	// (e.g. the nodes with the confidential computing hardware)
+	KbsNodeSelector map[string]string `json:"kbsNodeSelector,omitempty"`
+
+	// KbsTolerations are the tolerations of the KBS pods, allowing them to run on tainted nodes
+	KbsTolerations []corev1.Toleration `json:"kbsTolerations,omitempty"`
+
+	// KbsAffinity is the affinity of the KBS pods
+	// If it does not define a pod anti-affinity, the KBS replicas are still spread across the nodes
+	KbsAffinity *corev1.Affinity `json:"kbsAffinity,omitempty"`
+
+	// KbsTopologySpreadConstraints spread the KBS replicas across the topology domains (e.g. the zones)
+	// The constraints without label selector select the KBS pods
+	KbsTopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"kbsTopologySpreadConstraints,omitempty"`
+
+	// KbsAppArmorProfile is the AppArmor profile of the containers of the KBS pods
+	// It can assume one of the following values: runtime/default, localhost/<profile>, unconfined
+	// If not provided, the cluster default is used
+	KbsAppArmorProfile string `json:"kbsAppArmorProfile,omitempty"`
+
+	// KbsSELinuxOptions are the SELinux options (user, role, type, level) of the containers of the KBS pods
+	// If not provided, the cluster default is used
+	KbsSELinuxOptions *corev1.SELinuxOptions `json:"kbsSELinuxOptions,omitempty"`
+
+	// KbsPodSecurityContext is the security context of the KBS pods
+	// If not provided, the pods run as non-root with the runtime default seccomp profile.
+	// Images running as root require a RunAsUser on clusters that don't assign one to the pods
+	KbsPodSecurityContext *corev1.PodSecurityContext `json:"kbsPodSecurityContext,omitempty"`
+
+	// KbsContainerSecurityContext is the security context of the containers of the KBS pods
+	// If not provided, the containers drop all the capabilities, don't allow privilege escalation
+	// and, except for the AS and RVPS ones, run with a read-only root filesystem
+	// KbsSELinuxOptions applies when it does not define the SELinux options
+	KbsContainerSecurityContext *corev1.SecurityContext `json:"kbsContainerSecurityContext,omitempty"`
+
+	// KbsAdoptionLabel is the key of the label marking the KBS deployment and services created
+	// outside of the operator (e.g. by a Helm chart) that the operator is allowed to adopt
+	// The adopted resources are owned by the KbsConfig instance and converged to the desired state
+	KbsAdoptionLabel string `json:"kbsAdoptionLabel,omitempty"`
+
+	// KbsAuditLog configures the audit log of the KBS attestation decisions
+	// If not provided, the audit records are written to the standard output
+	KbsAuditLog *KbsAuditLog `json:"kbsAuditLog,omitempty"`
+
+	// KbsStorageClaimName is the name of a PersistentVolumeClaim backing the KBS storage (/opt/confidential-containers)
+	// instead of the in-memory volume, so that the state written at runtime (e.g. the resources set through
+	// the admin API) survives the pod restarts. With multiple replicas the claim must be ReadWriteMany
+	KbsStorageClaimName string `json:"kbsStorageClaimName,omitempty"`
+
+	// KbsStorage is the template of a PersistentVolumeClaim created by the operator for backing the KBS storage,
+	// which is deleted along with the KbsConfig. It's mutually exclusive with KbsStorageClaimName
+	KbsStorage *KbsStorage `json:"kbsStorage,omitempty"`
+
+	// KbsRequireAttestation enforces the attestation of the clients for every resource request
+	// When true (default), a KBS configuration enabling the insecure APIs (insecure_api) is rejected
+	// +kubebuilder:default=true
+	KbsRequireAttestation *bool `json:"kbsRequireAttestation,omitempty"`
+
+	// KbsDrainEndpointPath is the path of the KBS endpoint called before stopping a KBS pod (e.g. on
+	// scale-down or rollout), so that the in-flight attestation sessions are drained
+	KbsDrainEndpointPath string `json:"kbsDrainEndpointPath,omitempty"`
+
+	// KbsDrainTimeout is the time (e.g. "30s") given to a KBS pod to drain the in-flight sessions before
+	// being stopped, at most 1h. It extends the termination grace period of the pods and, when no drain
+	// endpoint is configured, the pod waits for it before being stopped (this requires the
+	// PodLifecycleSleepAction feature gate, enabled by default since Kubernetes 1.30)
+	KbsDrainTimeout string `json:"kbsDrainTimeout,omitempty"`
+
+	// KbsTerminationGracePeriodSeconds is the time given to a KBS pod to terminate before being killed
+	// If not provided, it defaults to 30s extended by KbsDrainTimeout. When provided along with
+	// KbsDrainTimeout, it must be longer than the drain timeout
+	// +kubebuilder:validation:Minimum=0
+	KbsTerminationGracePeriodSeconds *int64 `json:"kbsTerminationGracePeriodSeconds,omitempty"`
+
+	// KbsConfigValidation enables an init container validating the KBS, AS and RVPS configuration files
+	// (well-formed JSON, required fields) before the trustee containers are started, so that an invalid
+	// configuration is reported in the pod status instead of a crash loop. The init container runs the
+	// operator image, which must provide the validate-config command. It's disabled by default
+	// +kubebuilder:default=false
+	KbsConfigValidation *bool `json:"kbsConfigValidation,omitempty"`
+
+	// KbsMaintenanceWindows are the windows during which the changes requiring a rollout of the KBS pods
+	// (configuration, images, ...) are applied. Outside of them the rollouts are deferred to the next window,
+	// as reported by the RolloutDeferred condition. If not provided, the changes are applied immediately
+	KbsMaintenanceWindows []KbsMaintenanceWindow `json:"kbsMaintenanceWindows,omitempty"`
 }
 
 // KbsConfigStatus defines the observed state of KbsConfig
@@ -82,10 +737,62 @@ type KbsConfigStatus struct {
 
 	// IsReady is true when the KBS configuration is ready
 	IsReady bool `json:"isReady,omitempty"`
+
+	// Phase is the overall state of the KBS deployment
+	Phase KbsConfigPhase `json:"phase,omitempty"`
+
+	// ReadyReplicas is the number of ready KBS pods
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// DeploymentType is the type of the KBS deployment
+	DeploymentType DeploymentType `json:"deploymentType,omitempty"`
+
+	// ServiceType is the type of the KBS service
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+
+	// KbsAccessURL is the URL clients reach KBS at from outside of the cluster, through the ingress host
+	// or the load balancer. It's empty when KBS is not exposed externally or while the load balancer
+	// is being provisioned
+	KbsAccessURL string `json:"kbsAccessURL,omitempty"`
+
+	// Conditions represent the latest available observations of the KbsConfig state
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+const (
+	// ConditionReady reports whether all the KBS replicas are up to date and ready
+	ConditionReady = "Ready"
+
+	// ConditionDegraded reports whether the last reconciliation of the KBS resources failed
+	ConditionDegraded = "Degraded"
+
+	// ConditionReferencesResolved reports whether all the ConfigMaps and Secrets referenced by the KbsConfig exist
+	ConditionReferencesResolved = "ReferencesResolved"
+
+	// ConditionImageVersionsAligned reports whether the images of the trustee components
+	// share the same release version. A version skew is only a warning, since it may be intentional
+	ConditionImageVersionsAligned = "ImageVersionsAligned"
+
+	// ConditionRolloutDeferred reports whether a rollout of the KBS deployment is deferred
+	// to the next maintenance window
+	ConditionRolloutDeferred = "RolloutDeferred"
+
+	// ConditionWaiting reports whether the reconciliation is waiting for missing referenced
+	// ConfigMaps and Secrets, it's removed once they all exist
+	ConditionWaiting = "Waiting"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.isReady`
+//+kubebuilder:printcolumn:name="Ready Replicas",type=integer,JSONPath=`.status.readyReplicas`,priority=1
+//+kubebuilder:printcolumn:name="Deployment Type",type=string,JSONPath=`.status.deploymentType`
+//+kubebuilder:printcolumn:name="Service Type",type=string,JSONPath=`.status.serviceType`
+//+kubebuilder:printcolumn:name="Access URL",type=string,JSONPath=`.status.kbsAccessURL`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // KbsConfig is the Schema for the kbsconfigs API
 type KbsConfig struct {
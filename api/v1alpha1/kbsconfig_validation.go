@@ -0,0 +1,85 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// digestRegexp matches the digest part of an image reference (e.g. sha256:<64 hex chars>)
+var digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// MaxChallengeTtl is the longest time a client is given to answer an attestation challenge
+const MaxChallengeTtl = time.Hour
+
+// ValidateImageName checks that the image is referenced either by tag (name[:tag])
+// or by digest (name[:tag]@sha256:...)
+func ValidateImageName(imageName string) error {
+	name, digest, isDigest := strings.Cut(imageName, "@")
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("invalid image name %q", imageName)
+	}
+	if isDigest && !digestRegexp.MatchString(digest) {
+		return fmt.Errorf("invalid digest %q for image %s, expected sha256:<64 hex characters>", digest, name)
+	}
+	return nil
+}
+
+// ValidateImageNames checks the images of the trustee components set in the spec
+func (spec *KbsConfigSpec) ValidateImageNames() error {
+	for _, imageName := range []string{spec.KbsImageName, spec.KbsAsImageName, spec.KbsRvpsImageName} {
+		if imageName == "" {
+			continue
+		}
+		if err := ValidateImageName(imageName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseKbsChallengeTtl parses the attestation challenge TTL, a whole number of minutes up to MaxChallengeTtl
+func ParseKbsChallengeTtl(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid KbsChallengeTtl %q: %w", value, err)
+	}
+	if ttl < time.Minute || ttl > MaxChallengeTtl || ttl%time.Minute != 0 {
+		return 0, fmt.Errorf("invalid KbsChallengeTtl %q: must be a whole number of minutes between 1m and %s",
+			value, MaxChallengeTtl)
+	}
+	return ttl, nil
+}
+
+// ValidateKbsServerSettings checks the bind address and the attestation challenge TTL,
+// which are rendered into the KBS configuration
+func (spec *KbsConfigSpec) ValidateKbsServerSettings() error {
+	if spec.KbsBindAddress != "" && net.ParseIP(spec.KbsBindAddress) == nil {
+		return fmt.Errorf("invalid KbsBindAddress %q: must be an IP address", spec.KbsBindAddress)
+	}
+
+	if spec.KbsChallengeTtl != "" {
+		if _, err := ParseKbsChallengeTtl(spec.KbsChallengeTtl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
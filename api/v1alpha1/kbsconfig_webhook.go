@@ -0,0 +1,134 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var kbsconfiglog = logf.Log.WithName("kbsconfig-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *KbsConfig) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&kbsConfigValidator{}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-confidentialcontainers-org-v1alpha1-kbsconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=confidentialcontainers.org,resources=kbsconfigs,verbs=create;update,versions=v1alpha1,name=vkbsconfig.kb.io,admissionReviewVersions=v1
+
+// kbsConfigValidator validates the KbsConfig instances at admission time
+type kbsConfigValidator struct{}
+
+var _ webhook.CustomValidator = &kbsConfigValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *kbsConfigValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	kbsConfig, ok := obj.(*KbsConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a KbsConfig, but got %T", obj)
+	}
+	kbsconfiglog.Info("validate create", "name", kbsConfig.Name)
+
+	return nil, validateKbsConfigSpec(&kbsConfig.Spec)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *kbsConfigValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	kbsConfig, ok := newObj.(*KbsConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected a KbsConfig, but got %T", newObj)
+	}
+	kbsconfiglog.Info("validate update", "name", kbsConfig.Name)
+
+	return nil, validateKbsConfigSpec(&kbsConfig.Spec)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *kbsConfigValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateKbsConfigSpec rejects the specs that the controller would not be able to deploy
+func validateKbsConfigSpec(spec *KbsConfigSpec) error {
+	// the https private key and certificate go together
+	if (spec.KbsHttpsKeySecretName == "") != (spec.KbsHttpsCertSecretName == "") {
+		return fmt.Errorf("KbsHttpsKeySecretName and KbsHttpsCertSecretName must be set together")
+	}
+	if spec.KbsHttpsCertManager != nil && spec.KbsHttpsKeySecretName != "" {
+		return fmt.Errorf("KbsHttpsCertManager can't be combined with KbsHttpsKeySecretName and KbsHttpsCertSecretName")
+	}
+
+	switch spec.KbsDeploymentType {
+	case "", DeploymentTypeAllInOne, DeploymentTypeMicroservices:
+	default:
+		return fmt.Errorf("unknown KbsDeploymentType %q: must be %s or %s",
+			spec.KbsDeploymentType, DeploymentTypeAllInOne, DeploymentTypeMicroservices)
+	}
+
+	switch spec.KbsServiceType {
+	case "", corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("unknown KbsServiceType %q: must be %s, %s or %s", spec.KbsServiceType,
+			corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer)
+	}
+
+	if spec.KbsNodePort != 0 && spec.KbsServiceType != corev1.ServiceTypeNodePort {
+		return fmt.Errorf("KbsNodePort requires KbsServiceType %s", corev1.ServiceTypeNodePort)
+	}
+
+	if spec.KbsReplicas != nil && *spec.KbsReplicas < 0 {
+		return fmt.Errorf("KbsReplicas must not be negative, got %d", *spec.KbsReplicas)
+	}
+
+	if spec.KbsTerminationGracePeriodSeconds != nil && *spec.KbsTerminationGracePeriodSeconds < 0 {
+		return fmt.Errorf("KbsTerminationGracePeriodSeconds must not be negative, got %d", *spec.KbsTerminationGracePeriodSeconds)
+	}
+
+	// the autoscaler owns the replicas of the KBS deployment
+	if spec.KbsAutoscaling != nil && spec.KbsReplicas != nil {
+		return fmt.Errorf("KbsReplicas and KbsAutoscaling are mutually exclusive")
+	}
+
+	// both provide the volume claim of the KBS storage
+	if spec.KbsStorageClaimName != "" && spec.KbsStorage != nil {
+		return fmt.Errorf("KbsStorageClaimName and KbsStorage are mutually exclusive")
+	}
+
+	// both provide the KBS resource policy
+	if spec.KbsPolicyConfigMapName != "" && len(spec.KbsResourcePolicyRules) > 0 {
+		return fmt.Errorf("KbsPolicyConfigMapName and KbsResourcePolicyRules are mutually exclusive")
+	}
+
+	// images referenced by tag or digest
+	if err := spec.ValidateImageNames(); err != nil {
+		return err
+	}
+
+	// the settings rendered into the KBS configuration
+	return spec.ValidateKbsServerSettings()
+}
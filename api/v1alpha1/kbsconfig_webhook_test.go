@@ -0,0 +1,111 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestKbsConfig(namespace, name string) *KbsConfig {
+	return &KbsConfig{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func TestValidateCreateMultipleInstances(t *testing.T) {
+	// the resources of every KbsConfig are named after it, hence multiple instances are allowed
+	v := &kbsConfigValidator{}
+	for _, kbsConfig := range []*KbsConfig{
+		newTestKbsConfig("trustee", "other"),
+		newTestKbsConfig("other-namespace", "kbsconfig"),
+	} {
+		if _, err := v.ValidateCreate(context.TODO(), kbsConfig); err != nil {
+			t.Errorf("unexpected error for %s/%s: %v", kbsConfig.Namespace, kbsConfig.Name, err)
+		}
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	negative := int32(-1)
+	negativeGracePeriod := int64(-1)
+	tests := []struct {
+		name    string
+		spec    KbsConfigSpec
+		wantErr string
+	}{
+		{"empty spec", KbsConfigSpec{}, ""},
+		{"https key and cert", KbsConfigSpec{KbsHttpsKeySecretName: "key", KbsHttpsCertSecretName: "cert"}, ""},
+		{"https key without cert", KbsConfigSpec{KbsHttpsKeySecretName: "key"}, "KbsHttpsCertSecretName"},
+		{"https cert without key", KbsConfigSpec{KbsHttpsCertSecretName: "cert"}, "KbsHttpsKeySecretName"},
+		{"https cert-manager", KbsConfigSpec{KbsHttpsCertManager: &KbsHttpsCertManager{}}, ""},
+		{"https cert-manager with secrets", KbsConfigSpec{KbsHttpsKeySecretName: "key", KbsHttpsCertSecretName: "cert",
+			KbsHttpsCertManager: &KbsHttpsCertManager{}}, "KbsHttpsCertManager"},
+		{"microservices", KbsConfigSpec{KbsDeploymentType: DeploymentTypeMicroservices}, ""},
+		{"unknown deployment type", KbsConfigSpec{KbsDeploymentType: "Sidecar"}, "unknown KbsDeploymentType"},
+		{"load balancer", KbsConfigSpec{KbsServiceType: "LoadBalancer"}, ""},
+		{"unknown service type", KbsConfigSpec{KbsServiceType: "Headless"}, "unknown KbsServiceType"},
+		{"external name service", KbsConfigSpec{KbsServiceType: "ExternalName"}, "unknown KbsServiceType"},
+		{"lowercase service type", KbsConfigSpec{KbsServiceType: "clusterip"}, "unknown KbsServiceType"},
+		{"node port", KbsConfigSpec{KbsServiceType: "NodePort", KbsNodePort: 30080}, ""},
+		{"node port without node port type", KbsConfigSpec{KbsNodePort: 30080}, "KbsNodePort"},
+		{"negative replicas", KbsConfigSpec{KbsReplicas: &negative}, "KbsReplicas"},
+		{"negative grace period", KbsConfigSpec{KbsTerminationGracePeriodSeconds: &negativeGracePeriod}, "KbsTerminationGracePeriodSeconds"},
+		{"autoscaling", KbsConfigSpec{KbsAutoscaling: &KbsAutoscaling{MaxReplicas: 3}}, ""},
+		{"replicas with autoscaling", KbsConfigSpec{KbsReplicas: &negative, KbsAutoscaling: &KbsAutoscaling{MaxReplicas: 3}}, "KbsReplicas"},
+		{"storage claim with template", KbsConfigSpec{KbsStorageClaimName: "kbs-storage",
+			KbsStorage: &KbsStorage{}}, "KbsStorageClaimName"},
+		{"policy ConfigMap", KbsConfigSpec{KbsPolicyConfigMapName: "kbs-policy"}, ""},
+		{"policy ConfigMap with rules", KbsConfigSpec{KbsPolicyConfigMapName: "kbs-policy",
+			KbsResourcePolicyRules: []KbsResourcePolicyRule{{Path: "default/key/1"}}}, "KbsPolicyConfigMapName"},
+		{"image digest", KbsConfigSpec{KbsImageName: "quay.io/example/kbs@sha256:" + strings.Repeat("0123456789abcdef", 4)}, ""},
+		{"uppercase image digest", KbsConfigSpec{KbsAsImageName: "quay.io/example/as@sha256:" + strings.Repeat("0123456789ABCDEF", 4)},
+			"invalid digest"},
+		{"short image digest", KbsConfigSpec{KbsRvpsImageName: "quay.io/example/rvps@sha256:0123"}, "invalid digest"},
+		{"bind address", KbsConfigSpec{KbsBindAddress: "fd00::5"}, ""},
+		{"bind interface", KbsConfigSpec{KbsBindAddress: "eth0"}, "KbsBindAddress"},
+		{"challenge ttl", KbsConfigSpec{KbsChallengeTtl: "5m"}, ""},
+		{"long challenge ttl", KbsConfigSpec{KbsChallengeTtl: "2h"}, "KbsChallengeTtl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kbsConfig := newTestKbsConfig("trustee", "kbsconfig")
+			kbsConfig.Spec = tt.spec
+			for op, validate := range map[string]func() error{
+				"create": func() error {
+					_, err := (&kbsConfigValidator{}).ValidateCreate(context.TODO(), kbsConfig)
+					return err
+				},
+				"update": func() error {
+					_, err := (&kbsConfigValidator{}).ValidateUpdate(context.TODO(), kbsConfig, kbsConfig)
+					return err
+				},
+			} {
+				err := validate()
+				if tt.wantErr == "" && err != nil {
+					t.Errorf("%s: unexpected error: %v", op, err)
+				}
+				if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Errorf("%s: expected an error containing %q, got %v", op, tt.wantErr, err)
+				}
+			}
+		})
+	}
+}
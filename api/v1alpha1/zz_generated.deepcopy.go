@@ -21,16 +21,74 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CertManagerIssuerReference) DeepCopyInto(out *CertManagerIssuerReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CertManagerIssuerReference.
+func (in *CertManagerIssuerReference) DeepCopy() *CertManagerIssuerReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CertManagerIssuerReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsAuditLog) DeepCopyInto(out *KbsAuditLog) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsAuditLog.
+func (in *KbsAuditLog) DeepCopy() *KbsAuditLog {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsAuditLog)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsAutoscaling) DeepCopyInto(out *KbsAutoscaling) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsAutoscaling.
+func (in *KbsAutoscaling) DeepCopy() *KbsAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KbsConfig) DeepCopyInto(out *KbsConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsConfig.
@@ -86,11 +144,279 @@ func (in *KbsConfigList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KbsConfigSpec) DeepCopyInto(out *KbsConfigSpec) {
 	*out = *in
+	if in.KbsAuthSecretNames != nil {
+		in, out := &in.KbsAuthSecretNames, &out.KbsAuthSecretNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsServiceAnnotations != nil {
+		in, out := &in.KbsServiceAnnotations, &out.KbsServiceAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KbsLoadBalancerSourceRanges != nil {
+		in, out := &in.KbsLoadBalancerSourceRanges, &out.KbsLoadBalancerSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsIngress != nil {
+		in, out := &in.KbsIngress, &out.KbsIngress
+		*out = new(KbsIngress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsReplicas != nil {
+		in, out := &in.KbsReplicas, &out.KbsReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KbsAutoscaling != nil {
+		in, out := &in.KbsAutoscaling, &out.KbsAutoscaling
+		*out = new(KbsAutoscaling)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsDeploymentStrategy != nil {
+		in, out := &in.KbsDeploymentStrategy, &out.KbsDeploymentStrategy
+		*out = new(appsv1.DeploymentStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsHttpsCertManager != nil {
+		in, out := &in.KbsHttpsCertManager, &out.KbsHttpsCertManager
+		*out = new(KbsHttpsCertManager)
+		**out = **in
+	}
 	if in.KbsSecretResources != nil {
 		in, out := &in.KbsSecretResources, &out.KbsSecretResources
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.KbsSecretResourceMounts != nil {
+		in, out := &in.KbsSecretResourceMounts, &out.KbsSecretResourceMounts
+		*out = make([]KbsSecretResourceMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsSecretResourcesSelector != nil {
+		in, out := &in.KbsSecretResourcesSelector, &out.KbsSecretResourcesSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsExternalSecretStore != nil {
+		in, out := &in.KbsExternalSecretStore, &out.KbsExternalSecretStore
+		*out = new(KbsExternalSecretStore)
+		**out = **in
+	}
+	if in.KbsGitResources != nil {
+		in, out := &in.KbsGitResources, &out.KbsGitResources
+		*out = new(KbsGitResources)
+		**out = **in
+	}
+	if in.KbsCommand != nil {
+		in, out := &in.KbsCommand, &out.KbsCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsArgs != nil {
+		in, out := &in.KbsArgs, &out.KbsArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsAsCommand != nil {
+		in, out := &in.KbsAsCommand, &out.KbsAsCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsAsArgs != nil {
+		in, out := &in.KbsAsArgs, &out.KbsAsArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsRvpsCommand != nil {
+		in, out := &in.KbsRvpsCommand, &out.KbsRvpsCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsRvpsArgs != nil {
+		in, out := &in.KbsRvpsArgs, &out.KbsRvpsArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsImagePullSecrets != nil {
+		in, out := &in.KbsImagePullSecrets, &out.KbsImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsCertificateDnsNames != nil {
+		in, out := &in.KbsCertificateDnsNames, &out.KbsCertificateDnsNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsCertificateIpAddresses != nil {
+		in, out := &in.KbsCertificateIpAddresses, &out.KbsCertificateIpAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsMetrics != nil {
+		in, out := &in.KbsMetrics, &out.KbsMetrics
+		*out = new(KbsMetrics)
+		**out = **in
+	}
+	if in.KbsPorts != nil {
+		in, out := &in.KbsPorts, &out.KbsPorts
+		*out = make([]KbsPort, len(*in))
+		copy(*out, *in)
+	}
+	if in.KbsResourcePolicyRules != nil {
+		in, out := &in.KbsResourcePolicyRules, &out.KbsResourcePolicyRules
+		*out = make([]KbsResourcePolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsServiceEndpoints != nil {
+		in, out := &in.KbsServiceEndpoints, &out.KbsServiceEndpoints
+		*out = make([]KbsServiceEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsEnvVars != nil {
+		in, out := &in.KbsEnvVars, &out.KbsEnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsInitContainers != nil {
+		in, out := &in.KbsInitContainers, &out.KbsInitContainers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsExtraVolumes != nil {
+		in, out := &in.KbsExtraVolumes, &out.KbsExtraVolumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsExtraVolumeMounts != nil {
+		in, out := &in.KbsExtraVolumeMounts, &out.KbsExtraVolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsContainerResources != nil {
+		in, out := &in.KbsContainerResources, &out.KbsContainerResources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsAsContainerResources != nil {
+		in, out := &in.KbsAsContainerResources, &out.KbsAsContainerResources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsRvpsContainerResources != nil {
+		in, out := &in.KbsRvpsContainerResources, &out.KbsRvpsContainerResources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsRuntimeClassName != nil {
+		in, out := &in.KbsRuntimeClassName, &out.KbsRuntimeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.KbsResources != nil {
+		in, out := &in.KbsResources, &out.KbsResources
+		*out = make([]KbsResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsHostAliases != nil {
+		in, out := &in.KbsHostAliases, &out.KbsHostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsNodeSelector != nil {
+		in, out := &in.KbsNodeSelector, &out.KbsNodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KbsTolerations != nil {
+		in, out := &in.KbsTolerations, &out.KbsTolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsAffinity != nil {
+		in, out := &in.KbsAffinity, &out.KbsAffinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsTopologySpreadConstraints != nil {
+		in, out := &in.KbsTopologySpreadConstraints, &out.KbsTopologySpreadConstraints
+		*out = make([]v1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.KbsSELinuxOptions != nil {
+		in, out := &in.KbsSELinuxOptions, &out.KbsSELinuxOptions
+		*out = new(v1.SELinuxOptions)
+		**out = **in
+	}
+	if in.KbsPodSecurityContext != nil {
+		in, out := &in.KbsPodSecurityContext, &out.KbsPodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsContainerSecurityContext != nil {
+		in, out := &in.KbsContainerSecurityContext, &out.KbsContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsAuditLog != nil {
+		in, out := &in.KbsAuditLog, &out.KbsAuditLog
+		*out = new(KbsAuditLog)
+		**out = **in
+	}
+	if in.KbsStorage != nil {
+		in, out := &in.KbsStorage, &out.KbsStorage
+		*out = new(KbsStorage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KbsRequireAttestation != nil {
+		in, out := &in.KbsRequireAttestation, &out.KbsRequireAttestation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.KbsTerminationGracePeriodSeconds != nil {
+		in, out := &in.KbsTerminationGracePeriodSeconds, &out.KbsTerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.KbsConfigValidation != nil {
+		in, out := &in.KbsConfigValidation, &out.KbsConfigValidation
+		*out = new(bool)
+		**out = **in
+	}
+	if in.KbsMaintenanceWindows != nil {
+		in, out := &in.KbsMaintenanceWindows, &out.KbsMaintenanceWindows
+		*out = make([]KbsMaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsConfigSpec.
@@ -106,6 +432,13 @@ func (in *KbsConfigSpec) DeepCopy() *KbsConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KbsConfigStatus) DeepCopyInto(out *KbsConfigStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsConfigStatus.
@@ -117,3 +450,236 @@ func (in *KbsConfigStatus) DeepCopy() *KbsConfigStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsExternalSecretStore) DeepCopyInto(out *KbsExternalSecretStore) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsExternalSecretStore.
+func (in *KbsExternalSecretStore) DeepCopy() *KbsExternalSecretStore {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsExternalSecretStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsGitResources) DeepCopyInto(out *KbsGitResources) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsGitResources.
+func (in *KbsGitResources) DeepCopy() *KbsGitResources {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsGitResources)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsHttpsCertManager) DeepCopyInto(out *KbsHttpsCertManager) {
+	*out = *in
+	out.IssuerRef = in.IssuerRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsHttpsCertManager.
+func (in *KbsHttpsCertManager) DeepCopy() *KbsHttpsCertManager {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsHttpsCertManager)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsIngress) DeepCopyInto(out *KbsIngress) {
+	*out = *in
+	if in.IngressClassName != nil {
+		in, out := &in.IngressClassName, &out.IngressClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsIngress.
+func (in *KbsIngress) DeepCopy() *KbsIngress {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsIngress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsMaintenanceWindow) DeepCopyInto(out *KbsMaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsMaintenanceWindow.
+func (in *KbsMaintenanceWindow) DeepCopy() *KbsMaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsMaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsMetrics) DeepCopyInto(out *KbsMetrics) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsMetrics.
+func (in *KbsMetrics) DeepCopy() *KbsMetrics {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsMetrics)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsPort) DeepCopyInto(out *KbsPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsPort.
+func (in *KbsPort) DeepCopy() *KbsPort {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsResource) DeepCopyInto(out *KbsResource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsResource.
+func (in *KbsResource) DeepCopy() *KbsResource {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsResourcePolicyRule) DeepCopyInto(out *KbsResourcePolicyRule) {
+	*out = *in
+	if in.Tees != nil {
+		in, out := &in.Tees, &out.Tees
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsResourcePolicyRule.
+func (in *KbsResourcePolicyRule) DeepCopy() *KbsResourcePolicyRule {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsResourcePolicyRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsSecretResourceMount) DeepCopyInto(out *KbsSecretResourceMount) {
+	*out = *in
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]v1.KeyToPath, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsSecretResourceMount.
+func (in *KbsSecretResourceMount) DeepCopy() *KbsSecretResourceMount {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsSecretResourceMount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsServiceEndpoint) DeepCopyInto(out *KbsServiceEndpoint) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]v1.ServicePort, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsServiceEndpoint.
+func (in *KbsServiceEndpoint) DeepCopy() *KbsServiceEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsServiceEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KbsStorage) DeepCopyInto(out *KbsStorage) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KbsStorage.
+func (in *KbsStorage) DeepCopy() *KbsStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(KbsStorage)
+	in.DeepCopyInto(out)
+	return out
+}
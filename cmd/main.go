@@ -19,7 +19,10 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -29,8 +32,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -55,14 +60,42 @@ func init() {
 }
 
 func main() {
+	// The operator image also validates the configuration files in the init container of the KBS pods
+	if len(os.Args) > 1 && os.Args[1] == controller.ValidateConfigCommand {
+		if err := controller.ValidateConfigFiles(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var syncPeriod time.Duration
+	var forceApply bool
+	var nodeWatchLabel string
+	var startupDelay time.Duration
+	var startupRequiredAPIs string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Hour,
+		"The period after which all the watched resources are reconciled again, "+
+			"so that drifts not caught by the watches are eventually corrected.")
+	flag.BoolVar(&forceApply, "force-apply", true,
+		"Take over the fields of the KBS deployment managed by other controllers. "+
+			"If disabled, the conflicting fields are logged and the KBS deployment is not updated.")
+	flag.StringVar(&nodeWatchLabel, "node-watch-label", "",
+		"Label of the attestation-capable nodes. If set, the KbsConfig instances are reconciled "+
+			"when the label is added to or removed from a node. The watch caches all the nodes of the cluster.")
+	flag.DurationVar(&startupDelay, "startup-delay", 0,
+		"The time waited at startup before reconciling, e.g. while the API server is settling.")
+	flag.StringVar(&startupRequiredAPIs, "startup-required-apis", "",
+		"Comma-separated list of API group versions (e.g. cert-manager.io/v1) which must be served "+
+			"before reconciling. The operator is not ready until they are available.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,6 +110,9 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "178dc119.confidentialcontainers.org",
+		Cache: cache.Options{
+			SyncPeriod: &syncPeriod,
+		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -105,13 +141,42 @@ func main() {
 		os.Exit(1)
 	}
 
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	var requiredGroupVersions []string
+	for _, groupVersion := range strings.Split(startupRequiredAPIs, ",") {
+		if groupVersion = strings.TrimSpace(groupVersion); groupVersion != "" {
+			requiredGroupVersions = append(requiredGroupVersions, groupVersion)
+		}
+	}
+	startupGate := controller.NewStartupGate(discoveryClient, startupDelay, requiredGroupVersions,
+		ctrl.Log.WithName("startup-gate"))
+	if err := mgr.Add(startupGate); err != nil {
+		setupLog.Error(err, "unable to set up startup gate")
+		os.Exit(1)
+	}
+
 	if err = (&controller.KbsConfigReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		ForceApply:     forceApply,
+		NodeWatchLabel: nodeWatchLabel,
+		StartupGate:    startupGate,
+		Recorder:       mgr.GetEventRecorderFor("kbsconfig-controller"),
+		APIReader:      mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KbsConfig")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&confidentialcontainersorgv1alpha1.KbsConfig{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KbsConfig")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -122,6 +187,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("startup", startupGate.Check); err != nil {
+		setupLog.Error(err, "unable to set up startup check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -0,0 +1,128 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateKbsAdoptionLabel checks that the adoption label is a valid label key
+func (r *KbsConfigReconciler) validateKbsAdoptionLabel() error {
+	adoptionLabel := r.kbsConfig.Spec.KbsAdoptionLabel
+	if adoptionLabel == "" {
+		return nil
+	}
+	if errs := validation.IsQualifiedName(adoptionLabel); len(errs) != 0 {
+		return fmt.Errorf("invalid KbsAdoptionLabel %q: %v", adoptionLabel, errs)
+	}
+	return nil
+}
+
+// adoptKbsResources takes ownership of the deployment and the services created outside of the
+// operator (e.g. by a Helm chart or manually) which carry the adoption label, so that they are
+// converged to the desired state instead of being duplicated.
+// Only the resources named as the operator would name them can be adopted
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) adoptKbsResources(ctx context.Context) error {
+	adoptionLabel := r.kbsConfig.Spec.KbsAdoptionLabel
+	if adoptionLabel == "" {
+		return nil
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	err := r.Client.List(ctx, deployments, client.InNamespace(r.namespace), client.HasLabels{adoptionLabel})
+	if err != nil {
+		return err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if deployment.Name != r.kbsDeploymentName() {
+			r.log.Info("Skipping the adoption of a deployment not named as the KBS deployment",
+				"Deployment.Namespace", deployment.Namespace, "Deployment.Name", deployment.Name)
+			continue
+		}
+		// the selector of a deployment is immutable, hence it must already select the KBS pods
+		desiredSelector := &metav1.LabelSelector{MatchLabels: r.kbsPodLabels()}
+		if !reflect.DeepEqual(deployment.Spec.Selector, desiredSelector) {
+			return fmt.Errorf("deployment %s can't be adopted: its selector must match the labels app=kbs,%s=%s",
+				deployment.Name, kbsConfigLabel, r.kbsConfig.Name)
+		}
+		err = r.adoptKbsResource(ctx, deployment)
+		if err != nil {
+			return err
+		}
+	}
+
+	services := &corev1.ServiceList{}
+	err = r.Client.List(ctx, services, client.InNamespace(r.namespace), client.HasLabels{adoptionLabel})
+	if err != nil {
+		return err
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if !r.isKbsServiceName(service.Name) {
+			r.log.Info("Skipping the adoption of a service not named as a KBS service",
+				"Service.Namespace", service.Namespace, "Service.Name", service.Name)
+			continue
+		}
+		err = r.adoptKbsResource(ctx, service)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptKbsResource sets the KbsConfig instance as the controller of the object, unless it already is.
+// Objects controlled by someone else are never taken over
+func (r *KbsConfigReconciler) adoptKbsResource(ctx context.Context, obj client.Object) error {
+	owner := metav1.GetControllerOf(obj)
+	if owner != nil {
+		if owner.UID == r.kbsConfig.UID {
+			return nil
+		}
+		return fmt.Errorf("%s can't be adopted: it's already controlled by %s %s", obj.GetName(), owner.Kind, owner.Name)
+	}
+
+	r.log.Info("Adopting an existing resource", "Namespace", obj.GetNamespace(), "Name", obj.GetName())
+	err := r.setKbsConfigOwner(obj)
+	if err != nil {
+		return err
+	}
+	return r.Client.Update(ctx, obj)
+}
+
+// isKbsServiceName returns true if the operator manages a service with the given name
+func (r *KbsConfigReconciler) isKbsServiceName(name string) bool {
+	if name == r.kbsServiceName() {
+		return true
+	}
+	for _, endpoint := range r.kbsConfig.Spec.KbsServiceEndpoints {
+		if name == r.kbsServiceEndpointName(endpoint) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// asSocket returns the address the AS container listens on
+func (r *KbsConfigReconciler) asSocket() string {
+	if r.kbsConfig.Spec.KbsAsSocket != "" {
+		return r.kbsConfig.Spec.KbsAsSocket
+	}
+	return net.JoinHostPort("0.0.0.0", strconv.Itoa(asPort))
+}
+
+// asListenPort returns the port the AS container listens on
+func (r *KbsConfigReconciler) asListenPort() int32 {
+	_, port, err := net.SplitHostPort(r.asSocket())
+	if err != nil {
+		return asPort
+	}
+	portNumber, err := strconv.ParseInt(port, 10, 32)
+	if err != nil {
+		return asPort
+	}
+	return int32(portNumber)
+}
+
+// validateKbsAsSocket checks that the AS socket is a host:port address, on a port which isn't used
+// by the other containers of the KBS pods
+func (r *KbsConfigReconciler) validateKbsAsSocket() error {
+	socket := r.kbsConfig.Spec.KbsAsSocket
+	if socket == "" {
+		return nil
+	}
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		return fmt.Errorf("KbsAsSocket requires the %s deployment type", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	}
+	if r.usesExternalAs() {
+		return fmt.Errorf("KbsAsSocket can't be combined with KbsExternalAsAddress")
+	}
+	host, port, err := net.SplitHostPort(socket)
+	if err != nil {
+		return fmt.Errorf("invalid KbsAsSocket %q: %w", socket, err)
+	}
+	if host != "" && net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid KbsAsSocket %q: the host must be an IP address", socket)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("invalid KbsAsSocket %q: invalid port %q", socket, port)
+	}
+	for _, usedPort := range []int32{kbsServicePort, r.rvpsListenPort()} {
+		if int32(portNumber) == usedPort {
+			return fmt.Errorf("KbsAsSocket port %d collides with a port already used by the KBS pod", portNumber)
+		}
+	}
+	return nil
+}
+
+// asSocketConfigOverrides returns the overrides pointing KBS at the AS socket, when it differs from the default one
+func (r *KbsConfigReconciler) asSocketConfigOverrides() []configOverride {
+	if r.kbsConfig.Spec.KbsAsSocket == "" || !r.runsAs() {
+		return nil
+	}
+	return []configOverride{
+		{
+			path:  []string{"grpc_config", "as_addr"},
+			value: podLocalURL(r.asSocket()),
+		},
+	}
+}
+
+// podLocalURL returns the URL reaching a socket of a container from the other containers of the KBS pods.
+// A socket bound to all the interfaces is reached on the loopback one, since the containers share the pod network
+func podLocalURL(socket string) string {
+	host, port, _ := net.SplitHostPort(socket)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileAsSocket(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsAsSocket = "0.0.0.0:50010"
+	kbsConfig.Spec.KbsAsConfigMountPath = "/config/as"
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var as corev1.Container
+	for _, container := range getTestDeployment(t, r).Spec.Template.Spec.Containers {
+		if container.Name == "as" {
+			as = container
+		}
+	}
+	command := strings.Join(as.Command, " ")
+	for _, expected := range []string{"--socket 0.0.0.0:50010", "--config-file /config/as/as-config.json"} {
+		if !strings.Contains(command, expected) {
+			t.Errorf("expected %q in the AS command, got %q", expected, command)
+		}
+	}
+	if len(as.Ports) != 1 || as.Ports[0].ContainerPort != 50010 {
+		t.Errorf("expected the AS container port 50010, got %v", as.Ports)
+	}
+	if port := as.ReadinessProbe.TCPSocket.Port.IntValue(); port != 50010 {
+		t.Errorf("expected the AS readiness probe on port 50010, got %d", port)
+	}
+	if path := volumeMountPath(as, "as-config"); path != "/config/as" {
+		t.Errorf("expected the AS configuration to be mounted at /config/as, got %q", path)
+	}
+	// KBS follows the AS port
+	if config := getTestRenderedConfig(t, r, "kbs-config"); !strings.Contains(config, "http://127.0.0.1:50010") {
+		t.Errorf("expected KBS to be pointed at the AS socket, got %s", config)
+	}
+}
+
+func TestValidateKbsAsSocket(t *testing.T) {
+	for _, tc := range []struct {
+		socket         string
+		deploymentType confidentialcontainersorgv1alpha1.DeploymentType
+		valid          bool
+	}{
+		{"", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, true},
+		{"0.0.0.0:50010", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, true},
+		{"[::]:50010", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, true},
+		{"0.0.0.0:50010", confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne, false},
+		{"50010", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, false},
+		{"localhost:50010", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, false},
+		{"0.0.0.0:70000", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, false},
+		{"0.0.0.0:8080", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, false},
+	} {
+		r := newTestReconciler(t)
+		r.kbsConfig = newTestKbsConfig(tc.deploymentType)
+		r.kbsConfig.Spec.KbsAsSocket = tc.socket
+		err := r.validateKbsAsSocket()
+		if tc.valid && err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.socket, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected an error for %q with the %s deployment type", tc.socket, tc.deploymentType)
+		}
+	}
+}
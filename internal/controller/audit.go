@@ -0,0 +1,113 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// auditLogSink returns the configured audit log sink, defaulted to stdout
+func (r *KbsConfigReconciler) auditLogSink() confidentialcontainersorgv1alpha1.AuditLogSink {
+	auditLog := r.kbsConfig.Spec.KbsAuditLog
+	if auditLog == nil || auditLog.Sink == "" {
+		return confidentialcontainersorgv1alpha1.AuditLogSinkStdout
+	}
+	return auditLog.Sink
+}
+
+// validateKbsAuditLog checks that every audit log sink gets the settings it requires, and only them
+func (r *KbsConfigReconciler) validateKbsAuditLog() error {
+	auditLog := r.kbsConfig.Spec.KbsAuditLog
+	if auditLog == nil {
+		return nil
+	}
+
+	switch r.auditLogSink() {
+	case confidentialcontainersorgv1alpha1.AuditLogSinkStdout:
+		if auditLog.PersistentVolumeClaimName != "" || auditLog.Endpoint != "" {
+			return fmt.Errorf("KbsAuditLog: the Stdout sink doesn't accept a volume claim or an endpoint")
+		}
+	case confidentialcontainersorgv1alpha1.AuditLogSinkFile:
+		if auditLog.Endpoint != "" {
+			return fmt.Errorf("KbsAuditLog: the File sink doesn't accept an endpoint")
+		}
+		if errs := validation.IsDNS1123Subdomain(auditLog.PersistentVolumeClaimName); len(errs) != 0 {
+			return fmt.Errorf("KbsAuditLog: invalid volume claim name %q for the File sink: %v",
+				auditLog.PersistentVolumeClaimName, errs)
+		}
+	case confidentialcontainersorgv1alpha1.AuditLogSinkRemote:
+		if auditLog.PersistentVolumeClaimName != "" {
+			return fmt.Errorf("KbsAuditLog: the Remote sink doesn't accept a volume claim")
+		}
+		endpoint, err := url.Parse(auditLog.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("KbsAuditLog: invalid endpoint %q for the Remote sink, an HTTP(S) URL is required",
+				auditLog.Endpoint)
+		}
+	default:
+		return fmt.Errorf("KbsAuditLog: invalid sink %q", auditLog.Sink)
+	}
+	return nil
+}
+
+// auditLogConfigOverrides returns the overrides rendering the audit log sink into the KBS configuration
+func (r *KbsConfigReconciler) auditLogConfigOverrides() []configOverride {
+	auditLog := r.kbsConfig.Spec.KbsAuditLog
+	if auditLog == nil {
+		return nil
+	}
+
+	overrides := []configOverride{
+		{path: []string{"audit_log", "sink"}, value: string(r.auditLogSink())},
+	}
+	switch r.auditLogSink() {
+	case confidentialcontainersorgv1alpha1.AuditLogSinkFile:
+		overrides = append(overrides, configOverride{
+			path:  []string{"audit_log", "path"},
+			value: filepath.Join(auditLogPath, auditLogFileName),
+		})
+	case confidentialcontainersorgv1alpha1.AuditLogSinkRemote:
+		overrides = append(overrides, configOverride{
+			path:  []string{"audit_log", "endpoint"},
+			value: auditLog.Endpoint,
+		})
+	}
+	return overrides
+}
+
+// createAuditLogVolume returns the volume storing the audit log of the File sink
+func (r *KbsConfigReconciler) createAuditLogVolume(volumeName string) (*corev1.Volume, error) {
+	if r.auditLogSink() != confidentialcontainersorgv1alpha1.AuditLogSinkFile {
+		return nil, fmt.Errorf("KbsAuditLog doesn't use the File sink")
+	}
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: r.kbsConfig.Spec.KbsAuditLog.PersistentVolumeClaimName,
+			},
+		},
+	}
+	return &volume, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Path where the additional auth secrets are mounted, one directory per secret
+const authSecretsPath = kbsDefaultConfigPath + "/auth-secrets"
+
+// validateKbsAuthSecretNames checks that every additional auth secret is named and referenced only once
+func (r *KbsConfigReconciler) validateKbsAuthSecretNames() error {
+	names := map[string]bool{}
+	if r.kbsConfig.Spec.KbsAuthSecretName != "" {
+		names[r.kbsConfig.Spec.KbsAuthSecretName] = true
+	}
+	for _, name := range r.kbsConfig.Spec.KbsAuthSecretNames {
+		if name == "" {
+			return fmt.Errorf("invalid KbsAuthSecretNames: empty name")
+		}
+		if names[name] {
+			return fmt.Errorf("duplicated auth secret %s", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// authSecretsVolumeName returns the name of the volume of the i-th additional auth secret
+func authSecretsVolumeName(i int) string {
+	return fmt.Sprintf("auth-secrets-%d", i)
+}
+
+// createAuthSecretsVolumes returns the volumes and the KBS volume mounts of the additional auth secrets
+func (r *KbsConfigReconciler) createAuthSecretsVolumes(ctx context.Context) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for i, name := range r.kbsConfig.Spec.KbsAuthSecretNames {
+		r.log.Info("Retrieving details for KbsAuthSecretNames", "Secret.Namespace", r.namespace, "Secret.Name", name)
+		foundSecret := &corev1.Secret{}
+		err := r.getReferencedObject(ctx, name, foundSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		volumeName := authSecretsVolumeName(i)
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: name,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, createVolumeMount(volumeName, filepath.Join(authSecretsPath, name)))
+	}
+	return volumes, volumeMounts, nil
+}
+
+// authPersonasConfigOverrides returns the override making KBS trust the public keys of the auth secrets
+// Every key of the auth secrets holds a public key, which is registered as an admin persona
+// The configuration is left untouched when there are no additional auth secrets
+func (r *KbsConfigReconciler) authPersonasConfigOverrides(ctx context.Context) ([]configOverride, error) {
+	if len(r.kbsConfig.Spec.KbsAuthSecretNames) == 0 {
+		return nil, nil
+	}
+
+	secrets := map[string]string{}
+	if r.kbsConfig.Spec.KbsAuthSecretName != "" {
+		secrets[r.kbsConfig.Spec.KbsAuthSecretName] = filepath.Join(kbsDefaultConfigPath, "auth-secret")
+	}
+	for _, name := range r.kbsConfig.Spec.KbsAuthSecretNames {
+		secrets[name] = filepath.Join(authSecretsPath, name)
+	}
+
+	var personas []map[string]string
+	for name, mountPath := range secrets {
+		foundSecret := &corev1.Secret{}
+		err := r.getReferencedObject(ctx, name, foundSecret)
+		if err != nil {
+			return nil, err
+		}
+		if len(foundSecret.Data) == 0 {
+			return nil, fmt.Errorf("auth secret %s doesn't contain any public key", name)
+		}
+		for key := range foundSecret.Data {
+			personas = append(personas, map[string]string{
+				"id":              name + "-" + key,
+				"public_key_path": filepath.Join(mountPath, key),
+			})
+		}
+	}
+	// keep the rendered configuration stable across reconciliations
+	sort.Slice(personas, func(i, j int) bool {
+		return personas[i]["id"] < personas[j]["id"]
+	})
+
+	return []configOverride{
+		{path: []string{"admin", "type"}, value: "Simple"},
+		{path: []string{"admin", "personas"}, value: personas},
+	}, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Default average CPU utilization of the KBS pods targeted by the autoscaler
+const defaultAutoscalingTargetCPUUtilization = 80
+
+// validateKbsAutoscaling checks the replicas bounds of the autoscaler
+func (r *KbsConfigReconciler) validateKbsAutoscaling() error {
+	autoscaling := r.kbsConfig.Spec.KbsAutoscaling
+	if autoscaling == nil {
+		return nil
+	}
+	if r.kbsConfig.Spec.KbsReplicas != nil {
+		return fmt.Errorf("KbsReplicas and KbsAutoscaling are mutually exclusive")
+	}
+	if autoscaling.MaxReplicas < autoscalingMinReplicas(autoscaling.MinReplicas) {
+		return fmt.Errorf("invalid KbsAutoscaling: maxReplicas %d is lower than minReplicas %d",
+			autoscaling.MaxReplicas, autoscalingMinReplicas(autoscaling.MinReplicas))
+	}
+	return nil
+}
+
+// autoscalingMinReplicas returns the minimum number of KBS pods of the autoscaler, defaulted to 1
+func autoscalingMinReplicas(minReplicas *int32) int32 {
+	if minReplicas == nil {
+		return 1
+	}
+	return *minReplicas
+}
+
+// deployOrUpdateKbsAutoscaler creates or updates the horizontal pod autoscaler of the KBS deployment,
+// or deletes it when the autoscaling is not enabled
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsAutoscaler(ctx context.Context) error {
+	autoscaling := r.kbsConfig.Spec.KbsAutoscaling
+	found := &autoscalingv2.HorizontalPodAutoscaler{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsDeploymentName()}, found)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if autoscaling == nil {
+		if !exists || !metav1.IsControlledBy(found, r.kbsConfig) {
+			return nil
+		}
+		r.log.Info("Deleting the KBS autoscaler", "HorizontalPodAutoscaler.Namespace", r.namespace,
+			"HorizontalPodAutoscaler.Name", found.Name)
+		return client.IgnoreNotFound(r.Client.Delete(ctx, found))
+	}
+
+	targetCPUUtilization := int32(defaultAutoscalingTargetCPUUtilization)
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		targetCPUUtilization = *autoscaling.TargetCPUUtilizationPercentage
+	}
+	minReplicas := autoscalingMinReplicas(autoscaling.MinReplicas)
+	spec := autoscalingv2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       r.kbsDeploymentName(),
+		},
+		MinReplicas: &minReplicas,
+		MaxReplicas: autoscaling.MaxReplicas,
+		Metrics: []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: corev1.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: &targetCPUUtilization,
+					},
+				},
+			},
+		},
+	}
+
+	if exists {
+		r.log.Info("Updating the KBS autoscaler", "HorizontalPodAutoscaler.Namespace", r.namespace,
+			"HorizontalPodAutoscaler.Name", found.Name)
+		found.Spec = spec
+		err = r.setKbsConfigOwner(found)
+		if err != nil {
+			return err
+		}
+		return r.Client.Update(ctx, found)
+	}
+
+	autoscaler := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.kbsDeploymentName(),
+			Namespace: r.namespace,
+		},
+		Spec: spec,
+	}
+	err = r.setKbsConfigOwner(autoscaler)
+	if err != nil {
+		return err
+	}
+	r.log.Info("Creating the KBS autoscaler", "HorizontalPodAutoscaler.Namespace", r.namespace,
+		"HorizontalPodAutoscaler.Name", autoscaler.Name)
+	return r.Client.Create(ctx, autoscaler)
+}
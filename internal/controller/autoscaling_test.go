@@ -0,0 +1,111 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestValidateKbsAutoscaling(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	r.kbsConfig.Spec.KbsAutoscaling = &confidentialcontainersorgv1alpha1.KbsAutoscaling{MaxReplicas: 3}
+	if err := r.validateKbsAutoscaling(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	r.kbsConfig.Spec.KbsAutoscaling.MinReplicas = pointer(int32(4))
+	if err := r.validateKbsAutoscaling(); err == nil {
+		t.Errorf("expected an error for maxReplicas lower than minReplicas")
+	}
+
+	r.kbsConfig.Spec.KbsAutoscaling.MinReplicas = nil
+	r.kbsConfig.Spec.KbsReplicas = pointer(int32(2))
+	if err := r.validateKbsAutoscaling(); err == nil {
+		t.Errorf("expected an error for KbsReplicas combined with KbsAutoscaling")
+	}
+}
+
+func TestReconcileAutoscaling(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsAutoscaling = &confidentialcontainersorgv1alpha1.KbsAutoscaling{
+		MinReplicas: pointer(int32(2)),
+		MaxReplicas: 4,
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	key := client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsDeploymentName}
+	if err := r.Client.Get(context.TODO(), key, hpa); err != nil {
+		t.Fatalf("getting the KBS autoscaler: %v", err)
+	}
+	if hpa.Spec.ScaleTargetRef.Kind != "Deployment" || hpa.Spec.ScaleTargetRef.Name != testKbsDeploymentName {
+		t.Errorf("unexpected scale target %v", hpa.Spec.ScaleTargetRef)
+	}
+	if *hpa.Spec.MinReplicas != 2 || hpa.Spec.MaxReplicas != 4 {
+		t.Errorf("unexpected replicas bounds %d-%d", *hpa.Spec.MinReplicas, hpa.Spec.MaxReplicas)
+	}
+	if len(hpa.Spec.Metrics) != 1 || *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization != defaultAutoscalingTargetCPUUtilization {
+		t.Errorf("unexpected metrics %v", hpa.Spec.Metrics)
+	}
+	deployment := getTestDeployment(t, r)
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("expected the deployment to start with the minimum replicas, got %d", *deployment.Spec.Replicas)
+	}
+
+	// the replicas set by the autoscaler are not overwritten
+	deployment.Spec.Replicas = pointer(int32(3))
+	if err := r.Client.Update(context.TODO(), deployment); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if replicas := *getTestDeployment(t, r).Spec.Replicas; replicas != 3 {
+		t.Errorf("expected the replicas of the autoscaler to be kept, got %d", replicas)
+	}
+
+	// disabling the autoscaling deletes the autoscaler
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsAutoscaling = nil
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Client.Get(context.TODO(), key, hpa); !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the KBS autoscaler to be deleted, got %v", err)
+	}
+	if replicas := *getTestDeployment(t, r).Spec.Replicas; replicas != 1 {
+		t.Errorf("expected the default replicas once the autoscaling is disabled, got %d", replicas)
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// certificateSANs returns the DNS names and the IP addresses of the KBS https certificate
+// When none of them is provided in the spec, the KBS service DNS names are returned
+func (r *KbsConfigReconciler) certificateSANs() ([]string, []string, error) {
+	dnsNames := r.kbsConfig.Spec.KbsCertificateDnsNames
+	ipAddresses := r.kbsConfig.Spec.KbsCertificateIpAddresses
+
+	for _, dnsName := range dnsNames {
+		if errs := validation.IsWildcardDNS1123Subdomain(dnsName); len(errs) == 0 {
+			continue
+		}
+		if errs := validation.IsDNS1123Subdomain(dnsName); len(errs) != 0 {
+			return nil, nil, fmt.Errorf("invalid certificate DNS name %q: %v", dnsName, errs)
+		}
+	}
+	for _, ipAddress := range ipAddresses {
+		if net.ParseIP(ipAddress) == nil {
+			return nil, nil, fmt.Errorf("invalid certificate IP address %q", ipAddress)
+		}
+	}
+
+	if len(dnsNames) == 0 && len(ipAddresses) == 0 {
+		dnsNames = []string{
+			r.kbsServiceName(),
+			fmt.Sprintf("%s.%s", r.kbsServiceName(), r.namespace),
+			fmt.Sprintf("%s.%s.svc", r.kbsServiceName(), r.namespace),
+			fmt.Sprintf("%s.%s.svc.cluster.local", r.kbsServiceName(), r.namespace),
+		}
+	}
+	return dnsNames, ipAddresses, nil
+}
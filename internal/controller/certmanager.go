@@ -0,0 +1,166 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The Certificate kind of cert-manager, handled as unstructured
+// so that the operator doesn't depend on the cert-manager API
+var certificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1",
+	Kind:    "Certificate",
+}
+
+// kbsHttpsCertificateName returns the name of the cert-manager Certificate and of the secret it issues
+func (r *KbsConfigReconciler) kbsHttpsCertificateName() string {
+	return r.kbsResourceName(KbsHttpsCertificateName)
+}
+
+// validateKbsHttpsCertManager checks that the https certificate is either issued by cert-manager or provided
+func (r *KbsConfigReconciler) validateKbsHttpsCertManager() error {
+	if r.kbsConfig.Spec.KbsHttpsCertManager == nil {
+		return nil
+	}
+	if r.kbsConfig.Spec.KbsHttpsKeySecretName != "" || r.kbsConfig.Spec.KbsHttpsCertSecretName != "" {
+		return fmt.Errorf("KbsHttpsCertManager can't be combined with KbsHttpsKeySecretName and KbsHttpsCertSecretName")
+	}
+	return nil
+}
+
+// newKbsCertificate returns the cert-manager Certificate of KBS, issued for the certificate SANs
+func (r *KbsConfigReconciler) newKbsCertificate() (*unstructured.Unstructured, error) {
+	dnsNames, ipAddresses, err := r.certificateSANs()
+	if err != nil {
+		return nil, err
+	}
+	issuerRef := r.kbsConfig.Spec.KbsHttpsCertManager.IssuerRef
+	issuerKind := issuerRef.Kind
+	if issuerKind == "" {
+		issuerKind = "Issuer"
+	}
+	issuerGroup := issuerRef.Group
+	if issuerGroup == "" {
+		issuerGroup = certificateGVK.Group
+	}
+
+	spec := map[string]interface{}{
+		"secretName": r.kbsHttpsCertificateName(),
+		"issuerRef": map[string]interface{}{
+			"name":  issuerRef.Name,
+			"kind":  issuerKind,
+			"group": issuerGroup,
+		},
+	}
+	// unstructured objects only hold []interface{} slices
+	if len(dnsNames) != 0 {
+		spec["dnsNames"] = toInterfaceSlice(dnsNames)
+	}
+	if len(ipAddresses) != 0 {
+		spec["ipAddresses"] = toInterfaceSlice(ipAddresses)
+	}
+
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetNamespace(r.namespace)
+	certificate.SetName(r.kbsHttpsCertificateName())
+	certificate.Object["spec"] = spec
+	err = r.setKbsConfigOwner(certificate)
+	if err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+func toInterfaceSlice(values []string) []interface{} {
+	slice := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		slice = append(slice, value)
+	}
+	return slice
+}
+
+// deployOrUpdateKbsCertificate creates or updates the cert-manager Certificate of KBS,
+// or deletes it when the certificate is not issued by cert-manager.
+// Nothing is done when the Certificate CRD is not installed in the cluster
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsCertificate(ctx context.Context) error {
+	enabled := r.kbsConfig.Spec.KbsHttpsCertManager != nil
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(certificateGVK)
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsHttpsCertificateName()}, found)
+	if meta.IsNoMatchError(err) {
+		if enabled {
+			r.log.Info("Warning: the cert-manager CRDs are not installed, the KBS https certificate must be provided in the secret",
+				"Secret.Namespace", r.namespace, "Secret.Name", r.kbsHttpsCertificateName())
+		}
+		return nil
+	}
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if !enabled {
+		if !exists || !metav1.IsControlledBy(found, r.kbsConfig) {
+			return nil
+		}
+		r.log.Info("Deleting the KBS https certificate", "Certificate.Namespace", r.namespace, "Certificate.Name", found.GetName())
+		return client.IgnoreNotFound(r.Client.Delete(ctx, found))
+	}
+
+	certificate, err := r.newKbsCertificate()
+	if err != nil {
+		return err
+	}
+	if exists {
+		r.log.Info("Updating the KBS https certificate", "Certificate.Namespace", r.namespace, "Certificate.Name", found.GetName())
+		found.Object["spec"] = certificate.Object["spec"]
+		found.SetOwnerReferences(certificate.GetOwnerReferences())
+		return r.Client.Update(ctx, found)
+	}
+	r.log.Info("Creating the KBS https certificate", "Certificate.Namespace", r.namespace, "Certificate.Name", certificate.GetName())
+	return r.Client.Create(ctx, certificate)
+}
+
+// createCertManagerHttpsVolume returns the volume projecting a key of the secret issued by cert-manager
+// to the file name expected by KBS
+func (r *KbsConfigReconciler) createCertManagerHttpsVolume(volumeName string, key string, fileName string) *corev1.Volume {
+	return &corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: r.kbsHttpsCertificateName(),
+				Items: []corev1.KeyToPath{
+					{Key: key, Path: fileName},
+				},
+			},
+		},
+	}
+}
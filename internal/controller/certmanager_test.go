@@ -0,0 +1,157 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+const testKbsHttpsCertificateName = testKbsConfigName + "-" + KbsHttpsCertificateName
+
+func getTestCertificate(r *KbsConfigReconciler) (*unstructured.Unstructured, error) {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsHttpsCertificateName}, certificate)
+	return certificate, err
+}
+
+func TestValidateKbsHttpsCertManager(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	r.kbsConfig.Spec.KbsHttpsCertManager = &confidentialcontainersorgv1alpha1.KbsHttpsCertManager{
+		IssuerRef: confidentialcontainersorgv1alpha1.CertManagerIssuerReference{Name: "ca-issuer"},
+	}
+	if err := r.validateKbsHttpsCertManager(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	r.kbsConfig.Spec.KbsHttpsKeySecretName = "kbs-https-key"
+	if err := r.validateKbsHttpsCertManager(); err == nil {
+		t.Errorf("expected an error for KbsHttpsCertManager combined with KbsHttpsKeySecretName")
+	}
+}
+
+func TestReconcileKbsHttpsCertManager(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsHttpsCertManager = &confidentialcontainersorgv1alpha1.KbsHttpsCertManager{
+		IssuerRef: confidentialcontainersorgv1alpha1.CertManagerIssuerReference{Name: "ca-issuer", Kind: "ClusterIssuer"},
+	}
+	kbsConfig.Spec.KbsCertificateDnsNames = []string{"kbs.example.com"}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	// the KBS deployment waits for the secret issued by cert-manager
+	if message := reconcileWaitingKbsConfig(t, r); !strings.Contains(message, testKbsHttpsCertificateName) {
+		t.Fatalf("expected the certificate secret to be missing, got %s", message)
+	}
+	certificate, err := getTestCertificate(r)
+	if err != nil {
+		t.Fatalf("getting the KBS https certificate: %v", err)
+	}
+	secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	if secretName != testKbsHttpsCertificateName {
+		t.Errorf("unexpected certificate secret name %q", secretName)
+	}
+	issuerRef, _, _ := unstructured.NestedStringMap(certificate.Object, "spec", "issuerRef")
+	if issuerRef["name"] != "ca-issuer" || issuerRef["kind"] != "ClusterIssuer" || issuerRef["group"] != "cert-manager.io" {
+		t.Errorf("unexpected certificate issuer %v", issuerRef)
+	}
+	dnsNames, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames")
+	if len(dnsNames) != 1 || dnsNames[0] != "kbs.example.com" {
+		t.Errorf("unexpected certificate DNS names %v", dnsNames)
+	}
+	if !metav1.IsControlledBy(certificate, kbsConfig) {
+		t.Errorf("expected the certificate to be owned by the KbsConfig")
+	}
+
+	// cert-manager issues the secret
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testKbsHttpsCertificateName, Namespace: KbsOperatorNamespace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSPrivateKeyKey: []byte("key"), corev1.TLSCertKey: []byte("cert")},
+	}
+	if err := r.Client.Create(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	volumes := map[string]corev1.Volume{}
+	for _, volume := range getTestDeployment(t, r).Spec.Template.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	for volumeName, key := range map[string]string{"https-key": corev1.TLSPrivateKeyKey, "https-cert": corev1.TLSCertKey} {
+		volume, ok := volumes[volumeName]
+		if !ok || volume.Secret == nil || volume.Secret.SecretName != testKbsHttpsCertificateName {
+			t.Errorf("expected the %s volume to mount the issued secret, got %v", volumeName, volume)
+			continue
+		}
+		if len(volume.Secret.Items) != 1 || volume.Secret.Items[0].Key != key {
+			t.Errorf("expected the %s volume to project %s, got %v", volumeName, key, volume.Secret.Items)
+		}
+	}
+}
+
+func TestReconcileKbsHttpsCertManagerWithoutCRD(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsHttpsCertManager = &confidentialcontainersorgv1alpha1.KbsHttpsCertManager{
+		IssuerRef: confidentialcontainersorgv1alpha1.CertManagerIssuerReference{Name: "ca-issuer"},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t)
+	withoutCRD(r, certificateGVK, objs...)
+
+	// no Certificate is created, the secret is reported as missing
+	if message := reconcileWaitingKbsConfig(t, r); !strings.Contains(message, testKbsHttpsCertificateName) {
+		t.Fatalf("expected the certificate secret to be missing, got %s", message)
+	}
+	if _, err := getTestCertificate(r); !meta.IsNoMatchError(err) {
+		t.Errorf("expected no Certificate kind, got %v", err)
+	}
+}
+
+func TestKbsConfigOverridesCertManager(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	r.kbsConfig.Spec.KbsHttpsCertManager = &confidentialcontainersorgv1alpha1.KbsHttpsCertManager{
+		IssuerRef: confidentialcontainersorgv1alpha1.CertManagerIssuerReference{Name: "ca-issuer", Kind: "ClusterIssuer"},
+	}
+
+	// the flat KBS configuration reads the https key and certificate at the top level
+	config := renderSampleKbsConfig(t, r)
+	if config["private_key"] != "/etc/https-key/"+httpsKeyFileName {
+		t.Errorf("expected the issued private key in private_key, got %v", config["private_key"])
+	}
+	if config["certificate"] != "/etc/https-cert/"+httpsCertFileName {
+		t.Errorf("expected the issued certificate in certificate, got %v", config["certificate"])
+	}
+	if config["insecure_http"] != false {
+		t.Errorf("expected https to be enabled, got insecure_http %v", config["insecure_http"])
+	}
+	if _, ok := config["http_server"]; ok {
+		t.Errorf("unexpected http_server section in the flat KBS configuration: %v", config)
+	}
+}
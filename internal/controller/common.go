@@ -21,7 +21,7 @@ const (
 	// KbsFinalizerName for KbsConfig
 	KbsFinalizerName = "kbsconfig.confidentialcontainers.org/finalizer"
 
-	// KBS Deployment name
+	// KBS Deployment name, prefixed by the KbsConfig name
 	KbsDeploymentName = "trustee-deployment"
 
 	// KBS operator default namespace
@@ -36,9 +36,54 @@ const (
 	// Default RVPS image name
 	DefaultRvpsImageName = "ghcr.io/confidential-containers/reference-value-provider-service:latest"
 
-	// KBS service name
+	// Default config validation image name, the operator image
+	DefaultConfigValidationImageName = "quay.io/confidential-containers/trustee-operator:latest"
+
+	// Default git-sync image name, syncing the KBS resources from a Git repository
+	DefaultGitSyncImageName = "registry.k8s.io/git-sync/git-sync:v4.2.4"
+
+	// KBS service name, prefixed by the KbsConfig name
 	KbsServiceName = "kbs-service"
 
+	// KBS metadata ConfigMap name, prefixed by the KbsConfig name
+	KbsMetadataConfigMapName = "trustee-metadata"
+
+	// KBS service account name, prefixed by the KbsConfig name
+	KbsServiceAccountName = "kbs-service-account"
+
+	// KBS ingress name, prefixed by the KbsConfig name
+	KbsIngressName = "kbs-ingress"
+
+	// Name of the cert-manager Certificate of KBS and of the secret it issues, prefixed by the KbsConfig name
+	KbsHttpsCertificateName = "kbs-https-certificate"
+
+	// ConfigMap holding the KBS manifests rendered in dry-run mode, prefixed by the KbsConfig name
+	KbsDryRunConfigMapName = "kbs-dry-run"
+
+	// PersistentVolumeClaim of the KBS storage created from the KbsStorage template, prefixed by the KbsConfig name
+	KbsStorageClaimName = "kbs-storage"
+
+	// Field manager of the operator for the server-side apply of the KBS deployment
+	FieldManager = "trustee-operator"
+
+	// Label identifying the KBS pods of a KbsConfig instance
+	kbsConfigLabel = "confidentialcontainers.org/kbsconfig"
+
+	// Label identifying the additional KBS service endpoints
+	kbsServiceEndpointLabel = "confidentialcontainers.org/kbs-service-endpoint"
+
+	// Label identifying the secrets holding the KbsResources
+	kbsResourceLabel = "confidentialcontainers.org/kbs-resource"
+
+	// KBS service port
+	kbsServicePort = 8080
+
+	// AS gRPC port
+	asPort = 50004
+
+	// RVPS gRPC port
+	rvpsPort = 50003
+
 	// Root path for KBS file system
 	rootPath = "/opt"
 
@@ -64,6 +109,40 @@ const (
 
 	// Default RVPS reference values Path
 	rvpsReferenceValuesPath = confidentialContainersPath + "/rvps"
+
+	// Default AS attestation policy Path
+	attestationPolicyPath = confidentialContainersPath + "/attestation-service/opa"
+
+	// KBS resource policy Path
+	resourcePolicyPath = confidentialContainersPath + "/kbs/opa"
+
+	// KBS https private key and certificate file names, when issued by cert-manager
+	httpsKeyFileName  = "key.pem"
+	httpsCertFileName = "cert.pem"
+
+	// KBS external secret store credentials Path
+	secretStoreCredentialsPath = "/etc/secret-store"
+
+	// Git repository of the KBS resources Path, in the confidential-containers volume
+	gitResourcesPath = confidentialContainersPath + "/kbs/git"
+
+	// Git repository credentials Path
+	gitCredentialsPath = "/etc/git-credentials"
+
+	// Temporary files Path, writable under a read-only root filesystem
+	tmpPath = "/tmp"
+
+	// KBS audit log Path
+	auditLogPath = confidentialContainersPath + "/kbs/audit"
+
+	// KBS audit log file name
+	auditLogFileName = "audit.log"
+
+	// Default AS attestation policy file name
+	attestationPolicyFileName = "default.rego"
+
+	// KBS resource policy file name
+	resourcePolicyFileName = "policy.rego"
 )
 
 func contains(list []string, s string) bool {
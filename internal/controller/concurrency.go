@@ -0,0 +1,43 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// isStaleObjectConflict returns true if the error is an optimistic concurrency conflict, i.e. the
+// object was modified since it was read (e.g. by the previous leader during a failover), which is
+// resolved by reconciling again from a fresh read. The field manager conflicts of a server-side
+// apply are excluded, since they persist until the fields are force applied
+func isStaleObjectConflict(err error) bool {
+	if !k8serrors.IsConflict(err) {
+		return false
+	}
+	var status k8serrors.APIStatus
+	if errors.As(err, &status) && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			if cause.Type == metav1.CauseTypeFieldManagerConflict {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestIsStaleObjectConflict(t *testing.T) {
+	resource := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	conflict := k8serrors.NewConflict(resource, "kbs-deployment", fmt.Errorf("the object has been modified"))
+	fieldManagerConflict := k8serrors.NewConflict(resource, "kbs-deployment", fmt.Errorf("apply failed"))
+	fieldManagerConflict.ErrStatus.Details.Causes = []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.replicas"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"no error", nil, false},
+		{"not found", k8serrors.NewNotFound(resource, "kbs-deployment"), false},
+		{"conflict", conflict, true},
+		{"wrapped conflict", fmt.Errorf("updating: %w", conflict), true},
+		{"field manager conflict", fmt.Errorf("applying: %w", fieldManagerConflict), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if isStaleObjectConflict(tc.err) != tc.expected {
+				t.Errorf("expected %v for %v", tc.expected, tc.err)
+			}
+		})
+	}
+}
+
+func TestReconcileStatusUpdateConflict(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	// emulate the status written by another operator replica in the meantime
+	conflicts := 0
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if conflicts == 0 {
+				conflicts++
+				return k8serrors.NewConflict(schema.GroupResource{Group: confidentialcontainersorgv1alpha1.GroupVersion.Group,
+					Resource: "kbsconfigs"}, obj.GetName(), fmt.Errorf("the object has been modified"))
+			}
+			return c.SubResource(subResourceName).Update(ctx, obj, opts...)
+		},
+	})
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: KbsOperatorNamespace, Name: testKbsConfigName}}
+	result, err := r.Reconcile(context.TODO(), request)
+	if err != nil {
+		t.Fatalf("expected the conflict not to be returned, got %v", err)
+	}
+	if !result.Requeue {
+		t.Errorf("expected the KbsConfig to be requeued after the conflict")
+	}
+	if conflicts != 1 {
+		t.Fatalf("expected a conflicting status update, got %d", conflicts)
+	}
+
+	// the requeued reconciliation succeeds, without reporting the conflict as a failure
+	result, err = r.Reconcile(context.TODO(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Requeue {
+		t.Errorf("expected no requeue once the status is updated")
+	}
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if kbsConfig.Status.Phase == confidentialcontainersorgv1alpha1.KbsConfigPhaseFailed {
+		t.Errorf("expected the conflict not to be reported as a failure")
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// configOverride is a value from the KbsConfig spec that has to be rendered
+// into one of the JSON configuration files of the trustee components.
+// path is the list of nested keys leading to the value
+type configOverride struct {
+	path  []string
+	value interface{}
+}
+
+// asTokenBrokerTypes maps every token format to the AS token broker issuing it
+var asTokenBrokerTypes = map[confidentialcontainersorgv1alpha1.AsTokenFormat]string{
+	confidentialcontainersorgv1alpha1.AsTokenFormatJWT: "Simple",
+	confidentialcontainersorgv1alpha1.AsTokenFormatEAR: "Ear",
+}
+
+// kbsConfigOverrides returns the overrides to be applied to kbs-config.json
+func (r *KbsConfigReconciler) kbsConfigOverrides(ctx context.Context) ([]configOverride, error) {
+	var overrides []configOverride
+
+	// bind address of the KBS HTTP server, which keeps listening on the KBS service port
+	if r.kbsConfig.Spec.KbsBindAddress != "" {
+		overrides = append(overrides, configOverride{
+			path:  []string{"sockets"},
+			value: []string{net.JoinHostPort(r.kbsConfig.Spec.KbsBindAddress, strconv.Itoa(kbsServicePort))},
+		})
+	}
+
+	// https private key and certificate issued by cert-manager, top-level keys of the flat KBS configuration
+	// The certificate is only loaded by KBS over https, hence the plain HTTP mode is turned off
+	if r.kbsConfig.Spec.KbsHttpsCertManager != nil {
+		overrides = append(overrides,
+			configOverride{
+				path:  []string{"private_key"},
+				value: filepath.Join(kbsDefaultConfigPath, "https-key", httpsKeyFileName),
+			},
+			configOverride{
+				path:  []string{"certificate"},
+				value: filepath.Join(kbsDefaultConfigPath, "https-cert", httpsCertFileName),
+			},
+			configOverride{
+				path:  []string{"insecure_http"},
+				value: false,
+			},
+		)
+	}
+
+	// attestation challenge TTL, expressed in minutes in the KBS configuration
+	if r.kbsConfig.Spec.KbsChallengeTtl != "" {
+		ttl, err := confidentialcontainersorgv1alpha1.ParseKbsChallengeTtl(r.kbsConfig.Spec.KbsChallengeTtl)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, configOverride{
+			path:  []string{"timeout"},
+			value: int64(ttl.Minutes()),
+		})
+	}
+
+	// admin public keys
+	authOverrides, err := r.authPersonasConfigOverrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+	overrides = append(overrides, authOverrides...)
+
+	// audit log sink
+	overrides = append(overrides, r.auditLogConfigOverrides()...)
+
+	// resource policy
+	if r.usesResourcePolicy() {
+		overrides = append(overrides, configOverride{
+			path:  []string{"policy_engine_config", "policy_path"},
+			value: filepath.Join(resourcePolicyPath, resourcePolicyFileName),
+		})
+	}
+
+	// external secret store
+	overrides = append(overrides, r.secretStoreConfigOverrides()...)
+
+	// Git repository of the resources
+	overrides = append(overrides, r.gitResourcesConfigOverrides()...)
+
+	// metrics endpoint
+	overrides = append(overrides, r.kbsMetricsConfigOverrides()...)
+
+	// AS socket
+	overrides = append(overrides, r.asSocketConfigOverrides()...)
+
+	// external AS
+	if r.usesExternalAs() {
+		overrides = append(overrides, configOverride{
+			path:  []string{"grpc_config", "as_addr"},
+			value: r.kbsConfig.Spec.KbsExternalAsAddress,
+		})
+	}
+
+	// For the DeploymentTypeAllInOne case the AS is part of KBS and
+	// its configuration is the as_config section of the KBS configuration
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		asOverrides, err := r.asConfigOverrides()
+		if err != nil {
+			return nil, err
+		}
+		for _, override := range asOverrides {
+			overrides = append(overrides, configOverride{
+				path:  append([]string{"as_config"}, override.path...),
+				value: override.value,
+			})
+		}
+	}
+
+	return overrides, nil
+}
+
+// asConfigOverrides returns the overrides to be applied to as-config.json
+func (r *KbsConfigReconciler) asConfigOverrides() ([]configOverride, error) {
+	var overrides []configOverride
+
+	// attestation token format
+	switch r.kbsConfig.Spec.KbsAsTokenFormat {
+	case "":
+		// keep the token broker defined in the AS configuration
+	case confidentialcontainersorgv1alpha1.AsTokenFormatJWT,
+		confidentialcontainersorgv1alpha1.AsTokenFormatEAR:
+		overrides = append(overrides, configOverride{
+			path:  []string{"attestation_token_broker", "type"},
+			value: asTokenBrokerTypes[r.kbsConfig.Spec.KbsAsTokenFormat],
+		})
+	default:
+		return nil, fmt.Errorf("invalid KbsAsTokenFormat %q", r.kbsConfig.Spec.KbsAsTokenFormat)
+	}
+
+	// The RVPS settings only apply when RVPS runs in a separate container
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		return overrides, nil
+	}
+
+	// RVPS socket
+	overrides = append(overrides, r.rvpsSocketConfigOverrides()...)
+
+	// external RVPS
+	if r.kbsConfig.Spec.KbsExternalRvpsAddress != "" {
+		overrides = append(overrides, configOverride{
+			path:  []string{"rvps_config", "remote_addr"},
+			value: r.kbsConfig.Spec.KbsExternalRvpsAddress,
+		})
+	}
+
+	return overrides, nil
+}
+
+// requireAttestation returns true if the clients must be attested for every resource request
+func (r *KbsConfigReconciler) requireAttestation() bool {
+	return r.kbsConfig.Spec.KbsRequireAttestation == nil || *r.kbsConfig.Spec.KbsRequireAttestation
+}
+
+// validateKbsConfigContent checks that the KBS configuration stored in the source ConfigMap
+// complies with the KbsConfig spec
+func (r *KbsConfigReconciler) validateKbsConfigContent(source *corev1.ConfigMap, fileName string) error {
+	if !r.requireAttestation() {
+		return nil
+	}
+	content, ok := source.Data[fileName]
+	if !ok {
+		return fmt.Errorf("%s not found in ConfigMap %s", fileName, source.Name)
+	}
+	config := struct {
+		InsecureApi bool `json:"insecure_api"`
+	}{}
+	err := json.Unmarshal([]byte(content), &config)
+	if err != nil {
+		return fmt.Errorf("invalid %s in ConfigMap %s: %w", fileName, source.Name, err)
+	}
+	if config.InsecureApi {
+		return fmt.Errorf("%s in ConfigMap %s enables the insecure APIs, which is not allowed "+
+			"when KbsRequireAttestation is true", fileName, source.Name)
+	}
+	return nil
+}
+
+// applyConfigOverrides sets the overrides in the JSON document and returns the result
+func applyConfigOverrides(content string, overrides []configOverride) (string, error) {
+	config := map[string]interface{}{}
+	err := json.Unmarshal([]byte(content), &config)
+	if err != nil {
+		return "", err
+	}
+
+	for _, override := range overrides {
+		section := config
+		for _, key := range override.path[:len(override.path)-1] {
+			child, ok := section[key].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				section[key] = child
+			}
+			section = child
+		}
+		section[override.path[len(override.path)-1]] = override.value
+	}
+
+	rendered, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// renderConfigMap applies the overrides to the configuration file stored in the source ConfigMap.
+// The result is stored in a ConfigMap owned by the KbsConfig instance.
+// It returns the name of the ConfigMap to be mounted, that is the source ConfigMap
+// when there is nothing to override.
+func (r *KbsConfigReconciler) renderConfigMap(ctx context.Context, source *corev1.ConfigMap,
+	volumeName string, overrides []configOverride) (string, error) {
+	if len(overrides) == 0 {
+		return source.Name, nil
+	}
+
+	fileName := volumeName + ".json"
+	content, ok := source.Data[fileName]
+	if !ok {
+		return "", fmt.Errorf("%s not found in ConfigMap %s", fileName, source.Name)
+	}
+	rendered, err := applyConfigOverrides(content, overrides)
+	if err != nil {
+		return "", fmt.Errorf("failed to render %s from ConfigMap %s: %w", fileName, source.Name, err)
+	}
+
+	data := map[string]string{}
+	for key, value := range source.Data {
+		data[key] = value
+	}
+	data[fileName] = rendered
+
+	configMapName := r.kbsDeploymentName() + "-" + volumeName
+	err = r.createOrUpdateOwnedConfigMap(ctx, configMapName, data)
+	if err != nil {
+		return "", err
+	}
+	return configMapName, nil
+}
+
+// createOrUpdateOwnedConfigMap creates or updates a ConfigMap owned by the KbsConfig instance
+func (r *KbsConfigReconciler) createOrUpdateOwnedConfigMap(ctx context.Context, name string, data map[string]string) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace,
+		},
+		Data: data,
+	}
+	// Set KbsConfig instance as the owner and controller
+	err := r.setKbsConfigOwner(configMap)
+	if err != nil {
+		return err
+	}
+
+	found := &corev1.ConfigMap{}
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(configMap), found)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("Creating ConfigMap", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name", name)
+		return r.Client.Create(ctx, configMap)
+	} else if err != nil {
+		return err
+	}
+
+	found.Data = configMap.Data
+	found.OwnerReferences = configMap.OwnerReferences
+	r.log.Info("Updating ConfigMap", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name", name)
+	return r.Client.Update(ctx, found)
+}
+
+// deleteOwnedConfigMaps deletes the ConfigMaps owned by the KbsConfig instance
+// (e.g. the rendered configurations and the policies)
+func (r *KbsConfigReconciler) deleteOwnedConfigMaps(ctx context.Context) error {
+	configMapList := &corev1.ConfigMapList{}
+	err := r.Client.List(ctx, configMapList, client.InNamespace(r.namespace))
+	if err != nil {
+		return err
+	}
+	for i := range configMapList.Items {
+		configMap := &configMapList.Items[i]
+		if !metav1.IsControlledBy(configMap, r.kbsConfig) {
+			continue
+		}
+		r.log.Info("Deleting ConfigMap", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name", configMap.Name)
+		err = client.IgnoreNotFound(r.Client.Delete(ctx, configMap))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
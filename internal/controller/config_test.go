@@ -0,0 +1,198 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// sampleKbsConfig returns the kbs-config.json shipped in config/samples for the deployment type
+func sampleKbsConfig(t *testing.T, deploymentType confidentialcontainersorgv1alpha1.DeploymentType) string {
+	t.Helper()
+	sample := "microservices"
+	if deploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		sample = "all-in-one"
+	}
+	data, err := os.ReadFile(filepath.Join("..", "..", "config", "samples", sample, "kbs-config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(data, configMap); err != nil {
+		t.Fatal(err)
+	}
+	return configMap.Data["kbs-config.json"]
+}
+
+// renderSampleKbsConfig applies the kbs-config.json overrides of the KbsConfig to the KBS configuration
+// shipped in config/samples, and returns the rendered configuration
+func renderSampleKbsConfig(t *testing.T, r *KbsConfigReconciler) map[string]interface{} {
+	t.Helper()
+	overrides, err := r.kbsConfigOverrides(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rendered, err := applyConfigOverrides(sampleKbsConfig(t, r.kbsConfig.Spec.KbsDeploymentType), overrides)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(rendered), &config); err != nil {
+		t.Fatalf("rendered configuration is not valid JSON: %v", err)
+	}
+	return config
+}
+
+func TestApplyConfigOverrides(t *testing.T) {
+	content := `{"sockets": ["0.0.0.0:8080"], "as_config": {"work_dir": "/opt"}}`
+	rendered, err := applyConfigOverrides(content, []configOverride{
+		{path: []string{"as_config", "attestation_token_broker", "type"}, value: "Ear"},
+		{path: []string{"grpc_config", "as_addr"}, value: "http://127.0.0.1:50004"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var config struct {
+		AsConfig   map[string]interface{} `json:"as_config"`
+		GrpcConfig map[string]interface{} `json:"grpc_config"`
+	}
+	if err := json.Unmarshal([]byte(rendered), &config); err != nil {
+		t.Fatalf("rendered configuration is not valid JSON: %v", err)
+	}
+
+	if config.AsConfig["work_dir"] != "/opt" {
+		t.Errorf("existing settings must be preserved: %s", rendered)
+	}
+	if broker, _ := config.AsConfig["attestation_token_broker"].(map[string]interface{}); broker["type"] != "Ear" {
+		t.Errorf("nested override not applied: %s", rendered)
+	}
+	if config.GrpcConfig["as_addr"] != "http://127.0.0.1:50004" {
+		t.Errorf("missing sections must be created: %s", rendered)
+	}
+
+	if _, err := applyConfigOverrides("not json", nil); err == nil {
+		t.Errorf("expected an error for an invalid configuration")
+	}
+}
+
+func TestKbsConfigOverridesAllInOne(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne)
+	r.kbsConfig.Spec.KbsAsTokenFormat = confidentialcontainersorgv1alpha1.AsTokenFormatEAR
+
+	overrides, err := r.kbsConfigOverrides(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 1 {
+		t.Fatalf("expected only the AS token format override, got %v", overrides)
+	}
+	if path := overrides[0].path; len(path) != 3 || path[0] != "as_config" || path[1] != "attestation_token_broker" {
+		t.Errorf("unexpected override path %v", path)
+	}
+}
+
+func TestAsConfigOverridesTokenFormat(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	r.kbsConfig.Spec.KbsAsTokenFormat = confidentialcontainersorgv1alpha1.AsTokenFormatEAR
+
+	overrides, err := r.asConfigOverrides()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 1 || overrides[0].value != "Ear" {
+		t.Fatalf("expected the EAR token broker override, got %v", overrides)
+	}
+
+	r.kbsConfig.Spec.KbsAsTokenFormat = "CWT"
+	if _, err := r.asConfigOverrides(); err == nil {
+		t.Errorf("expected an error for an unsupported token format")
+	}
+}
+
+func TestKbsConfigOverridesChallengeTtl(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	r.kbsConfig.Spec.KbsChallengeTtl = "10m"
+	overrides, err := r.kbsConfigOverrides(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != 1 || overrides[0].path[0] != "timeout" || overrides[0].value != int64(10) {
+		t.Fatalf("expected a 10 minutes timeout override, got %v", overrides)
+	}
+
+	for _, ttl := range []string{"30s", "90s", "2h", "ten"} {
+		r.kbsConfig.Spec.KbsChallengeTtl = ttl
+		if _, err := r.kbsConfigOverrides(context.TODO()); err == nil {
+			t.Errorf("expected an error for KbsChallengeTtl %q", ttl)
+		}
+	}
+}
+
+func TestValidateKbsConfigContent(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	insecure := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "kbs-config"},
+		Data:       map[string]string{"kbs-config.json": `{"insecure_api": true}`},
+	}
+
+	// the attestation is required by default
+	if err := r.validateKbsConfigContent(insecure, "kbs-config.json"); err == nil {
+		t.Errorf("expected an error for the insecure APIs")
+	}
+
+	r.kbsConfig.Spec.KbsRequireAttestation = pointer(false)
+	if err := r.validateKbsConfigContent(insecure, "kbs-config.json"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestKbsConfigOverridesBindAddress(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	for address, socket := range map[string]string{"10.0.0.5": "10.0.0.5:8080", "fd00::5": "[fd00::5]:8080"} {
+		r.kbsConfig.Spec.KbsBindAddress = address
+		overrides, err := r.kbsConfigOverrides(context.TODO())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(overrides) != 1 || overrides[0].path[0] != "sockets" || overrides[0].value.([]string)[0] != socket {
+			t.Errorf("expected the %s socket, got %v", socket, overrides)
+		}
+	}
+
+	r.kbsConfig.Spec.KbsBindAddress = "eth0"
+	if err := r.validateKbsConfig(); err == nil {
+		t.Errorf("expected an error for an invalid bind address")
+	}
+}
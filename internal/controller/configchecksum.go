@@ -0,0 +1,72 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Annotation of the KBS pod template holding the checksum of the mounted ConfigMaps and Secrets
+const configChecksumAnnotation = "confidentialcontainers.org/config-checksum"
+
+// configChecksum returns the checksum of the contents of the ConfigMaps and Secrets mounted in the KBS pods
+// The trustee components read their configuration at startup only, hence the checksum is set in the pod
+// template, so that a change of the contents rolls out the pods. An absent object contributes a fixed
+// marker, so that the missing optional objects don't change the checksum between reconciliations
+func (r *KbsConfigReconciler) configChecksum(ctx context.Context, volumes []corev1.Volume) (string, error) {
+	hash := sha256.New()
+	for _, volume := range volumes {
+		var obj client.Object
+		var name string
+		switch {
+		case volume.ConfigMap != nil:
+			obj, name = &corev1.ConfigMap{}, volume.ConfigMap.Name
+		case volume.Secret != nil:
+			obj, name = &corev1.Secret{}, volume.Secret.SecretName
+		default:
+			continue
+		}
+		err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: name}, obj)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return "", err
+		}
+
+		var contents interface{} = "absent"
+		if err == nil {
+			switch o := obj.(type) {
+			case *corev1.ConfigMap:
+				contents = []interface{}{o.Data, o.BinaryData}
+			case *corev1.Secret:
+				contents = o.Data
+			}
+		}
+		// the keys of the maps are sorted by the JSON encoding, which makes the checksum stable
+		data, err := json.Marshal([]interface{}{volume.Name, name, contents})
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// ValidateConfigCommand is the operator command validating the configuration files
+// in the init container of the KBS pods
+const ValidateConfigCommand = "validate-config"
+
+// configValidationResources are the resources of the config validation init container
+var configValidationResources = corev1.ResourceRequirements{
+	Requests: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("10m"),
+		corev1.ResourceMemory: resource.MustParse("32Mi"),
+	},
+	Limits: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("64Mi"),
+	},
+}
+
+// ValidateConfigFiles checks that the configuration files are well-formed JSON objects
+// defining the required top-level fields. Every argument has the <path>[:<field>,<field>...] form
+func ValidateConfigFiles(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no configuration file to validate")
+	}
+	for _, arg := range args {
+		path, fields, _ := strings.Cut(arg, ":")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading the configuration file %s: %w", path, err)
+		}
+		var config map[string]interface{}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return fmt.Errorf("the configuration file %s is not a valid JSON object: %w", path, err)
+		}
+		if fields == "" {
+			continue
+		}
+		for _, field := range strings.Split(fields, ",") {
+			if _, ok := config[field]; !ok {
+				return fmt.Errorf("the configuration file %s misses the required field %q", path, field)
+			}
+		}
+	}
+	return nil
+}
+
+// configValidationEnabled returns whether the configuration files are validated before starting the trustee containers
+func (r *KbsConfigReconciler) configValidationEnabled() bool {
+	return r.kbsConfig.Spec.KbsConfigValidation != nil && *r.kbsConfig.Spec.KbsConfigValidation
+}
+
+// buildConfigValidationContainer returns the init container validating the configuration
+// files of the trustee containers, which are mounted from the same volumes
+func (r *KbsConfigReconciler) buildConfigValidationContainer(securityContext *corev1.SecurityContext) (corev1.Container, error) {
+	imageName, err := getImageName("", "CONFIG_VALIDATION_IMAGE_NAME", DefaultConfigValidationImageName)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+
+	command := []string{"/manager", ValidateConfigCommand}
+	volumeMounts := []corev1.VolumeMount{
+		createVolumeMount("kbs-config", "/etc/kbs-config"),
+	}
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		// the AS is part of KBS, configured by the as_config section
+		command = append(command, "/etc/kbs-config/kbs-config.json:as_config")
+	} else {
+		command = append(command, "/etc/kbs-config/kbs-config.json:grpc_config")
+	}
+	if r.runsAs() {
+		command = append(command, "/etc/as-config/as-config.json:work_dir")
+		volumeMounts = append(volumeMounts, createVolumeMount("as-config", "/etc/as-config"))
+	}
+	if r.runsRvps() {
+		command = append(command, "/etc/rvps-config/rvps-config.json:store_type")
+		volumeMounts = append(volumeMounts, createVolumeMount("rvps-config", "/etc/rvps-config"))
+	}
+
+	return corev1.Container{
+		Name:            "config-validation",
+		Image:           imageName,
+		ImagePullPolicy: r.imagePullPolicy(),
+		Command:         command,
+		SecurityContext: securityContext,
+		Resources:       *configValidationResources.DeepCopy(),
+		// Report the validation error in the pod status
+		TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+		VolumeMounts:             volumeMounts,
+	}, nil
+}
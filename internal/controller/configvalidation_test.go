@@ -0,0 +1,90 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestValidateConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "as-config.json")
+	invalid := filepath.Join(dir, "kbs-config.json")
+	if err := os.WriteFile(valid, []byte(`{"work_dir": "/opt"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte(`{"sockets": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateConfigFiles([]string{valid, valid + ":work_dir"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, args := range [][]string{
+		nil,
+		{invalid},
+		{valid + ":work_dir,policy_engine"},
+		{filepath.Join(dir, "missing.json")},
+	} {
+		if err := ValidateConfigFiles(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+func TestReconcileConfigValidation(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the validation is disabled by default
+	deployment := getTestDeployment(t, r)
+	if len(deployment.Spec.Template.Spec.InitContainers) != 0 {
+		t.Errorf("expected no init container when the validation is not enabled")
+	}
+
+	r.kbsConfig.Spec.KbsConfigValidation = pointer(true)
+	deployment, err := r.newKbsDeployment(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	initContainers := deployment.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 1 || initContainers[0].Name != "config-validation" {
+		t.Fatalf("expected the config validation init container, got %v", initContainers)
+	}
+	if len(initContainers[0].Command) != 5 || len(initContainers[0].VolumeMounts) != 3 {
+		t.Errorf("expected the KBS, AS and RVPS configurations to be validated, got %v", initContainers[0].Command)
+	}
+
+	r.kbsConfig.Spec.KbsConfigValidation = pointer(false)
+	deployment, err = r.newKbsDeployment(context.TODO())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deployment.Spec.Template.Spec.InitContainers) != 0 {
+		t.Errorf("expected no init container when the validation is disabled")
+	}
+}
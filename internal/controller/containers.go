@@ -0,0 +1,262 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+const (
+	// Environment variable setting the number of worker threads of the tokio runtime
+	workerThreadsEnvVar = "TOKIO_WORKER_THREADS"
+	// Environment variable setting the log level of the trustee components
+	logLevelEnvVar = "RUST_LOG"
+	// Default log level of the trustee components
+	defaultLogLevel = "info"
+)
+
+// logLevels are the log levels accepted by the trustee components
+var logLevels = []string{"error", "warn", "info", "debug", "trace"}
+
+// validateKbsCommands checks that the command overrides start with an executable
+func (r *KbsConfigReconciler) validateKbsCommands() error {
+	for name, command := range map[string][]string{
+		"KbsCommand":     r.kbsConfig.Spec.KbsCommand,
+		"KbsAsCommand":   r.kbsConfig.Spec.KbsAsCommand,
+		"KbsRvpsCommand": r.kbsConfig.Spec.KbsRvpsCommand,
+	} {
+		if len(command) > 0 && strings.TrimSpace(command[0]) == "" {
+			return fmt.Errorf("invalid %s %v: the executable is missing", name, command)
+		}
+	}
+	return nil
+}
+
+// overrideCommand returns the command of the KbsConfig spec if provided, the default one otherwise
+func overrideCommand(defaultCommand []string, command []string) []string {
+	if len(command) > 0 {
+		return command
+	}
+	return defaultCommand
+}
+
+// validateKbsLogLevel checks that the log level is one of the levels of the trustee components
+func (r *KbsConfigReconciler) validateKbsLogLevel() error {
+	if r.kbsConfig.Spec.KbsLogLevel == "" || contains(logLevels, r.kbsConfig.Spec.KbsLogLevel) {
+		return nil
+	}
+	return fmt.Errorf("invalid KbsLogLevel %q: must be one of %v", r.kbsConfig.Spec.KbsLogLevel, logLevels)
+}
+
+// logLevelEnv returns the environment variables setting the log level of a trustee container
+func (r *KbsConfigReconciler) logLevelEnv() []corev1.EnvVar {
+	logLevel := r.kbsConfig.Spec.KbsLogLevel
+	if logLevel == "" {
+		logLevel = defaultLogLevel
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  logLevelEnvVar,
+			Value: logLevel,
+		},
+	}
+}
+
+// validateKbsEnvVars checks the names of the environment variables and that each of them has a single value
+func (r *KbsConfigReconciler) validateKbsEnvVars() error {
+	names := map[string]bool{}
+	for _, envVar := range r.kbsConfig.Spec.KbsEnvVars {
+		if errs := validation.IsEnvVarName(envVar.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsEnvVars name %q: %v", envVar.Name, errs)
+		}
+		if names[envVar.Name] {
+			return fmt.Errorf("duplicated KbsEnvVars name %q", envVar.Name)
+		}
+		names[envVar.Name] = true
+		if envVar.Value != "" && envVar.ValueFrom != nil {
+			return fmt.Errorf("KbsEnvVars %q can't have both a value and a valueFrom", envVar.Name)
+		}
+	}
+	return nil
+}
+
+// setEnvVars sets the environment variables in a trustee container, replacing the ones with the same name
+func setEnvVars(container *corev1.Container, envVars []corev1.EnvVar) {
+	for _, envVar := range envVars {
+		replaced := false
+		for i := range container.Env {
+			if container.Env[i].Name == envVar.Name {
+				container.Env[i] = envVar
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			container.Env = append(container.Env, envVar)
+		}
+	}
+}
+
+// setWorkerThreads sets the number of worker threads of the container async runtime.
+// Without it, the runtime spawns a thread per node CPU, ignoring the container CPU limit.
+// When no value is provided, it's derived from the CPU limit (rounded up), if any
+func setWorkerThreads(container *corev1.Container, workerThreads int32) {
+	threads := int64(workerThreads)
+	if threads == 0 {
+		cpuLimit, ok := container.Resources.Limits[corev1.ResourceCPU]
+		if !ok {
+			return
+		}
+		threads = (cpuLimit.MilliValue() + 999) / 1000
+		if threads < 1 {
+			threads = 1
+		}
+	}
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name:  workerThreadsEnvVar,
+		Value: strconv.FormatInt(threads, 10),
+	})
+}
+
+// containerResourceProfiles are the default resources of the trustee containers
+// in the DeploymentTypeMicroservices case
+var containerResourceProfiles = map[string]corev1.ResourceRequirements{
+	"kbs": {
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	},
+	"as": {
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200m"),
+			corev1.ResourceMemory: resource.MustParse("256Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("2"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	},
+	"rvps": {
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("50m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("256Mi"),
+		},
+	},
+}
+
+// defaultContainerResources returns the default resources of a trustee container.
+// In the DeploymentTypeAllInOne case the KBS container also runs the AS and the RVPS,
+// hence it gets the sum of the resources of the three microservices containers
+func defaultContainerResources(deploymentType confidentialcontainersorgv1alpha1.DeploymentType,
+	containerName string) corev1.ResourceRequirements {
+	if deploymentType != confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		profile := containerResourceProfiles[containerName]
+		return *profile.DeepCopy()
+	}
+
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+	for _, profile := range containerResourceProfiles {
+		addResourceList(resources.Requests, profile.Requests)
+		addResourceList(resources.Limits, profile.Limits)
+	}
+	return resources
+}
+
+// specContainerResources returns the resources of a trustee container provided in the spec, if any
+func (r *KbsConfigReconciler) specContainerResources(containerName string) *corev1.ResourceRequirements {
+	switch containerName {
+	case "kbs":
+		return r.kbsConfig.Spec.KbsContainerResources
+	case "as":
+		return r.kbsConfig.Spec.KbsAsContainerResources
+	case "rvps":
+		return r.kbsConfig.Spec.KbsRvpsContainerResources
+	}
+	return nil
+}
+
+// containerResources returns the resources of a trustee container, either provided in the spec or the default ones
+func (r *KbsConfigReconciler) containerResources(containerName string) corev1.ResourceRequirements {
+	if resources := r.specContainerResources(containerName); resources != nil {
+		return *resources.DeepCopy()
+	}
+	return defaultContainerResources(r.kbsConfig.Spec.KbsDeploymentType, containerName)
+}
+
+// validateKbsContainerResources checks that the requests of the trustee containers don't exceed their limits
+func (r *KbsConfigReconciler) validateKbsContainerResources() error {
+	for _, containerName := range []string{"kbs", "as", "rvps"} {
+		resources := r.specContainerResources(containerName)
+		if resources == nil {
+			continue
+		}
+		for name, request := range resources.Requests {
+			if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+				return fmt.Errorf("invalid %s container resources: the %s request %s exceeds the limit %s",
+					containerName, name, request.String(), limit.String())
+			}
+		}
+	}
+	return nil
+}
+
+// podSchedulingRequests returns the resources the scheduler reserves for a pod: the sum of the
+// requests of the containers, raised to the request of any init container if higher, plus the pod overhead
+func podSchedulingRequests(initContainers []corev1.Container, containers []corev1.Container,
+	overhead corev1.ResourceList) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range containers {
+		addResourceList(requests, container.Resources.Requests)
+	}
+	for _, container := range initContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	addResourceList(requests, overhead)
+	return requests
+}
+
+func addResourceList(total corev1.ResourceList, list corev1.ResourceList) {
+	for name, quantity := range list {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestDefaultContainerResources(t *testing.T) {
+	resources := defaultContainerResources(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, "as")
+	if cpu := resources.Limits[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("unexpected AS CPU limit %s", cpu.String())
+	}
+
+	// the AllInOne KBS container gets the resources of the three microservices containers
+	resources = defaultContainerResources(confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne, "kbs")
+	if cpu := resources.Limits[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("3500m")) != 0 {
+		t.Errorf("unexpected AllInOne CPU limit %s", cpu.String())
+	}
+	if memory := resources.Requests[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("448Mi")) != 0 {
+		t.Errorf("unexpected AllInOne memory request %s", memory.String())
+	}
+
+	// the profiles must not be modified by the callers
+	resources.Limits[corev1.ResourceCPU] = resource.MustParse("10")
+	resources = defaultContainerResources(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices, "kbs")
+	if cpu := resources.Limits[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("1")) != 0 {
+		t.Errorf("unexpected KBS CPU limit %s", cpu.String())
+	}
+}
+
+func TestPodSchedulingRequests(t *testing.T) {
+	container := func(cpu string) corev1.Container {
+		return corev1.Container{Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+		}}
+	}
+	overhead := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("250m"),
+		corev1.ResourceMemory: resource.MustParse("160Mi"),
+	}
+
+	requests := podSchedulingRequests([]corev1.Container{container("50m")},
+		[]corev1.Container{container("100m"), container("200m")}, overhead)
+	if cpu := requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("550m")) != 0 {
+		t.Errorf("expected 550m CPU, got %s", cpu.String())
+	}
+	if memory := requests[corev1.ResourceMemory]; memory.Cmp(resource.MustParse("160Mi")) != 0 {
+		t.Errorf("expected the memory overhead, got %s", memory.String())
+	}
+
+	// an init container requesting more than the containers raises the requests
+	requests = podSchedulingRequests([]corev1.Container{container("1")},
+		[]corev1.Container{container("100m")}, nil)
+	if cpu := requests[corev1.ResourceCPU]; cpu.Cmp(resource.MustParse("1")) != 0 {
+		t.Errorf("expected 1 CPU, got %s", cpu.String())
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotation of the KbsConfig enabling the dry-run mode, where the KBS manifests are rendered
+// in a ConfigMap instead of being applied
+const dryRunAnnotation = "confidentialcontainers.org/dry-run"
+
+// Key of the dry-run ConfigMap holding the rendered manifests
+const dryRunManifestsKey = "manifests.yaml"
+
+// isDryRun returns true if the KbsConfig is annotated for the dry-run mode
+func (r *KbsConfigReconciler) isDryRun() bool {
+	return r.kbsConfig.Annotations[dryRunAnnotation] == "true"
+}
+
+// renderKbsDryRun renders the KBS deployment and service in the dry-run ConfigMap.
+// The existing KBS resources are left untouched
+func (r *KbsConfigReconciler) renderKbsDryRun(ctx context.Context) error {
+	err := r.validateKbsConfig()
+	if err != nil {
+		r.reportReconcileFailure(ctx, "InvalidSpec", err)
+		return err
+	}
+	err = r.validateReferences(ctx)
+	if err != nil {
+		r.reportReconcileFailure(ctx, "MissingReferences", err)
+		return err
+	}
+
+	manifests, err := r.renderKbsManifests(ctx)
+	if err != nil {
+		return err
+	}
+	err = r.createOrUpdateOwnedConfigMap(ctx, r.kbsDryRunConfigMapName(), map[string]string{
+		dryRunManifestsKey: manifests,
+	})
+	if err != nil {
+		return err
+	}
+	r.recordEvent(corev1.EventTypeNormal, "DryRun", "Rendered the KBS manifests in ConfigMap %s", r.kbsDryRunConfigMapName())
+	return nil
+}
+
+// renderKbsManifests returns the YAML of the desired KBS deployment and service.
+// Building them creates the rendered configuration ConfigMaps and Secrets, hence
+// a dry-run client is used to avoid persisting any of them
+func (r *KbsConfigReconciler) renderKbsManifests(ctx context.Context) (string, error) {
+	realClient := r.Client
+	r.Client = client.NewDryRunClient(realClient)
+	defer func() {
+		r.Client = realClient
+	}()
+
+	deployment, err := r.newKbsDeployment(ctx)
+	if err != nil {
+		return "", err
+	}
+	service, err := r.newKbsService(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	documents := []string{}
+	for _, obj := range []client.Object{deployment, service} {
+		gvk, err := r.Client.GroupVersionKindFor(obj)
+		if err != nil {
+			return "", err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, string(data))
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+// deleteKbsDryRunConfigMap deletes the manifests rendered while the KbsConfig was in dry-run mode
+func (r *KbsConfigReconciler) deleteKbsDryRunConfigMap(ctx context.Context) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Name:      r.kbsDryRunConfigMapName(),
+		},
+	}
+	return client.IgnoreNotFound(r.Client.Delete(ctx, configMap))
+}
@@ -0,0 +1,85 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileDryRun(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Annotations = map[string]string{dryRunAnnotation: "true"}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// nothing is deployed, including the rendered configuration
+	for _, check := range []struct {
+		name string
+		obj  client.Object
+	}{
+		{testKbsDeploymentName, &appsv1.Deployment{}},
+		{testKbsConfigName + "-" + KbsServiceName, &corev1.Service{}},
+		{testKbsDeploymentName + "-as-config", &corev1.ConfigMap{}},
+	} {
+		err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: check.name}, check.obj)
+		if !k8serrors.IsNotFound(err) {
+			t.Errorf("expected %s not to be created in dry-run mode, got %v", check.name, err)
+		}
+	}
+
+	// the manifests are rendered in the dry-run ConfigMap
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsConfigName + "-" + KbsDryRunConfigMapName}
+	if err := r.Client.Get(context.TODO(), key, configMap); err != nil {
+		t.Fatal(err)
+	}
+	manifests := configMap.Data[dryRunManifestsKey]
+	for _, expected := range []string{"kind: Deployment", "kind: Service", "name: " + testKbsDeploymentName} {
+		if !strings.Contains(manifests, expected) {
+			t.Errorf("expected the rendered manifests to contain %q, got:\n%s", expected, manifests)
+		}
+	}
+
+	// the KBS is deployed once the annotation is removed
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	delete(kbsConfig.Annotations, dryRunAnnotation)
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	getTestDeployment(t, r)
+	if err := r.Client.Get(context.TODO(), key, configMap); !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the dry-run ConfigMap to be deleted, got %v", err)
+	}
+}
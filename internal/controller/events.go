@@ -0,0 +1,68 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+// Reasons of the events recorded on the KbsConfig instances
+const (
+	eventReasonDeploymentCreated = "DeploymentCreated"
+	eventReasonDeploymentUpdated = "DeploymentUpdated"
+	eventReasonServiceCreated    = "ServiceCreated"
+	eventReasonServiceUpdated    = "ServiceUpdated"
+)
+
+// recordEvent records an event on the KbsConfig instance, so that it's reported by kubectl describe
+func (r *KbsConfigReconciler) recordEvent(eventType string, reason string, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(r.kbsConfig, eventType, reason, messageFmt, args...)
+}
+
+// serviceChanged returns true if applying the desired service changes the type, the selector, the ports
+// or the load balancer settings of the found one. The fields assigned by the cluster (e.g. the node ports)
+// and the annotations not set by the operator are ignored
+func serviceChanged(found *corev1.Service, desired *corev1.Service) bool {
+	if found.Spec.Type != desired.Spec.Type || len(found.Spec.Ports) != len(desired.Spec.Ports) {
+		return true
+	}
+	if len(found.Spec.Selector) != len(desired.Spec.Selector) {
+		return true
+	}
+	for key, value := range desired.Spec.Selector {
+		if found.Spec.Selector[key] != value {
+			return true
+		}
+	}
+	if !equality.Semantic.DeepEqual(found.Annotations, mergeServiceAnnotations(found.Annotations, desired.Annotations)) ||
+		!equality.Semantic.DeepEqual(found.Spec.LoadBalancerSourceRanges, desired.Spec.LoadBalancerSourceRanges) {
+		return true
+	}
+	for i, port := range desired.Spec.Ports {
+		if found.Spec.Ports[i].Name != port.Name || found.Spec.Ports[i].Port != port.Port ||
+			found.Spec.Ports[i].TargetPort != port.TargetPort ||
+			(port.Protocol != "" && found.Spec.Ports[i].Protocol != port.Protocol) ||
+			(port.NodePort != 0 && found.Spec.Ports[i].NodePort != port.NodePort) {
+			return true
+		}
+	}
+	return false
+}
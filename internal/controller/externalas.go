@@ -0,0 +1,71 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// usesExternalAs returns true if KBS is pointed at an AS running outside of the KBS pods
+func (r *KbsConfigReconciler) usesExternalAs() bool {
+	return r.kbsConfig.Spec.KbsDeploymentType != confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne &&
+		r.kbsConfig.Spec.KbsExternalAsAddress != ""
+}
+
+// runsAs returns true if the AS runs in a dedicated container of the KBS pods
+func (r *KbsConfigReconciler) runsAs() bool {
+	return r.kbsConfig.Spec.KbsDeploymentType != confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne &&
+		r.kbsConfig.Spec.KbsExternalAsAddress == ""
+}
+
+// runsRvps returns true if the RVPS runs in a dedicated container of the KBS pods,
+// which is only needed by the AS of the KBS pods
+func (r *KbsConfigReconciler) runsRvps() bool {
+	return r.runsAs() && r.kbsConfig.Spec.KbsExternalRvpsAddress == ""
+}
+
+// validateKbsExternalEndpoints checks that the external AS and RVPS addresses are http(s) URLs
+// and that they're only provided when the AS and the RVPS would run in dedicated containers
+func (r *KbsConfigReconciler) validateKbsExternalEndpoints() error {
+	spec := &r.kbsConfig.Spec
+	for name, address := range map[string]string{
+		"KbsExternalAsAddress":   spec.KbsExternalAsAddress,
+		"KbsExternalRvpsAddress": spec.KbsExternalRvpsAddress,
+	} {
+		if address == "" {
+			continue
+		}
+		if spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+			return fmt.Errorf("%s requires the %s deployment type", name, confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+		}
+		endpoint, err := url.Parse(address)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, address, err)
+		}
+		if (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Hostname() == "" ||
+			endpoint.Port() == "" || (endpoint.Path != "" && endpoint.Path != "/") {
+			return fmt.Errorf("invalid %s %q: must be an http(s) URL with a host and a port", name, address)
+		}
+	}
+	if spec.KbsExternalAsAddress != "" && spec.KbsExternalRvpsAddress != "" {
+		return fmt.Errorf("KbsExternalRvpsAddress is only used by the AS of the KBS pods, it can't be combined with KbsExternalAsAddress")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// getTestRenderedConfig returns the content of a configuration file rendered by the operator
+func getTestRenderedConfig(t *testing.T, r *KbsConfigReconciler, volumeName string) string {
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsDeploymentName + "-" + volumeName}
+	if err := r.Client.Get(context.TODO(), key, configMap); err != nil {
+		t.Fatal(err)
+	}
+	return configMap.Data[volumeName+".json"]
+}
+
+func TestReconcileExternalAs(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsExternalAsAddress = "http://as.trustee.svc:50004"
+	// the AS and RVPS configurations aren't needed
+	kbsConfig.Spec.KbsAsConfigMapName = ""
+	kbsConfig.Spec.KbsRvpsConfigMapName = ""
+	kbsConfig.Spec.KbsRvpsRefValuesConfigMapName = ""
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	podSpec := getTestDeployment(t, r).Spec.Template.Spec
+	if len(podSpec.Containers) != 1 || podSpec.Containers[0].Name != "kbs" {
+		t.Errorf("expected only the KBS container, got %v", podSpec.Containers)
+	}
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "as-config" || volume.Name == "rvps-config" || volume.Name == "reference-values" {
+			t.Errorf("unexpected volume %s", volume.Name)
+		}
+	}
+	if config := getTestRenderedConfig(t, r, "kbs-config"); !strings.Contains(config, "http://as.trustee.svc:50004") {
+		t.Errorf("expected KBS to be pointed at the external AS, got %s", config)
+	}
+}
+
+func TestReconcileExternalRvps(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsExternalRvpsAddress = "http://rvps.trustee.svc:50003"
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	containers := getTestDeployment(t, r).Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[0].Name != "kbs" || containers[1].Name != "as" {
+		t.Errorf("expected the KBS and AS containers, got %v", containers)
+	}
+	if config := getTestRenderedConfig(t, r, "as-config"); !strings.Contains(config, "http://rvps.trustee.svc:50003") {
+		t.Errorf("expected the AS to be pointed at the external RVPS, got %s", config)
+	}
+}
+
+func TestValidateKbsExternalEndpoints(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	for _, address := range []string{"as.trustee.svc:50004", "grpc://as:50004", "http://as", "http://as:50004/path", "http://:50004"} {
+		r.kbsConfig.Spec.KbsExternalAsAddress = address
+		if err := r.validateKbsExternalEndpoints(); err == nil {
+			t.Errorf("expected an error for KbsExternalAsAddress %q", address)
+		}
+	}
+
+	r.kbsConfig.Spec.KbsExternalAsAddress = "https://as.trustee.svc:50004"
+	if err := r.validateKbsExternalEndpoints(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	r.kbsConfig.Spec.KbsExternalRvpsAddress = "http://rvps.trustee.svc:50003"
+	if err := r.validateKbsExternalEndpoints(); err == nil {
+		t.Errorf("expected an error for the external RVPS along with the external AS")
+	}
+
+	r.kbsConfig.Spec.KbsExternalRvpsAddress = ""
+	r.kbsConfig.Spec.KbsDeploymentType = confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne
+	if err := r.validateKbsExternalEndpoints(); err == nil {
+		t.Errorf("expected an error for the external AS with the all-in-one deployment")
+	}
+}
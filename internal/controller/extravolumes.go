@@ -0,0 +1,77 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateKbsExtraVolumes checks that the extra volumes have valid and unique names, and that
+// the extra volume mounts reference them at absolute paths
+func (r *KbsConfigReconciler) validateKbsExtraVolumes() error {
+	names := map[string]bool{}
+	for _, volume := range r.kbsConfig.Spec.KbsExtraVolumes {
+		if errs := validation.IsDNS1123Label(volume.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsExtraVolumes name %q: %v", volume.Name, errs)
+		}
+		if names[volume.Name] {
+			return fmt.Errorf("duplicated KbsExtraVolumes name %q", volume.Name)
+		}
+		names[volume.Name] = true
+	}
+	for _, volumeMount := range r.kbsConfig.Spec.KbsExtraVolumeMounts {
+		if !names[volumeMount.Name] {
+			return fmt.Errorf("KbsExtraVolumeMounts %q doesn't reference any of the KbsExtraVolumes", volumeMount.Name)
+		}
+		if !filepath.IsAbs(volumeMount.MountPath) {
+			return fmt.Errorf("invalid KbsExtraVolumeMounts %q mount path %q: must be an absolute path",
+				volumeMount.Name, volumeMount.MountPath)
+		}
+	}
+	return nil
+}
+
+// appendKbsExtraVolumes appends the extra volumes to the pod volumes and the extra volume mounts
+// to the KBS container ones, rejecting those colliding with the volumes and mount paths of the operator
+func (r *KbsConfigReconciler) appendKbsExtraVolumes(volumes []corev1.Volume, kbsVM []corev1.VolumeMount) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	for _, volume := range r.kbsConfig.Spec.KbsExtraVolumes {
+		for _, existing := range volumes {
+			if existing.Name == volume.Name {
+				return nil, nil, fmt.Errorf("KbsExtraVolumes %q collides with a volume of the operator", volume.Name)
+			}
+		}
+	}
+	for _, volumeMount := range r.kbsConfig.Spec.KbsExtraVolumeMounts {
+		for _, existing := range kbsVM {
+			if filepath.Clean(existing.MountPath) == filepath.Clean(volumeMount.MountPath) {
+				return nil, nil, fmt.Errorf("KbsExtraVolumeMounts %q mount path %s collides with the %s volume mount",
+					volumeMount.Name, volumeMount.MountPath, existing.Name)
+			}
+		}
+	}
+	for _, volume := range r.kbsConfig.Spec.KbsExtraVolumes {
+		volumes = append(volumes, *volume.DeepCopy())
+	}
+	for _, volumeMount := range r.kbsConfig.Spec.KbsExtraVolumeMounts {
+		kbsVM = append(kbsVM, *volumeMount.DeepCopy())
+	}
+	return volumes, kbsVM, nil
+}
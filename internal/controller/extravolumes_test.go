@@ -0,0 +1,124 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileExtraVolumes(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsExtraVolumes = []corev1.Volume{{
+		Name: "attestation-token",
+		VolumeSource: corev1.VolumeSource{
+			Projected: &corev1.ProjectedVolumeSource{
+				Sources: []corev1.VolumeProjection{{
+					ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+						Audience:          "trustee",
+						ExpirationSeconds: pointer(int64(3600)),
+						Path:              "token",
+					},
+				}},
+			},
+		},
+	}}
+	kbsConfig.Spec.KbsExtraVolumeMounts = []corev1.VolumeMount{
+		{Name: "attestation-token", MountPath: "/var/run/secrets/trustee", ReadOnly: true},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	volume := getTestVolume(t, r, "attestation-token")
+	if volume.Projected == nil || len(volume.Projected.Sources) != 1 ||
+		volume.Projected.Sources[0].ServiceAccountToken == nil {
+		t.Errorf("expected the projected volume in the pod spec, got %v", volume)
+	}
+	kbsContainer := getTestDeployment(t, r).Spec.Template.Spec.Containers[0]
+	if mountPath := volumeMountPath(kbsContainer, "attestation-token"); mountPath != "/var/run/secrets/trustee" {
+		t.Errorf("expected the extra volume mounted in the KBS container, got %q", mountPath)
+	}
+}
+
+func TestValidateKbsExtraVolumes(t *testing.T) {
+	emptyDir := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	for _, tc := range []struct {
+		name         string
+		volumes      []corev1.Volume
+		volumeMounts []corev1.VolumeMount
+		expected     string
+	}{
+		{
+			name:     "invalid volume name",
+			volumes:  []corev1.Volume{{Name: "Extra", VolumeSource: emptyDir}},
+			expected: "invalid KbsExtraVolumes name",
+		},
+		{
+			name:     "duplicated volume name",
+			volumes:  []corev1.Volume{{Name: "extra", VolumeSource: emptyDir}, {Name: "extra", VolumeSource: emptyDir}},
+			expected: "duplicated KbsExtraVolumes name",
+		},
+		{
+			name:         "undefined volume",
+			volumes:      []corev1.Volume{{Name: "extra", VolumeSource: emptyDir}},
+			volumeMounts: []corev1.VolumeMount{{Name: "other", MountPath: "/extra"}},
+			expected:     "doesn't reference any of the KbsExtraVolumes",
+		},
+		{
+			name:         "relative mount path",
+			volumes:      []corev1.Volume{{Name: "extra", VolumeSource: emptyDir}},
+			volumeMounts: []corev1.VolumeMount{{Name: "extra", MountPath: "extra"}},
+			expected:     "must be an absolute path",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReconciler(t)
+			r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+			r.kbsConfig.Spec.KbsExtraVolumes = tc.volumes
+			r.kbsConfig.Spec.KbsExtraVolumeMounts = tc.volumeMounts
+			err := r.validateKbsExtraVolumes()
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestAppendKbsExtraVolumesCollisions(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	volumes := []corev1.Volume{{Name: confidentialContainers}}
+	kbsVM := []corev1.VolumeMount{createVolumeMount(confidentialContainers, "/opt/confidential-containers")}
+
+	r.kbsConfig.Spec.KbsExtraVolumes = []corev1.Volume{{Name: confidentialContainers}}
+	if _, _, err := r.appendKbsExtraVolumes(volumes, kbsVM); err == nil {
+		t.Errorf("expected an error for an extra volume colliding with an operator one")
+	}
+
+	r.kbsConfig.Spec.KbsExtraVolumes = []corev1.Volume{{Name: "extra"}}
+	r.kbsConfig.Spec.KbsExtraVolumeMounts = []corev1.VolumeMount{{Name: "extra", MountPath: "/opt/confidential-containers/"}}
+	if _, _, err := r.appendKbsExtraVolumes(volumes, kbsVM); err == nil {
+		t.Errorf("expected an error for an extra volume mount colliding with an operator one")
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// Default branch of the Git repository of the KBS resources
+	defaultGitRef = "main"
+	// Default interval between two syncs of the Git repository
+	defaultGitSyncInterval = time.Minute
+	// Shortest interval between two syncs of the Git repository, to avoid hammering the Git server
+	minGitSyncInterval = 10 * time.Second
+	// Name of the link git-sync points to the current checkout of the repository
+	gitResourcesLink = "repository"
+)
+
+// scpLikeGitURLRegexp matches the scp-like syntax of the SSH Git URLs (user@host:path)
+var scpLikeGitURLRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/].*$`)
+
+// gitSyncResources are the resources of the git-sync containers
+var gitSyncResources = corev1.ResourceRequirements{
+	Requests: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("10m"),
+		corev1.ResourceMemory: resource.MustParse("32Mi"),
+	},
+	Limits: corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("200m"),
+		corev1.ResourceMemory: resource.MustParse("128Mi"),
+	},
+}
+
+// isSshGitRepository returns true if the repository URL uses the SSH transport
+func isSshGitRepository(repository string) bool {
+	return strings.HasPrefix(repository, "ssh://") || scpLikeGitURLRegexp.MatchString(repository)
+}
+
+// gitSyncInterval returns the interval between two syncs of the Git repository
+func (r *KbsConfigReconciler) gitSyncInterval() (time.Duration, error) {
+	git := r.kbsConfig.Spec.KbsGitResources
+	if git.Interval == "" {
+		return defaultGitSyncInterval, nil
+	}
+	interval, err := time.ParseDuration(git.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("KbsGitResources: invalid interval %q: %w", git.Interval, err)
+	}
+	if interval < minGitSyncInterval {
+		return 0, fmt.Errorf("KbsGitResources: invalid interval %q: must be at least %s", git.Interval, minGitSyncInterval)
+	}
+	return interval, nil
+}
+
+// validateKbsGitResources checks the Git repository settings, and that the resources
+// aren't also served by the local secrets or by an external store
+func (r *KbsConfigReconciler) validateKbsGitResources() error {
+	git := r.kbsConfig.Spec.KbsGitResources
+	if git == nil {
+		return nil
+	}
+
+	if len(r.kbsConfig.Spec.KbsSecretResources) > 0 || r.kbsConfig.Spec.KbsSecretResourcesSelector != nil ||
+		len(r.kbsConfig.Spec.KbsSecretResourceMounts) > 0 || len(r.kbsConfig.Spec.KbsResources) > 0 ||
+		r.kbsConfig.Spec.KbsExternalSecretStore != nil {
+		return fmt.Errorf("KbsGitResources is exclusive to KbsSecretResources, KbsSecretResourcesSelector, " +
+			"KbsSecretResourceMounts, KbsResources and KbsExternalSecretStore")
+	}
+
+	if !isSshGitRepository(git.Repository) {
+		repository, err := url.Parse(git.Repository)
+		if err != nil || repository.Scheme != "https" || repository.Host == "" || repository.User != nil {
+			return fmt.Errorf("KbsGitResources: invalid repository %q, an HTTPS or SSH URL without credentials is required",
+				git.Repository)
+		}
+	}
+	if strings.HasPrefix(git.Ref, "-") || strings.ContainsAny(git.Ref, " \t") {
+		return fmt.Errorf("KbsGitResources: invalid ref %q", git.Ref)
+	}
+	if git.CredentialsSecretName != "" {
+		if errs := validation.IsDNS1123Subdomain(git.CredentialsSecretName); len(errs) != 0 {
+			return fmt.Errorf("KbsGitResources: invalid credentials secret name %q: %v", git.CredentialsSecretName, errs)
+		}
+	}
+	_, err := r.gitSyncInterval()
+	return err
+}
+
+// gitResourcesConfigOverrides returns the override pointing the KBS local repository to the synced Git repository
+func (r *KbsConfigReconciler) gitResourcesConfigOverrides() []configOverride {
+	if r.kbsConfig.Spec.KbsGitResources == nil {
+		return nil
+	}
+	return []configOverride{
+		{
+			path: []string{"repository_config"},
+			value: map[string]interface{}{
+				"type":     "LocalFs",
+				"dir_path": filepath.Join(gitResourcesPath, gitResourcesLink),
+			},
+		},
+	}
+}
+
+// gitCredentialKeys returns the keys of the credentials secret required by the repository transport
+func gitCredentialKeys(repository string) []string {
+	if isSshGitRepository(repository) {
+		return []string{"ssh-privatekey", "known_hosts"}
+	}
+	return []string{"username", "password"}
+}
+
+// createGitCredentialsVolume checks that the credentials secret holds the keys required
+// by the repository transport and returns the volume for mounting it
+func (r *KbsConfigReconciler) createGitCredentialsVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	git := r.kbsConfig.Spec.KbsGitResources
+	r.log.Info("Retrieving the Git repository credentials", "Secret.Namespace", r.namespace,
+		"Secret.Name", git.CredentialsSecretName)
+	foundSecret := &corev1.Secret{}
+	err := r.getReferencedObject(ctx, git.CredentialsSecretName, foundSecret)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range gitCredentialKeys(git.Repository) {
+		if len(foundSecret.Data[key]) == 0 {
+			return nil, fmt.Errorf("secret %s misses the %q key required by the Git repository %s",
+				git.CredentialsSecretName, key, git.Repository)
+		}
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: git.CredentialsSecretName,
+				// git-sync requires the SSH key not to be readable by others
+				DefaultMode: pointer(int32(0400)),
+			},
+		},
+	}
+	return &volume, nil
+}
+
+// buildGitSyncContainers returns the init container cloning the Git repository of the KBS resources
+// before KBS starts, and the sidecar container syncing it on the configured interval
+// The repository is checked out in the confidential-containers volume shared with KBS
+func (r *KbsConfigReconciler) buildGitSyncContainers(volumeMounts []corev1.VolumeMount,
+	securityContext *corev1.SecurityContext) (corev1.Container, corev1.Container, error) {
+	imageName, err := getImageName("", "GIT_SYNC_IMAGE_NAME", DefaultGitSyncImageName)
+	if err != nil {
+		return corev1.Container{}, corev1.Container{}, err
+	}
+	interval, err := r.gitSyncInterval()
+	if err != nil {
+		return corev1.Container{}, corev1.Container{}, err
+	}
+
+	git := r.kbsConfig.Spec.KbsGitResources
+	ref := git.Ref
+	if ref == "" {
+		ref = defaultGitRef
+	}
+	args := []string{
+		"--repo=" + git.Repository,
+		"--ref=" + ref,
+		"--root=" + gitResourcesPath,
+		"--link=" + gitResourcesLink,
+		"--period=" + interval.String(),
+	}
+	var env []corev1.EnvVar
+	if git.CredentialsSecretName != "" {
+		if isSshGitRepository(git.Repository) {
+			args = append(args,
+				"--ssh-key-file="+filepath.Join(gitCredentialsPath, "ssh-privatekey"),
+				"--ssh-known-hosts-file="+filepath.Join(gitCredentialsPath, "known_hosts"),
+			)
+		} else {
+			args = append(args, "--password-file="+filepath.Join(gitCredentialsPath, "password"))
+			env = append(env, corev1.EnvVar{
+				Name: "GITSYNC_USERNAME",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: git.CredentialsSecretName},
+						Key:                  "username",
+					},
+				},
+			})
+		}
+	}
+
+	sidecar := corev1.Container{
+		Name:            "git-sync",
+		Image:           imageName,
+		ImagePullPolicy: r.imagePullPolicy(),
+		Args:            args,
+		Env:             env,
+		SecurityContext: securityContext,
+		Resources:       *gitSyncResources.DeepCopy(),
+		VolumeMounts:    volumeMounts,
+	}
+	initContainer := *sidecar.DeepCopy()
+	initContainer.Name = "git-clone"
+	initContainer.Args = append(initContainer.Args, "--one-time")
+	return initContainer, sidecar, nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateKbsHostAliases checks the IP addresses and the hostnames of the host aliases
+func (r *KbsConfigReconciler) validateKbsHostAliases() error {
+	for _, hostAlias := range r.kbsConfig.Spec.KbsHostAliases {
+		if net.ParseIP(hostAlias.IP) == nil {
+			return fmt.Errorf("invalid host alias IP address %q", hostAlias.IP)
+		}
+		if len(hostAlias.Hostnames) == 0 {
+			return fmt.Errorf("host alias %s: missing hostnames", hostAlias.IP)
+		}
+		for _, hostname := range hostAlias.Hostnames {
+			if errs := validation.IsDNS1123Subdomain(hostname); len(errs) != 0 {
+				return fmt.Errorf("host alias %s: invalid hostname %q: %v", hostAlias.IP, hostname, errs)
+			}
+		}
+	}
+	return nil
+}
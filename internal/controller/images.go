@@ -0,0 +1,89 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// getImageName returns the image for a trustee component. The image from the KbsConfig spec
+// takes precedence over the one defined in the operator environment, which in turn takes
+// precedence over the default image
+func getImageName(specImageName string, envVariable string, defaultImageName string) (string, error) {
+	imageName := specImageName
+	if imageName == "" {
+		imageName = os.Getenv(envVariable)
+	}
+	if imageName == "" {
+		imageName = defaultImageName
+	}
+	err := confidentialcontainersorgv1alpha1.ValidateImageName(imageName)
+	if err != nil {
+		return "", err
+	}
+	return imageName, nil
+}
+
+// imagePullPolicy returns the pull policy of the images of the KBS pods, defaulted to IfNotPresent
+func (r *KbsConfigReconciler) imagePullPolicy() corev1.PullPolicy {
+	if r.kbsConfig.Spec.KbsImagePullPolicy == "" {
+		return corev1.PullIfNotPresent
+	}
+	return r.kbsConfig.Spec.KbsImagePullPolicy
+}
+
+// validateKbsImagePullPolicy checks that the image pull policy is one of the Kubernetes ones
+func (r *KbsConfigReconciler) validateKbsImagePullPolicy() error {
+	switch r.kbsConfig.Spec.KbsImagePullPolicy {
+	case "", corev1.PullAlways, corev1.PullNever, corev1.PullIfNotPresent:
+		return nil
+	}
+	return fmt.Errorf("invalid KbsImagePullPolicy %q: must be %s, %s or %s", r.kbsConfig.Spec.KbsImagePullPolicy,
+		corev1.PullAlways, corev1.PullNever, corev1.PullIfNotPresent)
+}
+
+// checkImagePullSecrets checks that the image pull secrets exist, since the kubelet would otherwise
+// silently ignore them and the KBS pods would fail to pull the images from the private registries
+func (r *KbsConfigReconciler) checkImagePullSecrets(ctx context.Context) error {
+	for _, pullSecret := range r.kbsConfig.Spec.KbsImagePullSecrets {
+		err := r.getReferencedObject(ctx, pullSecret.Name, &corev1.Secret{})
+		if err != nil && k8serrors.IsNotFound(err) {
+			r.log.Info("Image pull secret not found", "Secret.Namespace", r.namespace, "Secret.Name", pullSecret.Name)
+			return fmt.Errorf("image pull secret %s/%s not found: %w", r.namespace, pullSecret.Name, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// referencesImagePullSecret returns true if the secret is an image pull secret of the KbsConfig
+func referencesImagePullSecret(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig, secretName string) bool {
+	for _, pullSecret := range kbsConfig.Spec.KbsImagePullSecrets {
+		if pullSecret.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
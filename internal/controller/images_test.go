@@ -0,0 +1,57 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetImageName(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0123456789abcdef", 4)
+	t.Setenv("KBS_IMAGE_NAME", "")
+
+	tests := []struct {
+		name      string
+		imageName string
+		expected  string
+		wantErr   bool
+	}{
+		{"tag only", "quay.io/example/kbs:v0.10.1", "quay.io/example/kbs:v0.10.1", false},
+		{"digest", "quay.io/example/kbs@" + digest, "quay.io/example/kbs@" + digest, false},
+		{"tag and digest", "quay.io/example/kbs:v0.10.1@" + digest, "quay.io/example/kbs:v0.10.1@" + digest, false},
+		{"uppercase digest", "quay.io/example/kbs@" + strings.ToUpper(digest), "", true},
+		{"short digest", "quay.io/example/kbs@" + digest[:len(digest)-1], "", true},
+		{"digest without name", "@" + digest, "", true},
+		{"empty", "", DefaultKbsImageName, false},
+	}
+	for _, tt := range tests {
+		imageName, err := getImageName(tt.imageName, "KBS_IMAGE_NAME", DefaultKbsImageName)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error for %q", tt.name, tt.imageName)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if imageName != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, imageName)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kbsIngressName returns the name of the KBS ingress
+func (r *KbsConfigReconciler) kbsIngressName() string {
+	return r.kbsResourceName(KbsIngressName)
+}
+
+// validateKbsIngress checks the host and the TLS secret name of the KBS ingress
+func (r *KbsConfigReconciler) validateKbsIngress() error {
+	ingress := r.kbsConfig.Spec.KbsIngress
+	if ingress == nil {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(ingress.Host); len(errs) != 0 {
+		return fmt.Errorf("invalid KbsIngress host %q: %v", ingress.Host, errs)
+	}
+	if ingress.TLSSecretName != "" {
+		if errs := validation.IsDNS1123Subdomain(ingress.TLSSecretName); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsIngress TLS secret name %q: %v", ingress.TLSSecretName, errs)
+		}
+	}
+	return nil
+}
+
+// newKbsIngress returns the ingress routing the KbsIngress host to the KBS service
+func (r *KbsConfigReconciler) newKbsIngress() (*networkingv1.Ingress, error) {
+	spec := r.kbsConfig.Spec.KbsIngress
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        r.kbsIngressName(),
+			Namespace:   r.namespace,
+			Annotations: spec.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: spec.Host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: r.kbsServiceName(),
+											Port: networkingv1.ServiceBackendPort{
+												Number: kbsServicePort,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if spec.TLSSecretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{spec.Host},
+				SecretName: spec.TLSSecretName,
+			},
+		}
+	}
+	err := r.setKbsConfigOwner(ingress)
+	if err != nil {
+		return nil, err
+	}
+	return ingress, nil
+}
+
+// deployOrUpdateKbsIngress creates or updates the KBS ingress, or deletes it when KbsIngress is not provided
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsIngress(ctx context.Context) error {
+	if r.kbsConfig.Spec.KbsIngress == nil {
+		return r.deleteKbsIngress(ctx)
+	}
+	ingress, err := r.newKbsIngress()
+	if err != nil {
+		return err
+	}
+	found := &networkingv1.Ingress{}
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(ingress), found)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("Creating the KBS ingress", "Ingress.Namespace", ingress.Namespace, "Ingress.Name", ingress.Name)
+		return r.Client.Create(ctx, ingress)
+	} else if err != nil {
+		return err
+	}
+	r.log.Info("Updating the KBS ingress", "Ingress.Namespace", ingress.Namespace, "Ingress.Name", ingress.Name)
+	found.Annotations = ingress.Annotations
+	found.Spec = ingress.Spec
+	found.OwnerReferences = ingress.OwnerReferences
+	return r.Client.Update(ctx, found)
+}
+
+// deleteKbsIngress deletes the KBS ingress, if it was created by the operator
+func (r *KbsConfigReconciler) deleteKbsIngress(ctx context.Context) error {
+	ingress := &networkingv1.Ingress{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsIngressName()}, ingress)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(ingress, r.kbsConfig) {
+		return nil
+	}
+	r.log.Info("Deleting the KBS ingress", "Ingress.Namespace", r.namespace, "Ingress.Name", ingress.Name)
+	return client.IgnoreNotFound(r.Client.Delete(ctx, ingress))
+}
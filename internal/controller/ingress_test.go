@@ -0,0 +1,158 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+const testKbsIngressName = testKbsConfigName + "-" + KbsIngressName
+
+func TestValidateKbsIngress(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	r.kbsConfig.Spec.KbsIngress = &confidentialcontainersorgv1alpha1.KbsIngress{Host: "kbs.example.com", TLSSecretName: "kbs-tls"}
+	if err := r.validateKbsIngress(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	r.kbsConfig.Spec.KbsIngress.Host = "https://kbs.example.com"
+	if err := r.validateKbsIngress(); err == nil {
+		t.Errorf("expected an error for an invalid host")
+	}
+	r.kbsConfig.Spec.KbsIngress.Host = "kbs.example.com"
+	r.kbsConfig.Spec.KbsIngress.TLSSecretName = "KBS TLS"
+	if err := r.validateKbsIngress(); err == nil {
+		t.Errorf("expected an error for an invalid TLS secret name")
+	}
+}
+
+func TestReconcileKbsIngress(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsIngress = &confidentialcontainersorgv1alpha1.KbsIngress{
+		Host:             "kbs.example.com",
+		IngressClassName: pointer("nginx"),
+		TLSSecretName:    "kbs-tls",
+		Annotations:      map[string]string{"nginx.ingress.kubernetes.io/backend-protocol": "HTTP"},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ingress := &networkingv1.Ingress{}
+	key := client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsIngressName}
+	if err := r.Client.Get(context.TODO(), key, ingress); err != nil {
+		t.Fatalf("getting the KBS ingress: %v", err)
+	}
+	if len(ingress.Spec.Rules) != 1 || ingress.Spec.Rules[0].Host != "kbs.example.com" {
+		t.Fatalf("unexpected ingress rules %v", ingress.Spec.Rules)
+	}
+	backend := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	if backend.Name != testKbsServiceName || backend.Port.Number != getTestService(t, r).Spec.Ports[0].Port {
+		t.Errorf("expected the ingress to route to the KBS service port, got %v", backend)
+	}
+	if ingress.Spec.IngressClassName == nil || *ingress.Spec.IngressClassName != "nginx" {
+		t.Errorf("expected the nginx ingress class, got %v", ingress.Spec.IngressClassName)
+	}
+	if len(ingress.Spec.TLS) != 1 || ingress.Spec.TLS[0].SecretName != "kbs-tls" {
+		t.Errorf("expected the TLS secret in the ingress, got %v", ingress.Spec.TLS)
+	}
+	if ingress.Annotations["nginx.ingress.kubernetes.io/backend-protocol"] != "HTTP" {
+		t.Errorf("expected the annotations on the ingress, got %v", ingress.Annotations)
+	}
+
+	// the finalizer deletes the ingress
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Client.Delete(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Client.Get(context.TODO(), key, ingress); !k8serrors.IsNotFound(err) {
+		t.Errorf("the ingress must be deleted by the finalizer, got %v", err)
+	}
+}
+
+func TestReconcileKbsIngressAccessURL(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsIngress = &confidentialcontainersorgv1alpha1.KbsIngress{Host: "kbs.example.com"}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+	getAccessURL := func() string {
+		if err := reconcileKbsConfig(t, r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+			t.Fatal(err)
+		}
+		return kbsConfig.Status.KbsAccessURL
+	}
+
+	// the ingress doesn't terminate TLS
+	if url := getAccessURL(); url != "http://kbs.example.com" {
+		t.Errorf("expected the ingress host in the access URL, got %q", url)
+	}
+
+	// the ingress terminates TLS
+	kbsConfig.Spec.KbsIngress.TLSSecretName = "kbs-tls"
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if url := getAccessURL(); url != "https://kbs.example.com" {
+		t.Errorf("expected the ingress host over https in the access URL, got %q", url)
+	}
+}
+
+func TestReconcileKbsIngressDisabled(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsIngress = &confidentialcontainersorgv1alpha1.KbsIngress{Host: "kbs.example.com"}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// leaving KbsIngress empty deletes the ingress
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsIngress = nil
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ingress := &networkingv1.Ingress{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsIngressName}, ingress)
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the ingress to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// operatorInitContainerNames are the names of the init containers added by the operator
+var operatorInitContainerNames = []string{"config-validation", "git-clone"}
+
+// validateKbsInitContainers checks that the init containers have valid and unique names,
+// which don't collide with the init containers added by the operator
+func (r *KbsConfigReconciler) validateKbsInitContainers() error {
+	names := map[string]bool{}
+	for _, container := range r.kbsConfig.Spec.KbsInitContainers {
+		if errs := validation.IsDNS1123Label(container.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsInitContainers name %q: %v", container.Name, errs)
+		}
+		if names[container.Name] || contains(operatorInitContainerNames, container.Name) {
+			return fmt.Errorf("duplicated KbsInitContainers name %q", container.Name)
+		}
+		names[container.Name] = true
+		if container.Image == "" {
+			return fmt.Errorf("KbsInitContainers %q has no image", container.Name)
+		}
+	}
+	return nil
+}
+
+// buildKbsInitContainers returns the init containers of the KbsConfig spec, with the KBS storage
+// mounted unless they already mount its volumes or paths, and the hardened security context by default
+func (r *KbsConfigReconciler) buildKbsInitContainers(storageVM []corev1.VolumeMount, securityContext *corev1.SecurityContext) []corev1.Container {
+	var initContainers []corev1.Container
+	for _, container := range r.kbsConfig.Spec.KbsInitContainers {
+		initContainer := *container.DeepCopy()
+		for _, volumeMount := range storageVM {
+			mounted := false
+			for _, existing := range initContainer.VolumeMounts {
+				if existing.Name == volumeMount.Name || existing.MountPath == volumeMount.MountPath {
+					mounted = true
+					break
+				}
+			}
+			if !mounted {
+				initContainer.VolumeMounts = append(initContainer.VolumeMounts, volumeMount)
+			}
+		}
+		if initContainer.SecurityContext == nil {
+			initContainer.SecurityContext = securityContext.DeepCopy()
+		}
+		initContainers = append(initContainers, initContainer)
+	}
+	return initContainers
+}
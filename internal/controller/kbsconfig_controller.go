@@ -18,23 +18,27 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
-	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
@@ -48,16 +52,45 @@ type KbsConfigReconciler struct {
 	kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig
 	log       logr.Logger
 	namespace string
+
+	// ForceApply lets the operator take over the fields of the KBS deployment
+	// which are managed by other controllers
+	ForceApply bool
+
+	// NodeWatchLabel is the label of the attestation-capable nodes. When set, adding or removing
+	// the label from a node triggers the reconciliation of the KbsConfig instances
+	NodeWatchLabel string
+
+	// StartupGate, when set, holds off the reconciliation until the operator startup dependencies are available
+	StartupGate *StartupGate
+
+	// Recorder records the events of the reconciliation on the KbsConfig instances
+	Recorder record.EventRecorder
+
+	// APIReader, when set, reads the referenced ConfigMaps and Secrets from the API server,
+	// bypassing the cache, so that missing RBAC permissions are reported as Forbidden errors
+	APIReader client.Reader
 }
 
 //+kubebuilder:rbac:groups=confidentialcontainers.org,resources=kbsconfigs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=confidentialcontainers.org,resources=kbsconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=confidentialcontainers.org,resources=kbsconfigs/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;update
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get
+//+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -72,8 +105,27 @@ type KbsConfigReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.1/pkg/reconcile
 func (r *KbsConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	// With several operator replicas, the KbsConfig and its resources may be written concurrently
+	// around a leader election failover. The stale writes are retried with a rate-limited requeue
+	if isStaleObjectConflict(err) {
+		r.log.Info("Conflicting concurrent update, requeuing the KbsConfig", "err", err)
+		result, err = ctrl.Result{Requeue: true}, nil
+	}
+	observeReconcile(result, err)
+	return result, err
+}
+
+// reconcile reconciles the KbsConfig instance, its result is recorded in the metrics by Reconcile
+func (r *KbsConfigReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.log.Info("Reconciling KbsConfig")
 
+	// Wait for the startup dependencies, to avoid spurious failures while the cluster is starting
+	if r.StartupGate != nil && !r.StartupGate.IsOpen() {
+		r.log.Info("Waiting for the startup dependencies before reconciling")
+		return ctrl.Result{RequeueAfter: startupGateRequeueAfter}, nil
+	}
+
 	// Get the KbsConfig instance
 	r.kbsConfig = &confidentialcontainersorgv1alpha1.KbsConfig{}
 	err := r.Client.Get(ctx, req.NamespacedName, r.kbsConfig)
@@ -92,6 +144,11 @@ func (r *KbsConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	// KbsConfig instance is found, so continue with rest of the processing
 
+	// The KBS resources are deployed in the namespace of the KbsConfig instance, where the
+	// referenced ConfigMaps and Secrets are looked up too. The resources are owned by the
+	// KbsConfig, and owner references can't cross namespaces
+	r.namespace = r.kbsConfig.Namespace
+
 	// Check if the KbsConfig object is marked to be deleted, which is
 	// indicated by the deletion timestamp being set.
 	isKbsConfigMarkedToBeDeleted := r.kbsConfig.GetDeletionTimestamp() != nil
@@ -99,12 +156,19 @@ func (r *KbsConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		if contains(r.kbsConfig.GetFinalizers(), KbsFinalizerName) {
 			// Run finalization logic for kbsFinalizer. If the
 			// finalization logic fails, don't remove the finalizer so
-			// that we can retry during the next reconciliation.
+			// that we can retry during the next reconciliation, until
+			// the finalization grace period expires.
 			err := r.finalizeKbsConfig(ctx)
-			if err != nil {
+			if err != nil && !r.finalizeGracePeriodExpired() {
 				r.log.Info("Error in finalizeKbsConfig", "err", err)
 				return ctrl.Result{}, err
 			}
+			// Don't block the deletion forever, the owned resources are garbage collected anyway
+			if err != nil {
+				r.log.Info("Giving up finalizeKbsConfig after the grace period", "err", err)
+				r.recordEvent(corev1.EventTypeWarning, "FinalizationAbandoned",
+					"Removing the finalizer after %s despite the cleanup failure: %s", finalizeGracePeriod, err)
+			}
 		}
 		// Remove kbsFinalizer. Once all finalizers have been
 		// removed, the object will be deleted.
@@ -118,130 +182,273 @@ func (r *KbsConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	// Create or update the KBS deployment
-	err = r.deployOrUpdateKbsDeployment(ctx)
+	// In dry-run mode the KBS manifests are only rendered, without deploying anything
+	if r.isDryRun() {
+		err = r.renderKbsDryRun(ctx)
+		if err != nil {
+			r.log.Info("Error in rendering the KBS manifests", "err", err)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Add the kbsFinalizer before any KBS resource gets created
+	err = r.ensureFinalizer(ctx)
 	if err != nil {
-		r.log.Info("Error in creating/updating KBS deployment", "err", err)
+		r.log.Info("Failed to add kbsFinalizer to KbsConfig", "err", err)
 		return ctrl.Result{}, err
 	}
 
-	// Create or update the KBS service
-	err = r.deployOrUpdateKbsService(ctx)
+	// Delete the manifests rendered in dry-run mode, which are stale once the KBS is deployed
+	err = r.deleteKbsDryRunConfigMap(ctx)
 	if err != nil {
-		r.log.Info("Error in creating/updating KBS service", "err", err)
+		r.log.Info("Error in deleting the KBS dry-run ConfigMap", "err", err)
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// Validate the KbsConfig spec
+	err = r.validateKbsConfig()
+	if err != nil {
+		r.log.Info("Invalid KbsConfig", "err", err)
+		r.reportReconcileFailure(ctx, "InvalidSpec", err)
+		return ctrl.Result{}, err
+	}
+
+	// Request the KBS https certificate from cert-manager, the issued secret is checked with the other references
+	err = r.deployOrUpdateKbsCertificate(ctx)
+	if err != nil {
+		r.log.Info("Error in creating/updating KBS https certificate", "err", err)
+		r.reportReconcileFailure(ctx, "CertificateFailed", err)
+		return ctrl.Result{}, err
+	}
+
+	// Check that all the referenced ConfigMaps and Secrets exist, reporting the missing ones at once
+	// The missing ones aren't a failure: the reconciliation is requeued with a backoff until they're created
+	err = r.validateReferences(ctx)
+	var missingErr *missingReferencesError
+	if errors.As(err, &missingErr) {
+		r.log.Info("Waiting for the KbsConfig references", "err", err)
+		return ctrl.Result{RequeueAfter: r.waitForReferences(ctx, err)}, nil
+	}
+	if err != nil {
+		r.log.Info("Error in resolving the KbsConfig references", "err", err)
+		r.reportReconcileFailure(ctx, "InvalidReferences", err)
+		return ctrl.Result{}, err
+	}
+
+	// Adopt the KBS resources created outside of the operator
+	err = r.adoptKbsResources(ctx)
+	if err != nil {
+		r.log.Info("Error in adopting KBS resources", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	// Delete the resources deployed with the names used by the previous versions of the operator
+	err = r.deleteLegacyKbsResources(ctx)
+	if err != nil {
+		r.log.Info("Error in deleting legacy KBS resources", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	// Create, update or delete the KBS resources, in dependency order
+	for _, step := range []struct {
+		resource string
+		reason   string
+		deploy   func(context.Context) error
+	}{
+		// the service account of the KBS pods, unless the KbsConfig provides its own
+		{"service account", "ServiceAccountFailed", r.deployKbsServiceAccount},
+		// the volume claim of the KBS storage from the KbsStorage template
+		{"storage", "StorageFailed", r.deployOrUpdateKbsStorage},
+		{"deployment", "DeploymentFailed", r.deployOrUpdateKbsDeployment},
+		{"autoscaler", "AutoscalerFailed", r.deployOrUpdateKbsAutoscaler},
+		{"service", "ServiceFailed", r.deployOrUpdateKbsService},
+		{"ingress", "IngressFailed", r.deployOrUpdateKbsIngress},
+		// the ServiceMonitor scraping the KBS metrics
+		{"ServiceMonitor", "ServiceMonitorFailed", r.deployOrUpdateKbsServiceMonitor},
+		// the additional KBS service endpoints
+		{"service endpoints", "ServiceEndpointsFailed", r.deployOrUpdateKbsServiceEndpoints},
+		// the KBS metadata ConfigMap
+		{"metadata", "MetadataFailed", r.deployOrUpdateKbsMetadata},
+	} {
+		err = r.deployKbsResource(ctx, step.resource, step.reason, step.deploy)
+		// The namespace teardown deletes the KBS resources, retrying would only spam errors
+		if isNamespaceTerminating(err) {
+			return ctrl.Result{}, nil
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Report the readiness of KBS
+	err = r.updateKbsConfigStatus(ctx)
+	if err != nil {
+		r.log.Info("Error in updating the KbsConfig status", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	// Report a version skew between the trustee component images
+	err = r.updateImageVersionsCondition(ctx)
+	if err != nil {
+		r.log.Info("Error in updating the image versions condition", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	// Retry a deferred rollout at the start of the next maintenance window
+	requeueAfter, err := r.deferredRolloutRequeueAfter()
+	if err != nil {
+		r.log.Info("Error in scheduling the deferred rollout", "err", err)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// deployKbsResource runs a step creating, updating or deleting a KBS resource and reports its failure
+// with the given reason. The failures in a terminating namespace are only logged and returned as is,
+// for the caller to stop the reconciliation without requeuing it
+func (r *KbsConfigReconciler) deployKbsResource(ctx context.Context, resource string, reason string,
+	deploy func(context.Context) error) error {
+	err := deploy(ctx)
+	if err == nil {
+		return nil
+	}
+	if isNamespaceTerminating(err) {
+		r.log.Info("Skipping the KBS "+resource+" reconciliation, the namespace is terminating", "namespace", r.namespace)
+		return err
+	}
+	r.log.Info("Error in creating/updating KBS "+resource, "err", err)
+	r.reportReconcileFailure(ctx, reason, err)
+	return err
 }
 
-// finalizeKbsConfig deletes the KBS deployment
+const (
+	// finalizeTimeout bounds a finalization attempt, so that a stuck API call doesn't hold the reconciliation
+	finalizeTimeout = 30 * time.Second
+	// finalizeGracePeriod is the time after the deletion of the KbsConfig after which its finalizer is
+	// removed even if the cleanup keeps failing. The owned resources are then left to the garbage collector
+	finalizeGracePeriod = 5 * time.Minute
+)
+
+// finalizeKbsConfig deletes the KBS resources in an order that stops the traffic first: the KBS ingress,
+// services and service endpoints, then the KBS deployment, and only then the ConfigMaps and Secrets the
+// KBS pods mount, the KBS metadata and the KBS service account
+// The resources already deleted (e.g. by a previous partial finalization) are skipped
 // Errors are logged by the callee and hence no error is logged in this method
 func (r *KbsConfigReconciler) finalizeKbsConfig(ctx context.Context) error {
-	// Delete the deployment
-	r.log.Info("Deleting the KBS deployment")
-	// Get the KbsDeploymentName deployment
-	deployment := &appsv1.Deployment{}
-	err := r.Client.Get(ctx, client.ObjectKey{
-		Namespace: r.namespace,
-		Name:      KbsDeploymentName,
-	}, deployment)
+	ctx, cancel := context.WithTimeout(ctx, finalizeTimeout)
+	defer cancel()
+
+	err := r.deleteKbsIngress(ctx)
 	if err != nil {
 		return err
 	}
-	err = r.Client.Delete(ctx, deployment)
+
+	err = r.deleteKbsServiceEndpoints(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
-}
 
-// deployOrUpdateKbsService returns a new service for the KBS instance
-// Errors are logged by the callee and hence no error is logged in this method
-func (r *KbsConfigReconciler) deployOrUpdateKbsService(ctx context.Context) error {
-
-	// Check if the service name kbs-service in r.namespace already exists
-	// If it does, update the service
-	// If it does not, create the service
-	found := &corev1.Service{}
+	err = r.deleteKbsService(ctx)
+	if err != nil {
+		return err
+	}
 
-	err := r.Client.Get(ctx, client.ObjectKey{
-		Namespace: r.namespace,
-		Name:      KbsServiceName,
-	}, found)
+	// Delete the deployment
+	r.log.Info("Deleting the KBS deployment")
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Name:      r.kbsDeploymentName(),
+		},
+	}
+	err = client.IgnoreNotFound(r.Client.Delete(ctx, deployment))
+	if err != nil {
+		return err
+	}
 
-	if err != nil && k8serrors.IsNotFound(err) {
-		// Create the service
-		r.log.Info("Creating a new service", "Service.Namespace", r.namespace, "Service.Name", KbsServiceName)
-		service := r.newKbsService(ctx)
-		// If service object is nil, return error
-		if service == nil {
-			// Create an return new error object
-			return fmt.Errorf("failed to get KBS service definition")
-		}
-		err = r.Client.Create(ctx, service)
-		if err != nil {
-			return err
-		}
-		// Service created successfully - return and requeue
-		return nil
-	} else if err != nil {
+	err = r.deleteOwnedConfigMaps(ctx)
+	if err != nil {
 		return err
 	}
 
-	// Service already exists, so update the service
-	r.log.Info("Updating the service", "Service.Namespace", r.namespace, "Service.Name", KbsServiceName)
-	service := r.newKbsService(ctx)
-	// If service object is nil, return error
-	if service == nil {
-		return fmt.Errorf("failed to get KBS service definition")
+	err = r.deleteKbsResourceSecrets(ctx, nil)
+	if err != nil {
+		return err
 	}
-	err = r.Client.Update(ctx, service)
+
+	err = r.deleteKbsMetadata(ctx)
 	if err != nil {
 		return err
 	}
-	// Service updated successfully - ret
-	return nil
+
+	return r.deleteKbsServiceAccount(ctx)
 }
 
-// newKbsService returns a new service for the KBS instance
+// finalizeGracePeriodExpired returns true if the KbsConfig has been deleted for longer than the finalization grace period
+func (r *KbsConfigReconciler) finalizeGracePeriodExpired() bool {
+	deletionTimestamp := r.kbsConfig.GetDeletionTimestamp()
+	return deletionTimestamp != nil && time.Since(deletionTimestamp.Time) > finalizeGracePeriod
+}
+
+// setKbsConfigOwner sets the KbsConfig instance as the owner and controller of an object it manages
+// The owner reference has BlockOwnerDeletion set, so that a foreground deletion of the
+// KbsConfig (kubectl delete --cascade=foreground) waits for the object to be deleted
+func (r *KbsConfigReconciler) setKbsConfigOwner(obj metav1.Object) error {
+	return ctrl.SetControllerReference(r.kbsConfig, obj, r.Scheme)
+}
+
+// deployOrUpdateKbsService creates or updates the service for the KBS instance
 // Errors are logged by the callee and hence no error is logged in this method
-func (r *KbsConfigReconciler) newKbsService(ctx context.Context) *corev1.Service {
-	// Get the service type from the KbsConfig instance
-	serviceType := r.kbsConfig.Spec.KbsServiceType
-	// if the service type is not provided, default to ClusterIP
-	if serviceType == "" {
-		serviceType = corev1.ServiceTypeClusterIP
+func (r *KbsConfigReconciler) deployOrUpdateKbsService(ctx context.Context) error {
+	service, err := r.newKbsService(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get KBS service definition: %w", err)
 	}
+	return r.createOrUpdateService(ctx, service)
+}
+
+// newKbsService returns a new service for the KBS instance
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) newKbsService(ctx context.Context) (*corev1.Service, error) {
+	serviceType := r.kbsServiceType()
 
 	// Create a new service
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: r.namespace,
-			Name:      KbsServiceName,
+			Name:      r.kbsServiceName(),
+			// The labels let a ServiceMonitor select the KBS service
+			Labels: r.kbsPodLabels(),
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: map[string]string{
-				"app": "kbs",
-			},
-			Type: serviceType,
+			Selector: r.kbsPodLabels(),
+			Type:     serviceType,
 			Ports: []corev1.ServicePort{
 				{
 					Name:       "kbs-port",
 					Protocol:   corev1.ProtocolTCP,
-					Port:       8080,
-					TargetPort: intstr.FromInt(8080),
+					Port:       kbsServicePort,
+					TargetPort: intstr.FromInt(kbsServicePort),
+					NodePort:   r.kbsConfig.Spec.KbsNodePort,
 				},
 			},
 		},
 	}
+	service.Spec.Ports = append(service.Spec.Ports, r.kbsMetricsServicePorts()...)
+	service.Spec.Ports = append(service.Spec.Ports, r.kbsExtraServicePorts()...)
+	// The load balancer settings are only meaningful for the LoadBalancer services
+	if serviceType == corev1.ServiceTypeLoadBalancer {
+		service.Annotations = r.kbsConfig.Spec.KbsServiceAnnotations
+		service.Spec.LoadBalancerSourceRanges = r.kbsConfig.Spec.KbsLoadBalancerSourceRanges
+	}
 	// Set KbsConfig instance as the owner and controller
-	err := ctrl.SetControllerReference(r.kbsConfig, service, r.Scheme)
+	err := r.setKbsConfigOwner(service)
 	if err != nil {
-		r.log.Info("Error in setting the controller reference for the KBS service", "err", err)
-		return nil
+		return nil, err
 	}
-	return service
+	return service, nil
 }
 
 // deployOrUpdateKbsDeployment returns a new deployment for the KBS instance
@@ -255,42 +462,69 @@ func (r *KbsConfigReconciler) deployOrUpdateKbsDeployment(ctx context.Context) e
 
 	err := r.Client.Get(ctx, client.ObjectKey{
 		Namespace: r.namespace,
-		Name:      KbsDeploymentName,
+		Name:      r.kbsDeploymentName(),
 	}, found)
 
 	if err != nil && k8serrors.IsNotFound(err) {
 		// Create the deployment
-		r.log.Info("Creating a new deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", KbsDeploymentName)
+		r.log.Info("Creating a new deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", r.kbsDeploymentName())
 		deployment, err := r.newKbsDeployment(ctx)
 		if err != nil {
 			return err
 		}
-		err = r.Client.Create(ctx, deployment)
+		// The autoscaler takes over the replicas once the deployment starts with its minimum
+		if autoscaling := r.kbsConfig.Spec.KbsAutoscaling; autoscaling != nil {
+			minReplicas := autoscalingMinReplicas(autoscaling.MinReplicas)
+			deployment.Spec.Replicas = &minReplicas
+		}
+		err = r.Client.Create(ctx, deployment, client.FieldOwner(FieldManager))
 		if err != nil {
 			return err
-		} else {
-			// Deployment created successfully
-			r.log.Info("Created a new deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", KbsDeploymentName)
-			// Add the kbsFinalizer to the KbsConfig if it doesn't already exist
-			return r.addKbsConfigFinalizer(ctx)
 		}
+		// Deployment created successfully
+		r.log.Info("Created a new deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", r.kbsDeploymentName())
+		r.recordEvent(corev1.EventTypeNormal, eventReasonDeploymentCreated, "Created deployment %s", deployment.Name)
+		deploymentOperationsTotal.WithLabelValues(deploymentOperationCreate).Inc()
+		return nil
 	} else if err != nil {
 		// Unknown error
 		return err
 	}
-	// Update the found deployment and write the result back if there are any changes
-	err = r.updateKbsDeployment(ctx, found)
+	// Apply the desired state to the found deployment
+	deployment, err := r.newKbsDeployment(ctx)
 	if err != nil {
 		return err
-	} else {
-		// Deployment updated successfully
-		r.log.Info("Updated Deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", KbsDeploymentName)
 	}
-
+	// Outside of the maintenance windows, the changes requiring a rollout are deferred
+	// while the other ones (e.g. the replicas) are applied
+	deferred, err := r.deferRollout(ctx, found, deployment)
+	if err != nil {
+		return err
+	}
+	if deferred {
+		keepPodTemplate(found, deployment)
+	}
+	// Skip the update of an up to date deployment, which would only churn it
+	if !r.kbsDeploymentChanged(found, deployment) {
+		return nil
+	}
+	err = r.updateKbsDeployment(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	deploymentOperationsTotal.WithLabelValues(deploymentOperationUpdate).Inc()
+	if found.Annotations[podTemplateHashAnnotation] != deployment.Annotations[podTemplateHashAnnotation] {
+		r.recordEvent(corev1.EventTypeNormal, eventReasonDeploymentUpdated, "Updated deployment %s, rolling out the KBS pods", deployment.Name)
+	} else if deployment.Spec.Replicas != nil && !equality.Semantic.DeepEqual(found.Spec.Replicas, deployment.Spec.Replicas) {
+		r.recordEvent(corev1.EventTypeNormal, eventReasonDeploymentUpdated, "Scaled deployment %s to %d replicas",
+			deployment.Name, *deployment.Spec.Replicas)
+	}
 	return nil
 }
 
-func (r *KbsConfigReconciler) addKbsConfigFinalizer(ctx context.Context) error {
+// ensureFinalizer adds the kbsFinalizer to the KbsConfig if it doesn't already exist, so that
+// the KBS resources are cleaned up even if the KbsConfig is deleted right after its creation
+func (r *KbsConfigReconciler) ensureFinalizer(ctx context.Context) error {
 	if !contains(r.kbsConfig.GetFinalizers(), KbsFinalizerName) {
 		r.log.Info("Adding kbsFinalizer to KbsConfig")
 		r.kbsConfig.SetFinalizers(append(r.kbsConfig.GetFinalizers(), KbsFinalizerName))
@@ -304,26 +538,24 @@ func (r *KbsConfigReconciler) addKbsConfigFinalizer(ctx context.Context) error {
 
 // newKbsDeployment returns a new deployment for the KBS instance
 func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Deployment, error) {
-	// Set replica count
+	// Set replica count, defaulted to 1
 	replicas := int32(1)
-	// Set rolling update strategy
-	rollingUpdate := &appsv1.RollingUpdateDeployment{
-		MaxUnavailable: &intstr.IntOrString{
-			Type:   intstr.Int,
-			IntVal: 1,
-		},
+	if r.kbsConfig.Spec.KbsReplicas != nil {
+		replicas = *r.kbsConfig.Spec.KbsReplicas
 	}
-	// Set labels
-	labels := map[string]string{
-		"app": "kbs",
-	}
-
-	// deployment type defaulted to microservices
-	kbsDeploymentType := r.kbsConfig.Spec.KbsDeploymentType
-	if kbsDeploymentType == "" {
-		kbsDeploymentType = confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices
+	// With autoscaling the replica count is owned by the HPA and never forced on the deployment,
+	// the pods are spread as for the maximum number of replicas
+	deploymentReplicas := &replicas
+	spreadReplicas := replicas
+	if autoscaling := r.kbsConfig.Spec.KbsAutoscaling; autoscaling != nil {
+		deploymentReplicas = nil
+		spreadReplicas = autoscaling.MaxReplicas
 	}
+	// Set labels
+	labels := r.kbsPodLabels()
 
+	// Resolution of the referenced resources and build of the volumes
+	volumesStart := time.Now()
 	var volumes []corev1.Volume
 	var kbsVM []corev1.VolumeMount
 	var asVM []corev1.VolumeMount
@@ -332,6 +564,7 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 	// The paths /opt/confidential-container and /opt/confidential-container/kbs/repository/default
 	// are mounted as a RW volume in memory to allow trustee components
 	// to have full access to the filesystem
+	// With a persistent KBS storage, the default repository is part of the persisted volume
 	// confidential-containers
 	volume, err := r.createConfidentialContainersVolume(confidentialContainers)
 	if err != nil {
@@ -341,12 +574,24 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 	volumeMount := createVolumeMount(volume.Name, filepath.Join(rootPath, volume.Name))
 	kbsVM = append(kbsVM, volumeMount)
 	// default repo
-	volume, err = r.createDefaultRepositoryVolume(defaultRepository)
+	if r.kbsStorageClaimName() == "" {
+		volume, err = r.createDefaultRepositoryVolume(defaultRepository)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, filepath.Join(repositoryPath, volume.Name))
+		kbsVM = append(kbsVM, volumeMount)
+	}
+	// the KBS storage is shared with the init containers of the KbsConfig spec
+	storageVM := append([]corev1.VolumeMount{}, kbsVM...)
+	// tmp, writable under the read-only root filesystem
+	volume, err = r.createTmpVolume("tmp")
 	if err != nil {
 		return nil, err
 	}
 	volumes = append(volumes, *volume)
-	volumeMount = createVolumeMount(volume.Name, filepath.Join(repositoryPath, volume.Name))
+	volumeMount = createVolumeMount(volume.Name, tmpPath)
 	kbsVM = append(kbsVM, volumeMount)
 
 	// kbs-config
@@ -354,7 +599,7 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 	if err != nil {
 		return nil, err
 	}
-	volumeMount = createVolumeMount(volume.Name, filepath.Join(kbsDefaultConfigPath, volume.Name))
+	volumeMount = createVolumeMount(volume.Name, r.kbsConfigMountPath())
 	volumes = append(volumes, *volume)
 	kbsVM = append(kbsVM, volumeMount)
 
@@ -367,6 +612,14 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 	volumeMount = createVolumeMount(volume.Name, filepath.Join(kbsDefaultConfigPath, volume.Name))
 	kbsVM = append(kbsVM, volumeMount)
 
+	// additional auth secrets
+	authSecretsVolumes, authSecretsVM, err := r.createAuthSecretsVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumes = append(volumes, authSecretsVolumes...)
+	kbsVM = append(kbsVM, authSecretsVM...)
+
 	// https
 	// TBD: Make https as must going forward
 	if r.isHttpsConfigPresent() {
@@ -394,34 +647,83 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 	}
 	volumes = append(volumes, kbsSecretVolumes...)
 	for _, vol := range kbsSecretVolumes {
-		volumeMount = createVolumeMount(vol.Name, filepath.Join(kbsResourcesPath, vol.Name))
+		volumeMount = createVolumeMount(vol.Name, filepath.Join(r.kbsSecretResourcesMountPath(), vol.Name))
 		kbsVM = append(kbsVM, volumeMount)
 	}
 
-	// reference-values
-	volume, err = r.createRvpsRefValuesConfigMapVolume(ctx, "reference-values")
+	// kbs secret resources with a sub-path and key-to-path mapping
+	secretResourceMountsVolumes, secretResourceMountsVM, err := r.createKbsSecretResourceMountsVolumes(ctx)
 	if err != nil {
 		return nil, err
 	}
-	volumes = append(volumes, *volume)
-	volumeMount = createVolumeMount(volume.Name, filepath.Join(rvpsReferenceValuesPath, volume.Name))
+	volumes = append(volumes, secretResourceMountsVolumes...)
+	kbsVM = append(kbsVM, secretResourceMountsVM...)
 
-	// For the DeploymentTypeAllInOne case, if reference-values.json file is provided must be mounted in kbs
-	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+	// kbs resources declared in the spec
+	kbsResourcesVolumes, kbsResourcesVM, err := r.createKbsResourcesVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumes = append(volumes, kbsResourcesVolumes...)
+	kbsVM = append(kbsVM, kbsResourcesVM...)
+
+	// external secret store credentials
+	if r.kbsConfig.Spec.KbsExternalSecretStore != nil {
+		volume, err = r.createSecretStoreCredentialsVolume(ctx, "secret-store")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, secretStoreCredentialsPath)
 		kbsVM = append(kbsVM, volumeMount)
-	} else {
-		rvpsVM = append(rvpsVM, volumeMount)
+	}
+
+	// git-credentials
+	// The Git repository of the KBS resources is checked out in the confidential-containers volume
+	var gitSyncVM []corev1.VolumeMount
+	if r.kbsConfig.Spec.KbsGitResources != nil {
+		gitSyncVM = append(gitSyncVM, createVolumeMount(confidentialContainers, confidentialContainersPath),
+			createVolumeMount("tmp", tmpPath))
+		if r.kbsConfig.Spec.KbsGitResources.CredentialsSecretName != "" {
+			volume, err = r.createGitCredentialsVolume(ctx, "git-credentials")
+			if err != nil {
+				return nil, err
+			}
+			volumes = append(volumes, *volume)
+			volumeMount = createVolumeMount(volume.Name, gitCredentialsPath)
+			gitSyncVM = append(gitSyncVM, volumeMount)
+		}
+	}
 
-		// as-config
+	// reference-values
+	// For the DeploymentTypeAllInOne case, if reference-values.json file is provided must be mounted in kbs
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne || r.runsRvps() {
+		volume, err = r.createRvpsRefValuesConfigMapVolume(ctx, "reference-values")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, filepath.Join(rvpsReferenceValuesPath, volume.Name))
+		if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+			kbsVM = append(kbsVM, volumeMount)
+		} else {
+			rvpsVM = append(rvpsVM, volumeMount)
+		}
+	}
+
+	// as-config
+	if r.runsAs() {
 		volume, err = r.createAsConfigMapVolume(ctx, "as-config")
 		if err != nil {
 			return nil, err
 		}
 		volumes = append(volumes, *volume)
-		volumeMount = createVolumeMount(volume.Name, filepath.Join(asDefaultConfigPath, volume.Name))
+		volumeMount = createVolumeMount(volume.Name, r.asConfigMountPath())
 		asVM = append(asVM, volumeMount)
+	}
 
-		// rvps-config
+	// rvps-config
+	if r.runsRvps() {
 		volume, err = r.processRvpsConfigMapVolume(ctx, "rvps-config")
 		if err != nil {
 			return nil, err
@@ -431,47 +733,231 @@ func (r *KbsConfigReconciler) newKbsDeployment(ctx context.Context) (*appsv1.Dep
 		rvpsVM = append(rvpsVM, volumeMount)
 	}
 
-	securityContext := createSecurityContext()
-	containers := []corev1.Container{r.buildKbsContainer(kbsVM, securityContext)}
+	// attestation-policy
+	// The policy is evaluated by the AS, which is part of KBS for the DeploymentTypeAllInOne case
+	if r.kbsConfig.Spec.KbsAttestationPolicy != "" && !r.usesExternalAs() {
+		volume, err = r.createAttestationPolicyVolume(ctx, "attestation-policy")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, attestationPolicyPath)
+		if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+			kbsVM = append(kbsVM, volumeMount)
+		} else {
+			asVM = append(asVM, volumeMount)
+		}
+	}
 
-	if kbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices {
+	// resource-policy
+	// The resource policy is evaluated by KBS, whatever the deployment type
+	if len(r.kbsConfig.Spec.KbsResourcePolicyRules) > 0 {
+		volume, err = r.createResourcePolicyVolume(ctx, "resource-policy")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, resourcePolicyPath)
+		kbsVM = append(kbsVM, volumeMount)
+	}
+	if r.kbsConfig.Spec.KbsPolicyConfigMapName != "" {
+		volume, err = r.processKbsPolicyConfigMapVolume(ctx, "resource-policy")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, resourcePolicyPath)
+		kbsVM = append(kbsVM, volumeMount)
+	}
+
+	// audit-log
+	if r.auditLogSink() == confidentialcontainersorgv1alpha1.AuditLogSinkFile {
+		volume, err = r.createAuditLogVolume("audit-log")
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, *volume)
+		volumeMount = createVolumeMount(volume.Name, auditLogPath)
+		kbsVM = append(kbsVM, volumeMount)
+	}
+
+	// extra volumes, not modeled by the operator
+	volumes, kbsVM, err = r.appendKbsExtraVolumes(volumes, kbsVM)
+	if err != nil {
+		return nil, err
+	}
+
+	// image pull secrets
+	err = r.checkImagePullSecrets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roll out the pods when the contents of the mounted ConfigMaps and Secrets change
+	configChecksum, err := r.configChecksum(ctx, volumes)
+	if err != nil {
+		return nil, err
+	}
+
+	observeResolution(resourceKindVolumes, volumesStart)
+
+	// The AS and the RVPS write in the paths shipped with their images, hence they keep
+	// a writable root filesystem
+	securityContext := r.containerSecurityContext(true)
+	trusteeSecurityContext := r.containerSecurityContext(false)
+	kbsContainer, err := r.buildKbsContainer(kbsVM, securityContext)
+	if err != nil {
+		return nil, err
+	}
+	containers := []corev1.Container{kbsContainer}
+
+	// The AS and the RVPS are not deployed when KBS is pointed at external ones
+	if r.runsAs() {
 		// build AS container
-		containers = append(containers, r.buildAsContainer(asVM, securityContext))
+		asContainer, err := r.buildAsContainer(asVM, trusteeSecurityContext)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, asContainer)
+	}
+	if r.runsRvps() {
 		// build RVPS container
-		containers = append(containers, r.buildRvpsContainer(rvpsVM, securityContext))
+		rvpsContainer, err := r.buildRvpsContainer(rvpsVM, trusteeSecurityContext)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, rvpsContainer)
+	}
+
+	// Validate the configuration files before starting the trustee containers
+	var initContainers []corev1.Container
+	if r.configValidationEnabled() {
+		configValidationContainer, err := r.buildConfigValidationContainer(securityContext)
+		if err != nil {
+			return nil, err
+		}
+		initContainers = append(initContainers, configValidationContainer)
 	}
 
+	// Set the number of worker threads and the additional environment variables of every container
+	for i := range containers {
+		setWorkerThreads(&containers[i], r.kbsConfig.Spec.KbsWorkerThreads)
+		setEnvVars(&containers[i], r.kbsConfig.Spec.KbsEnvVars)
+	}
+
+	// Clone the Git repository of the KBS resources before starting KBS, and keep it synced
+	if r.kbsConfig.Spec.KbsGitResources != nil {
+		gitCloneContainer, gitSyncContainer, err := r.buildGitSyncContainers(gitSyncVM, securityContext)
+		if err != nil {
+			return nil, err
+		}
+		initContainers = append(initContainers, gitCloneContainer)
+		containers = append(containers, gitSyncContainer)
+	}
+
+	// Run the init containers of the KbsConfig spec, e.g. seeding the KBS storage
+	initContainers = append(initContainers, r.buildKbsInitContainers(storageVM, securityContext)...)
+
+	// termination grace period, extended by the drain timeout
+	terminationGracePeriodSeconds, err := r.terminationGracePeriodSeconds()
+	if err != nil {
+		return nil, err
+	}
+
+	// runtime class
+	// The pod overhead of the runtime class is set on the pods by the RuntimeClass admission controller,
+	// which rejects any other value, hence it's accounted for but never set by the operator
+	runtimeClassName := r.kbsConfig.Spec.KbsRuntimeClassName
+	if runtimeClassName != nil {
+		runtimeClass := r.checkRuntimeClass(ctx, *runtimeClassName)
+		if runtimeClass != nil && runtimeClass.Overhead != nil {
+			r.log.Info("The RuntimeClass adds a pod overhead to the KBS pods", "RuntimeClass.Name", *runtimeClassName,
+				"overhead", runtimeClass.Overhead.PodFixed,
+				"requests", podSchedulingRequests(initContainers, containers, runtimeClass.Overhead.PodFixed))
+		}
+	}
+
+	if r.kbsConfig.Spec.KbsPriorityClassName != "" {
+		r.checkPriorityClass(ctx, r.kbsConfig.Spec.KbsPriorityClassName)
+	}
+
+	podAnnotations := r.appArmorAnnotations(initContainers, containers)
+	if podAnnotations == nil {
+		podAnnotations = map[string]string{}
+	}
+	podAnnotations[configChecksumAnnotation] = configChecksum
+
 	// Create the deployment
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      KbsDeploymentName,
+			Name:      r.kbsDeploymentName(),
 			Namespace: r.namespace,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas: deploymentReplicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
-			Strategy: appsv1.DeploymentStrategy{
-				RollingUpdate: rollingUpdate,
-				Type:          appsv1.RollingUpdateDeploymentStrategyType,
-			},
+			Strategy: r.kbsDeploymentStrategy(),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      labels,
+					Annotations: podAnnotations,
 				},
 				// Add the KBS container
 				Spec: corev1.PodSpec{
-					Containers: containers,
+					RuntimeClassName:              runtimeClassName,
+					SecurityContext:               r.podSecurityContext(),
+					Affinity:                      r.kbsAffinity(spreadReplicas, labels),
+					TopologySpreadConstraints:     r.kbsTopologySpreadConstraints(labels),
+					NodeSelector:                  r.kbsConfig.Spec.KbsNodeSelector,
+					Tolerations:                   r.kbsConfig.Spec.KbsTolerations,
+					HostAliases:                   r.kbsConfig.Spec.KbsHostAliases,
+					SchedulerName:                 r.kbsConfig.Spec.KbsSchedulerName,
+					PriorityClassName:             r.kbsConfig.Spec.KbsPriorityClassName,
+					ServiceAccountName:            r.kbsServiceAccountName(),
+					ImagePullSecrets:              r.kbsConfig.Spec.KbsImagePullSecrets,
+					TerminationGracePeriodSeconds: terminationGracePeriodSeconds,
+					InitContainers:                initContainers,
+					Containers:                    containers,
 					// Add volumes
 					Volumes: volumes,
 				},
 			},
 		},
 	}
+	// Track the changes of the pod template, which trigger a rollout
+	templateHash, err := podTemplateHash(&deployment.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	deployment.Annotations = map[string]string{
+		podTemplateHashAnnotation: templateHash,
+	}
+	// Set KbsConfig instance as the owner and controller
+	err = r.setKbsConfigOwner(deployment)
+	if err != nil {
+		return nil, err
+	}
 	return deployment, nil
 }
 
+// checkRuntimeClass returns the RuntimeClass and logs a warning if it doesn't exist, since
+// the KBS pods can't be started until it gets created
+func (r *KbsConfigReconciler) checkRuntimeClass(ctx context.Context, runtimeClassName string) *nodev1.RuntimeClass {
+	runtimeClass := &nodev1.RuntimeClass{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: runtimeClassName}, runtimeClass)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("WARNING: the RuntimeClass doesn't exist, the KBS pods won't start until it's created",
+			"RuntimeClass.Name", runtimeClassName)
+		return nil
+	} else if err != nil {
+		r.log.Info("Unable to check the RuntimeClass", "RuntimeClass.Name", runtimeClassName, "err", err)
+		return nil
+	}
+	return runtimeClass
+}
+
 func pointer[T any](d T) *T {
 	return &d
 }
@@ -489,42 +975,60 @@ func createSecurityContext() *corev1.SecurityContext {
 	}
 }
 
-func (r *KbsConfigReconciler) buildAsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) corev1.Container {
-	asImageName := os.Getenv("AS_IMAGE_NAME")
-	if asImageName == "" {
-		asImageName = DefaultAsImageName
+// tcpReadinessProbe returns the probe reporting a container ready once it accepts connections on the port
+func tcpReadinessProbe(port int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt(int(port)),
+			},
+		},
+		PeriodSeconds: 5,
+	}
+}
+
+func (r *KbsConfigReconciler) buildAsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) (corev1.Container, error) {
+	asImageName, err := getImageName(r.kbsConfig.Spec.KbsAsImageName, "AS_IMAGE_NAME", DefaultAsImageName)
+	if err != nil {
+		return corev1.Container{}, err
 	}
 
 	// command array for the Attestation Server container
 	asCommand := []string{
 		"/usr/local/bin/grpc-as",
 		"--socket",
-		"0.0.0.0:50004",
+		r.asSocket(),
 		"--config-file",
-		"/etc/as-config/as-config.json",
+		filepath.Join(r.asConfigMountPath(), "as-config.json"),
 	}
 
 	return corev1.Container{
-		Name:  "as",
-		Image: asImageName,
+		Name:            "as",
+		Image:           asImageName,
+		ImagePullPolicy: r.imagePullPolicy(),
 		Ports: []corev1.ContainerPort{
 			{
-				ContainerPort: 50004,
+				ContainerPort: r.asListenPort(),
 				Name:          "as",
 			},
 		},
 		// Add command to start AS
-		Command:         asCommand,
+		Command:         overrideCommand(asCommand, r.kbsConfig.Spec.KbsAsCommand),
+		Args:            r.kbsConfig.Spec.KbsAsArgs,
 		SecurityContext: securityContext,
+		Resources:       r.containerResources("as"),
+		// KBS is only ready once AS accepts the connections
+		ReadinessProbe: tcpReadinessProbe(r.asListenPort()),
 		// Add volume mount for config
 		VolumeMounts: volumeMounts,
-	}
+		Env:          r.logLevelEnv(),
+	}, nil
 }
 
-func (r *KbsConfigReconciler) buildRvpsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) corev1.Container {
-	rvpsImageName := os.Getenv("RVPS_IMAGE_NAME")
-	if rvpsImageName == "" {
-		rvpsImageName = DefaultRvpsImageName
+func (r *KbsConfigReconciler) buildRvpsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) (corev1.Container, error) {
+	rvpsImageName, err := getImageName(r.kbsConfig.Spec.KbsRvpsImageName, "RVPS_IMAGE_NAME", DefaultRvpsImageName)
+	if err != nil {
+		return corev1.Container{}, err
 	}
 
 	// command array for the RVPS container
@@ -533,96 +1037,174 @@ func (r *KbsConfigReconciler) buildRvpsContainer(volumeMounts []corev1.VolumeMou
 		"-c",
 		"/etc/rvps-config/rvps-config.json",
 	}
+	rvpsCommand = append(rvpsCommand, r.rvpsSocketArgs()...)
 
 	return corev1.Container{
-		Name:  "rvps",
-		Image: rvpsImageName,
+		Name:            "rvps",
+		Image:           rvpsImageName,
+		ImagePullPolicy: r.imagePullPolicy(),
 		Ports: []corev1.ContainerPort{
 			{
-				ContainerPort: 50003,
+				ContainerPort: r.rvpsListenPort(),
 				Name:          "rvps",
 			},
 		},
 		// Add command to start RVPS
-		Command:         rvpsCommand,
+		Command:         overrideCommand(rvpsCommand, r.kbsConfig.Spec.KbsRvpsCommand),
+		Args:            r.kbsConfig.Spec.KbsRvpsArgs,
 		SecurityContext: securityContext,
+		Resources:       r.containerResources("rvps"),
+		// KBS is only ready once RVPS accepts the connections
+		ReadinessProbe: tcpReadinessProbe(r.rvpsListenPort()),
 		// Add volume mount for config
 		VolumeMounts: volumeMounts,
-	}
+		Env:          r.logLevelEnv(),
+	}, nil
 }
 
-func (r *KbsConfigReconciler) buildKbsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) corev1.Container {
-	// Get Image Name from env variable if set
-	imageName := os.Getenv("KBS_IMAGE_NAME")
-	if imageName == "" {
-		imageName = DefaultKbsImageName
+func (r *KbsConfigReconciler) buildKbsContainer(volumeMounts []corev1.VolumeMount, securityContext *corev1.SecurityContext) (corev1.Container, error) {
+	// Get Image Name from the spec or from env variable if set
+	imageName, err := getImageName(r.kbsConfig.Spec.KbsImageName, "KBS_IMAGE_NAME", DefaultKbsImageName)
+	if err != nil {
+		return corev1.Container{}, err
 	}
 
 	// command array for the KBS container
 	command := []string{
 		"/usr/local/bin/kbs",
 		"--config-file",
-		"/etc/kbs-config/kbs-config.json",
+		filepath.Join(r.kbsConfigMountPath(), "kbs-config.json"),
 	}
 
-	return corev1.Container{
-		Name:  "kbs",
-		Image: imageName,
-		Ports: []corev1.ContainerPort{
-			{
-				ContainerPort: 8080,
-				Name:          "kbs",
-			},
+	ports := []corev1.ContainerPort{
+		{
+			ContainerPort: kbsServicePort,
+			Name:          "kbs",
 		},
+	}
+	if metricsPort := r.kbsMetricsPort(); metricsPort != 0 {
+		ports = append(ports, corev1.ContainerPort{
+			ContainerPort: metricsPort,
+			Name:          "kbs-metrics",
+		})
+	}
+	ports = append(ports, r.kbsExtraContainerPorts()...)
+
+	preStop, err := r.kbsPreStopHandler()
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	var lifecycle *corev1.Lifecycle
+	if preStop != nil {
+		lifecycle = &corev1.Lifecycle{PreStop: preStop}
+	}
+
+	return corev1.Container{
+		Name:            "kbs",
+		Image:           imageName,
+		ImagePullPolicy: r.imagePullPolicy(),
+		Ports:           ports,
 		// Add command to start KBS
-		Command:         command,
+		Command:         overrideCommand(command, r.kbsConfig.Spec.KbsCommand),
+		Args:            r.kbsConfig.Spec.KbsArgs,
 		SecurityContext: securityContext,
+		Resources:       r.containerResources("kbs"),
+		// Drain the in-flight sessions before stopping
+		Lifecycle: lifecycle,
 		// Add volume mount for KBS config
 		VolumeMounts: volumeMounts,
-		/* TODO commented out because not configurable yet
-		Env: []corev1.EnvVar{
-			{
-				Name:  "RUST_LOG",
-				Value: "debug",
-			},
-		},
-		*/
-	}
+		Env:          r.logLevelEnv(),
+	}, nil
 }
 
 func (r *KbsConfigReconciler) isHttpsConfigPresent() bool {
 	if r.kbsConfig.Spec.KbsHttpsKeySecretName != "" && r.kbsConfig.Spec.KbsHttpsCertSecretName != "" {
 		return true
 	}
-	return false
+	return r.kbsConfig.Spec.KbsHttpsCertManager != nil
+}
+
+// kbsDeploymentChanged returns true if the found deployment differs from the desired one on the fields
+// set by the operator. The pod template is compared through its hash, so that the fields defaulted
+// by Kubernetes don't cause spurious updates
+func (r *KbsConfigReconciler) kbsDeploymentChanged(found *appsv1.Deployment, desired *appsv1.Deployment) bool {
+	if found.Annotations[podTemplateHashAnnotation] != desired.Annotations[podTemplateHashAnnotation] {
+		return true
+	}
+	if desired.Spec.Replicas != nil && !equality.Semantic.DeepEqual(found.Spec.Replicas, desired.Spec.Replicas) {
+		return true
+	}
+	if found.Spec.Strategy.Type != desired.Spec.Strategy.Type ||
+		(found.Spec.Strategy.RollingUpdate == nil) != (desired.Spec.Strategy.RollingUpdate == nil) {
+		return true
+	}
+	if desired.Spec.Strategy.RollingUpdate != nil {
+		foundRollingUpdate, desiredRollingUpdate := found.Spec.Strategy.RollingUpdate, desired.Spec.Strategy.RollingUpdate
+		if rollingUpdateParameter(foundRollingUpdate.MaxUnavailable) != rollingUpdateParameter(desiredRollingUpdate.MaxUnavailable) ||
+			rollingUpdateParameter(foundRollingUpdate.MaxSurge) != rollingUpdateParameter(desiredRollingUpdate.MaxSurge) {
+			return true
+		}
+	}
+	return !metav1.IsControlledBy(found, r.kbsConfig)
 }
 
-// updateKbsDeployment updates an existing deployment for the KBS instance
+// rollingUpdateParameter returns the value of a rolling update parameter, defaulted to 25% by Kubernetes
+func rollingUpdateParameter(value *intstr.IntOrString) intstr.IntOrString {
+	if value == nil {
+		return intstr.FromString("25%")
+	}
+	return *value
+}
+
+// updateKbsDeployment converges the existing deployment for the KBS instance to the desired state
+// with a server-side apply. The fields managed by other controllers are taken over only when
+// ForceApply is set, otherwise the conflicting fields are logged and an error is returned
 // Errors are logged by the callee and hence no error is logged in this method
 func (r *KbsConfigReconciler) updateKbsDeployment(ctx context.Context, deployment *appsv1.Deployment) error {
+	deployment.TypeMeta = metav1.TypeMeta{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+	}
+	patchOptions := []client.PatchOption{client.FieldOwner(FieldManager)}
+	if r.ForceApply {
+		patchOptions = append(patchOptions, client.ForceOwnership)
+	}
 
-	err := r.Client.Update(ctx, deployment)
-	if err != nil {
+	err := r.Client.Patch(ctx, deployment, client.Apply, patchOptions...)
+	if err != nil && k8serrors.IsConflict(err) {
+		fields := conflictingFields(err)
+		r.log.Info("The KBS deployment has fields managed by other controllers", "Deployment.Namespace", r.namespace,
+			"Deployment.Name", deployment.Name, "fields", fields)
+		return fmt.Errorf("conflicting field managers on deployment %s (fields %v), "+
+			"enable the force-apply option to override them: %w", deployment.Name, fields, err)
+	} else if err != nil {
 		return err
-	} else {
-		// Deployment updated successfully
-		r.log.Info("Updated Deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", "kbs-deployment")
-		return nil
 	}
+	// Deployment updated successfully
+	r.log.Info("Updated Deployment", "Deployment.Namespace", r.namespace, "Deployment.Name", deployment.Name)
+	return nil
+}
+
+// conflictingFields returns the fields reported by a field manager conflict error
+func conflictingFields(err error) []string {
+	var fields []string
+	status, ok := err.(k8serrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return fields
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			fields = append(fields, cause.Field)
+		}
+	}
+	return fields
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KbsConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
-	// Get the namespace that the controller is running in
-	r.namespace = os.Getenv("POD_NAMESPACE")
-	if r.namespace == "" {
-		r.namespace = KbsOperatorNamespace
-	}
-
 	// Create a logr instance and assign it to r.log
 	r.log = ctrl.Log.WithName("kbsconfig-controller")
-	r.log = r.log.WithValues("kbsconfig", r.namespace)
 
 	configMapMapper, err := configMapToKbsConfigMapper(r.Client, r.log)
 	if err != nil {
@@ -635,24 +1217,45 @@ func (r *KbsConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	// Create a new controller and add a watch for KbsConfig including the following secondary resources:
-	// KbsConfigMap, KbsSecret, KbsAsConfigMap, KbsRvpsConfigMap in the same namespace as the controller
-	return ctrl.NewControllerManagedBy(mgr).
+	// KbsConfigMap, KbsSecret, KbsAsConfigMap, KbsRvpsConfigMap in the same namespace as the KbsConfig
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&confidentialcontainersorgv1alpha1.KbsConfig{}).
-		// Watch for changes to ConfigMap, Secret that are in the same namespace as the controller
+		// Watch for changes to ConfigMap, Secret that are in the same namespace as a KbsConfig
 		// The ConfigMap and Secret are not owned by the KbsConfig
 		Watches(
 			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(configMapMapper),
-			builder.WithPredicates(namespacePredicate(r.namespace)),
 		).
 		Watches(
 			&corev1.Secret{},
 			handler.EnqueueRequestsFromMapFunc(secretMapper),
-			builder.WithPredicates(namespacePredicate(r.namespace)),
 		).
+		// Watch the KBS deployment and services to report their readiness and access URL,
+		// and to correct their drift (e.g. an out-of-band edit or deletion) promptly
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
 		Owns(&corev1.ConfigMap{}).
 		Owns(&corev1.Secret{}).
-		Complete(r)
+		Owns(&corev1.ServiceAccount{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&networkingv1.Ingress{})
+
+	// Watch for the nodes whose attestation label changes, so that the scheduling
+	// of KBS stays aligned with the available hardware
+	// The watch is optional, as it caches all the nodes of the cluster
+	if r.NodeWatchLabel != "" {
+		nodeMapper, err := nodeToKbsConfigMapper(r.Client, r.log)
+		if err != nil {
+			return err
+		}
+		b = b.Watches(
+			&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(nodeMapper),
+			builder.WithPredicates(nodeLabelPredicate(r.NodeWatchLabel)),
+		)
+	}
+
+	return b.Complete(r)
 }
 
 // create mapper to transform from ConfigMap to KbsConfig
@@ -680,7 +1283,8 @@ func configMapToKbsConfigMapper(c client.Client, log logr.Logger) (handler.MapFu
 			if kbsConfig.Spec.KbsConfigMapName == configMap.Name ||
 				kbsConfig.Spec.KbsAsConfigMapName == configMap.Name ||
 				kbsConfig.Spec.KbsRvpsConfigMapName == configMap.Name ||
-				kbsConfig.Spec.KbsRvpsRefValuesConfigMapName == configMap.Name {
+				kbsConfig.Spec.KbsRvpsRefValuesConfigMapName == configMap.Name ||
+				kbsConfig.Spec.KbsPolicyConfigMapName == configMap.Name {
 
 				requests = append(requests, reconcile.Request{
 					NamespacedName: types.NamespacedName{
@@ -719,9 +1323,17 @@ func secretToKbsConfigMapper(c client.Client, log logr.Logger) (handler.MapFunc,
 		var requests []reconcile.Request
 		for _, kbsConfig := range kbsConfigList.Items {
 			if kbsConfig.Spec.KbsAuthSecretName == secret.Name ||
+				contains(kbsConfig.Spec.KbsAuthSecretNames, secret.Name) ||
 				kbsConfig.Spec.KbsHttpsKeySecretName == secret.Name ||
 				kbsConfig.Spec.KbsHttpsCertSecretName == secret.Name ||
-				kbsConfig.Spec.KbsSecretResources != nil && contains(kbsConfig.Spec.KbsSecretResources, secret.Name) {
+				kbsConfig.Spec.KbsHttpsCertManager != nil && kbsConfig.Name+"-"+KbsHttpsCertificateName == secret.Name ||
+				kbsConfig.Spec.KbsSecretResources != nil && contains(kbsConfig.Spec.KbsSecretResources, secret.Name) ||
+				mountsSecretResource(&kbsConfig, secret.Name) ||
+				selectsSecretResource(&kbsConfig, secret) ||
+				kbsConfig.Spec.KbsExternalSecretStore != nil && kbsConfig.Spec.KbsExternalSecretStore.CredentialsSecretName == secret.Name ||
+				kbsConfig.Spec.KbsGitResources != nil && kbsConfig.Spec.KbsGitResources.CredentialsSecretName == secret.Name ||
+				kbsResourceReferencesSecret(&kbsConfig, secret.Name) ||
+				referencesImagePullSecret(&kbsConfig, secret.Name) {
 				requests = append(requests, reconcile.Request{
 					NamespacedName: types.NamespacedName{
 						Namespace: kbsConfig.Namespace,
@@ -735,27 +1347,3 @@ func secretToKbsConfigMapper(c client.Client, log logr.Logger) (handler.MapFunc,
 
 	return mapperFunc, nil
 }
-
-// namespacePredicate is a custom predicate function that filters resources based on the namespace.
-func namespacePredicate(namespace string) predicate.Predicate {
-	return predicate.Funcs{
-		CreateFunc: func(e event.CreateEvent) bool {
-			return isResourceInNamespace(e.Object, namespace)
-		},
-		UpdateFunc: func(e event.UpdateEvent) bool {
-			return isResourceInNamespace(e.ObjectNew, namespace)
-		},
-		DeleteFunc: func(e event.DeleteEvent) bool {
-			return isResourceInNamespace(e.Object, namespace)
-		},
-		GenericFunc: func(e event.GenericEvent) bool {
-			return isResourceInNamespace(e.Object, namespace)
-		},
-	}
-}
-
-// isResourceInNamespace checks if the resource is in the specified namespace.
-func isResourceInNamespace(obj metav1.Object, namespace string) bool {
-
-	return obj.GetNamespace() == namespace
-}
@@ -0,0 +1,154 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// createEnvtestKbsConfig creates the namespace of the KbsConfig, the objects it references and the KbsConfig
+func createEnvtestKbsConfig(ctx context.Context, c client.Client, kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: kbsConfig.Namespace}}
+	Expect(c.Create(ctx, namespace)).To(Succeed())
+	for _, obj := range newTestReferencedObjects() {
+		obj.SetNamespace(kbsConfig.Namespace)
+		Expect(c.Create(ctx, obj)).To(Succeed())
+	}
+	Expect(c.Create(ctx, kbsConfig)).To(Succeed())
+}
+
+// reconcileEnvtestKbsConfig reconciles the KbsConfig against the API server of the envtest
+func reconcileEnvtestKbsConfig(ctx context.Context, c client.Client, log logr.Logger,
+	kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig) error {
+	r := &KbsConfigReconciler{
+		Client:    c,
+		APIReader: c,
+		Scheme:    scheme.Scheme,
+		log:       log,
+	}
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: kbsConfig.Namespace,
+		Name:      kbsConfig.Name,
+	}})
+	return err
+}
+
+// newEnvtestLogger returns a logger recording its log lines, and the function returning them
+func newEnvtestLogger() (logr.Logger, func() string) {
+	var mutex sync.Mutex
+	var lines []string
+	log := funcr.New(func(prefix, args string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		lines = append(lines, args)
+	}, funcr.Options{})
+	return log, func() string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return strings.Join(lines, "\n")
+	}
+}
+
+var _ = Describe("KbsConfig controller", func() {
+	ctx := context.Background()
+
+	It("deploys and scales the KBS replicas", func() {
+		kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+		kbsConfig.Namespace = "kbs-replicas"
+		kbsConfig.Spec.KbsReplicas = pointer(int32(3))
+		createEnvtestKbsConfig(ctx, k8sClient, kbsConfig)
+
+		Expect(reconcileEnvtestKbsConfig(ctx, k8sClient, logr.Discard(), kbsConfig)).To(Succeed())
+		deployment := &appsv1.Deployment{}
+		key := client.ObjectKey{Namespace: kbsConfig.Namespace, Name: testKbsDeploymentName}
+		Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+		Expect(deployment.Spec.Replicas).To(HaveValue(Equal(int32(3))))
+		Expect(metav1.IsControlledBy(deployment, kbsConfig)).To(BeTrue())
+
+		// the replicas of the existing deployment are converged by the server-side apply
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kbsConfig), kbsConfig)).To(Succeed())
+		kbsConfig.Spec.KbsReplicas = pointer(int32(5))
+		Expect(k8sClient.Update(ctx, kbsConfig)).To(Succeed())
+		Expect(reconcileEnvtestKbsConfig(ctx, k8sClient, logr.Discard(), kbsConfig)).To(Succeed())
+		Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+		Expect(deployment.Spec.Replicas).To(HaveValue(Equal(int32(5))))
+	})
+
+	It("creates the ServiceMonitor of the KBS metrics", func() {
+		kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+		kbsConfig.Namespace = "kbs-servicemonitor"
+		kbsConfig.Spec.KbsMetrics = &confidentialcontainersorgv1alpha1.KbsMetrics{Enabled: true}
+		createEnvtestKbsConfig(ctx, k8sClient, kbsConfig)
+
+		Expect(reconcileEnvtestKbsConfig(ctx, k8sClient, logr.Discard(), kbsConfig)).To(Succeed())
+		serviceMonitor := &unstructured.Unstructured{}
+		serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+		key := client.ObjectKey{Namespace: kbsConfig.Namespace, Name: testKbsServiceName}
+		Expect(k8sClient.Get(ctx, key, serviceMonitor)).To(Succeed())
+		Expect(metav1.IsControlledBy(serviceMonitor, kbsConfig)).To(BeTrue())
+		endpoints, _, err := unstructured.NestedSlice(serviceMonitor.Object, "spec", "endpoints")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(endpoints).To(ConsistOf(HaveKeyWithValue("port", "kbs-metrics-port")))
+	})
+
+	It("skips the ServiceMonitor when its CRD is not installed", func() {
+		// a dedicated API server, without the CRD of the Prometheus operator
+		noCRDEnv := &envtest.Environment{
+			CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+			ErrorIfCRDPathMissing: true,
+		}
+		noCRDCfg, err := noCRDEnv.Start()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func() {
+			Expect(noCRDEnv.Stop()).To(Succeed())
+		})
+		noCRDClient, err := client.New(noCRDCfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+		kbsConfig.Namespace = "kbs-servicemonitor"
+		kbsConfig.Spec.KbsMetrics = &confidentialcontainersorgv1alpha1.KbsMetrics{Enabled: true}
+		createEnvtestKbsConfig(ctx, noCRDClient, kbsConfig)
+
+		// the KBS is still deployed, only the ServiceMonitor is skipped with a warning
+		log, logs := newEnvtestLogger()
+		Expect(reconcileEnvtestKbsConfig(ctx, noCRDClient, log, kbsConfig)).To(Succeed())
+		Expect(logs()).To(ContainSubstring("the ServiceMonitor CRD is not installed"))
+		key := client.ObjectKey{Namespace: kbsConfig.Namespace, Name: testKbsDeploymentName}
+		Expect(noCRDClient.Get(ctx, key, &appsv1.Deployment{})).To(Succeed())
+	})
+})
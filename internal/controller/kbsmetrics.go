@@ -0,0 +1,93 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// Port of the KBS metrics endpoint when enabled by KbsMetrics without a port
+	defaultKbsMetricsPort = 9090
+	// HTTP path of the KBS metrics
+	defaultKbsMetricsPath = "/metrics"
+)
+
+// kbsMetricsPort returns the port of the KBS metrics endpoint, or 0 if the metrics are disabled
+func (r *KbsConfigReconciler) kbsMetricsPort() int32 {
+	metrics := r.kbsConfig.Spec.KbsMetrics
+	if metrics == nil || !metrics.Enabled {
+		return r.kbsConfig.Spec.KbsMetricsPort
+	}
+	if metrics.Port != 0 {
+		return metrics.Port
+	}
+	if r.kbsConfig.Spec.KbsMetricsPort != 0 {
+		return r.kbsConfig.Spec.KbsMetricsPort
+	}
+	return defaultKbsMetricsPort
+}
+
+// validateKbsMetricsPort checks that the metrics port doesn't collide with the other ports of the KBS pod
+func (r *KbsConfigReconciler) validateKbsMetricsPort() error {
+	metricsPort := r.kbsMetricsPort()
+	if metricsPort == 0 {
+		return nil
+	}
+	if metricsPort < 0 || metricsPort > 65535 {
+		return fmt.Errorf("invalid KBS metrics port %d", metricsPort)
+	}
+	for _, port := range []int32{kbsServicePort, r.asListenPort(), r.rvpsListenPort()} {
+		if metricsPort == port {
+			return fmt.Errorf("KBS metrics port %d collides with a port already used by the KBS pod", metricsPort)
+		}
+	}
+	return nil
+}
+
+// kbsMetricsConfigOverrides returns the overrides enabling the KBS metrics endpoint
+func (r *KbsConfigReconciler) kbsMetricsConfigOverrides() []configOverride {
+	metricsPort := r.kbsMetricsPort()
+	if metricsPort == 0 {
+		return nil
+	}
+	return []configOverride{
+		{
+			path:  []string{"metrics_sockets"},
+			value: []string{fmt.Sprintf("0.0.0.0:%d", metricsPort)},
+		},
+	}
+}
+
+// kbsMetricsServicePorts returns the port exposing the KBS metrics on the KBS service, if enabled
+func (r *KbsConfigReconciler) kbsMetricsServicePorts() []corev1.ServicePort {
+	metricsPort := r.kbsMetricsPort()
+	if metricsPort == 0 {
+		return nil
+	}
+	return []corev1.ServicePort{
+		{
+			Name:       "kbs-metrics-port",
+			Protocol:   corev1.ProtocolTCP,
+			Port:       metricsPort,
+			TargetPort: intstr.FromInt32(metricsPort),
+		},
+	}
+}
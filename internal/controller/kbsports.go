@@ -0,0 +1,103 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// operatorPortNames are the names of the container and service ports added by the operator
+var operatorPortNames = []string{"kbs", "kbs-metrics", "kbs-port", "kbs-metrics-port"}
+
+// kbsPortServicePort returns the port exposing the KBS port on the KBS service
+func kbsPortServicePort(port confidentialcontainersorgv1alpha1.KbsPort) int32 {
+	if port.Port != 0 {
+		return port.Port
+	}
+	return port.ContainerPort
+}
+
+// validateKbsPorts checks that the additional KBS ports have valid and unique names, and that
+// they don't collide with the other ports of the KBS pod and of the KBS service
+func (r *KbsConfigReconciler) validateKbsPorts() error {
+	names := map[string]bool{}
+	containerPorts := map[int32]bool{}
+	for _, port := range []int32{kbsServicePort, r.asListenPort(), r.rvpsListenPort(), r.kbsMetricsPort()} {
+		containerPorts[port] = true
+	}
+	servicePorts := map[int32]bool{kbsServicePort: true, r.kbsMetricsPort(): true}
+	for _, port := range r.kbsConfig.Spec.KbsPorts {
+		// IANA_SVC_NAME: at most 15 lowercase alphanumeric characters or '-'
+		if errs := validation.IsValidPortName(port.Name); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsPorts name %q: %v", port.Name, errs)
+		}
+		if names[port.Name] || contains(operatorPortNames, port.Name) {
+			return fmt.Errorf("duplicated KbsPorts name %q", port.Name)
+		}
+		names[port.Name] = true
+		if errs := validation.IsValidPortNum(int(port.ContainerPort)); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsPorts %q container port %d: %v", port.Name, port.ContainerPort, errs)
+		}
+		if containerPorts[port.ContainerPort] {
+			return fmt.Errorf("KbsPorts %q container port %d collides with a port already used by the KBS pod",
+				port.Name, port.ContainerPort)
+		}
+		containerPorts[port.ContainerPort] = true
+		servicePort := kbsPortServicePort(port)
+		if errs := validation.IsValidPortNum(int(servicePort)); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsPorts %q port %d: %v", port.Name, servicePort, errs)
+		}
+		if servicePorts[servicePort] {
+			return fmt.Errorf("KbsPorts %q port %d collides with a port already exposed by the KBS service",
+				port.Name, servicePort)
+		}
+		servicePorts[servicePort] = true
+	}
+	return nil
+}
+
+// kbsExtraContainerPorts returns the additional ports of the KBS container
+func (r *KbsConfigReconciler) kbsExtraContainerPorts() []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, port := range r.kbsConfig.Spec.KbsPorts {
+		ports = append(ports, corev1.ContainerPort{
+			ContainerPort: port.ContainerPort,
+			Name:          port.Name,
+		})
+	}
+	return ports
+}
+
+// kbsExtraServicePorts returns the ports exposing the additional KBS ports on the KBS service
+func (r *KbsConfigReconciler) kbsExtraServicePorts() []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, port := range r.kbsConfig.Spec.KbsPorts {
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.Name,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       kbsPortServicePort(port),
+			TargetPort: intstr.FromString(port.Name),
+		})
+	}
+	return ports
+}
@@ -0,0 +1,112 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileKbsPorts(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsPorts = []confidentialcontainersorgv1alpha1.KbsPort{
+		{Name: "kbs-api", ContainerPort: 8081},
+		{Name: "kbs-internal", ContainerPort: 8082, Port: 9082},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	containerPorts := map[string]int32{}
+	for _, port := range getTestDeployment(t, r).Spec.Template.Spec.Containers[0].Ports {
+		containerPorts[port.Name] = port.ContainerPort
+	}
+	if containerPorts["kbs"] != kbsServicePort || containerPorts["kbs-api"] != 8081 || containerPorts["kbs-internal"] != 8082 {
+		t.Errorf("expected the KBS ports in the KBS container, got %v", containerPorts)
+	}
+
+	servicePorts := map[string]int32{}
+	for _, port := range getTestService(t, r).Spec.Ports {
+		servicePorts[port.Name] = port.Port
+		if port.Name == "kbs-internal" && port.TargetPort != intstr.FromString("kbs-internal") {
+			t.Errorf("expected the service port to target the named container port, got %v", port.TargetPort)
+		}
+	}
+	if servicePorts["kbs-port"] != kbsServicePort || servicePorts["kbs-api"] != 8081 || servicePorts["kbs-internal"] != 9082 {
+		t.Errorf("expected the KBS ports in the KBS service, got %v", servicePorts)
+	}
+}
+
+func TestValidateKbsPorts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		ports    []confidentialcontainersorgv1alpha1.KbsPort
+		expected string
+	}{
+		{
+			name:     "name too long",
+			ports:    []confidentialcontainersorgv1alpha1.KbsPort{{Name: "kbs-internal-api", ContainerPort: 8081}},
+			expected: "invalid KbsPorts name",
+		},
+		{
+			name:     "invalid name",
+			ports:    []confidentialcontainersorgv1alpha1.KbsPort{{Name: "Kbs_Api", ContainerPort: 8081}},
+			expected: "invalid KbsPorts name",
+		},
+		{
+			name: "duplicated name",
+			ports: []confidentialcontainersorgv1alpha1.KbsPort{
+				{Name: "kbs-api", ContainerPort: 8081}, {Name: "kbs-api", ContainerPort: 8082},
+			},
+			expected: "duplicated KbsPorts name",
+		},
+		{
+			name:     "operator name",
+			ports:    []confidentialcontainersorgv1alpha1.KbsPort{{Name: "kbs-metrics", ContainerPort: 8081}},
+			expected: "duplicated KbsPorts name",
+		},
+		{
+			name:     "KBS container port",
+			ports:    []confidentialcontainersorgv1alpha1.KbsPort{{Name: "kbs-api", ContainerPort: kbsServicePort}},
+			expected: "collides with a port already used by the KBS pod",
+		},
+		{
+			name: "service port",
+			ports: []confidentialcontainersorgv1alpha1.KbsPort{
+				{Name: "kbs-api", ContainerPort: 8081}, {Name: "kbs-internal", ContainerPort: 8082, Port: 8081},
+			},
+			expected: "collides with a port already exposed by the KBS service",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReconciler(t)
+			r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+			r.kbsConfig.Spec.KbsPorts = tc.ports
+			err := r.validateKbsPorts()
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Default grace period of the pods, on top of which the drain timeout is added
+const defaultTerminationGracePeriod = 30 * time.Second
+
+// Longest drain timeout, to avoid blocking the rollouts for too long
+const maxDrainTimeout = time.Hour
+
+// drainTimeout returns the time given to the KBS pods to drain the in-flight sessions before being stopped
+func (r *KbsConfigReconciler) drainTimeout() (time.Duration, error) {
+	if r.kbsConfig.Spec.KbsDrainTimeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(r.kbsConfig.Spec.KbsDrainTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid KbsDrainTimeout %q: %w", r.kbsConfig.Spec.KbsDrainTimeout, err)
+	}
+	if timeout < time.Second || timeout > maxDrainTimeout {
+		return 0, fmt.Errorf("invalid KbsDrainTimeout %q: must be between 1s and %s", r.kbsConfig.Spec.KbsDrainTimeout, maxDrainTimeout)
+	}
+	return timeout, nil
+}
+
+// validateKbsDrain checks the drain endpoint path and timeout, and that the grace period lets the pods drain
+func (r *KbsConfigReconciler) validateKbsDrain() error {
+	path := r.kbsConfig.Spec.KbsDrainEndpointPath
+	if path != "" && (!strings.HasPrefix(path, "/") || strings.ContainsAny(path, " \t?#")) {
+		return fmt.Errorf("invalid KbsDrainEndpointPath %q: must be an absolute path", path)
+	}
+	timeout, err := r.drainTimeout()
+	if err != nil {
+		return err
+	}
+	grace := r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds
+	if grace == nil {
+		return nil
+	}
+	if *grace < 0 {
+		return fmt.Errorf("KbsTerminationGracePeriodSeconds must not be negative, got %d", *grace)
+	}
+	if timeout > 0 && time.Duration(*grace)*time.Second <= timeout {
+		return fmt.Errorf("KbsTerminationGracePeriodSeconds %d must be longer than KbsDrainTimeout %q",
+			*grace, r.kbsConfig.Spec.KbsDrainTimeout)
+	}
+	return nil
+}
+
+// kbsPreStopHandler returns the handler draining the KBS container before it's stopped:
+// the drain endpoint is called when configured, otherwise the container waits for the drain timeout
+func (r *KbsConfigReconciler) kbsPreStopHandler() (*corev1.LifecycleHandler, error) {
+	timeout, err := r.drainTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.kbsConfig.Spec.KbsDrainEndpointPath != "" {
+		scheme := corev1.URISchemeHTTP
+		if r.isHttpsConfigPresent() {
+			scheme = corev1.URISchemeHTTPS
+		}
+		return &corev1.LifecycleHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   r.kbsConfig.Spec.KbsDrainEndpointPath,
+				Port:   intstr.FromInt(kbsServicePort),
+				Scheme: scheme,
+			},
+		}, nil
+	}
+	if timeout > 0 {
+		return &corev1.LifecycleHandler{
+			Sleep: &corev1.SleepAction{
+				Seconds: int64(timeout.Seconds()),
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+// terminationGracePeriodSeconds returns the grace period of the KBS pods: the one of the KbsConfig spec
+// if provided, otherwise the default grace period extended by the drain timeout
+func (r *KbsConfigReconciler) terminationGracePeriodSeconds() (*int64, error) {
+	timeout, err := r.drainTimeout()
+	if err != nil {
+		return nil, err
+	}
+	if r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds != nil {
+		return pointer(*r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds), nil
+	}
+	return pointer(int64((defaultTerminationGracePeriod + timeout).Seconds())), nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestKbsPreStopHandler(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	// no drain configured
+	if handler, err := r.kbsPreStopHandler(); err != nil || handler != nil {
+		t.Errorf("expected no preStop hook, got %v, %v", handler, err)
+	}
+	if grace, err := r.terminationGracePeriodSeconds(); err != nil || grace == nil || *grace != 30 {
+		t.Errorf("expected the default grace period, got %v, %v", grace, err)
+	}
+
+	// wait for the drain timeout
+	r.kbsConfig.Spec.KbsDrainTimeout = "45s"
+	handler, err := r.kbsPreStopHandler()
+	if err != nil || handler == nil || handler.Sleep == nil || handler.Sleep.Seconds != 45 {
+		t.Fatalf("expected a 45s sleep, got %v, %v", handler, err)
+	}
+	if grace, err := r.terminationGracePeriodSeconds(); err != nil || grace == nil || *grace != 75 {
+		t.Errorf("expected a 75s grace period, got %v, %v", grace, err)
+	}
+
+	// the grace period of the spec takes precedence
+	r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds = pointer(int64(60))
+	if grace, err := r.terminationGracePeriodSeconds(); err != nil || grace == nil || *grace != 60 {
+		t.Errorf("expected a 60s grace period, got %v, %v", grace, err)
+	}
+	r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds = nil
+
+	// call the drain endpoint
+	r.kbsConfig.Spec.KbsDrainEndpointPath = "/drain"
+	handler, err = r.kbsPreStopHandler()
+	if err != nil || handler == nil || handler.HTTPGet == nil {
+		t.Fatalf("expected an HTTP preStop hook, got %v, %v", handler, err)
+	}
+	if handler.HTTPGet.Path != "/drain" || handler.HTTPGet.Scheme != corev1.URISchemeHTTP ||
+		handler.HTTPGet.Port.IntValue() != kbsServicePort {
+		t.Errorf("unexpected HTTP preStop hook %v", handler.HTTPGet)
+	}
+}
+
+func TestValidateKbsDrain(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	for _, path := range []string{"drain", "/drain?now"} {
+		r.kbsConfig.Spec.KbsDrainEndpointPath = path
+		if err := r.validateKbsDrain(); err == nil {
+			t.Errorf("expected an error for KbsDrainEndpointPath %q", path)
+		}
+	}
+
+	r.kbsConfig.Spec.KbsDrainEndpointPath = "/drain"
+	for _, timeout := range []string{"500ms", "2h", "soon"} {
+		r.kbsConfig.Spec.KbsDrainTimeout = timeout
+		if err := r.validateKbsDrain(); err == nil {
+			t.Errorf("expected an error for KbsDrainTimeout %q", timeout)
+		}
+	}
+
+	// the grace period must let the pods drain
+	r.kbsConfig.Spec.KbsDrainTimeout = "45s"
+	for _, grace := range []int64{-1, 45} {
+		r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds = pointer(grace)
+		if err := r.validateKbsDrain(); err == nil {
+			t.Errorf("expected an error for KbsTerminationGracePeriodSeconds %d", grace)
+		}
+	}
+	r.kbsConfig.Spec.KbsTerminationGracePeriodSeconds = pointer(int64(50))
+	if err := r.validateKbsDrain(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReconcileTerminationGracePeriod(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	grace := getTestDeployment(t, r).Spec.Template.Spec.TerminationGracePeriodSeconds
+	if grace == nil || *grace != 30 {
+		t.Errorf("expected the default 30s grace period, got %v", grace)
+	}
+
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsTerminationGracePeriodSeconds = pointer(int64(120))
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	grace = getTestDeployment(t, r).Spec.Template.Spec.TerminationGracePeriodSeconds
+	if grace == nil || *grace != 120 {
+		t.Errorf("expected a 120s grace period, got %v", grace)
+	}
+}
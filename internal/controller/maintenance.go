@@ -0,0 +1,194 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// Annotation of the KBS deployment holding the hash of the pod template, which changes on every rollout
+const podTemplateHashAnnotation = "kbsconfig.confidentialcontainers.org/pod-template-hash"
+
+// weekdays maps the days of the maintenance windows to the time package ones
+var weekdays = map[string]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// maintenanceWindow is a parsed KbsMaintenanceWindow
+type maintenanceWindow struct {
+	// days the window starts on, every day if empty
+	days map[time.Weekday]bool
+	// start of the window, from midnight UTC
+	start    time.Duration
+	duration time.Duration
+}
+
+func parseMaintenanceWindow(window confidentialcontainersorgv1alpha1.KbsMaintenanceWindow) (maintenanceWindow, error) {
+	parsed := maintenanceWindow{days: map[time.Weekday]bool{}}
+	for _, day := range window.Days {
+		weekday, ok := weekdays[day]
+		if !ok {
+			return parsed, fmt.Errorf("invalid day %q", day)
+		}
+		parsed.days[weekday] = true
+	}
+	start, err := time.Parse("15:04", window.Start)
+	if err != nil {
+		return parsed, fmt.Errorf("invalid start %q: %w", window.Start, err)
+	}
+	parsed.start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	parsed.duration, err = time.ParseDuration(window.Duration)
+	if err != nil {
+		return parsed, fmt.Errorf("invalid duration %q: %w", window.Duration, err)
+	}
+	if parsed.duration < time.Minute || parsed.duration > 24*time.Hour {
+		return parsed, fmt.Errorf("invalid duration %q: must be between 1m and 24h", window.Duration)
+	}
+	return parsed, nil
+}
+
+// startsOn returns the start of the window on the day of the given time, and whether the window starts that day
+func (w maintenanceWindow) startsOn(day time.Time) (time.Time, bool) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return midnight.Add(w.start), len(w.days) == 0 || w.days[midnight.Weekday()]
+}
+
+// validateKbsMaintenanceWindows checks the days, start and duration of the maintenance windows
+func (r *KbsConfigReconciler) validateKbsMaintenanceWindows() error {
+	for i, window := range r.kbsConfig.Spec.KbsMaintenanceWindows {
+		if _, err := parseMaintenanceWindow(window); err != nil {
+			return fmt.Errorf("invalid KbsMaintenanceWindows[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// nextMaintenanceWindow returns the start of the next maintenance window, or the zero time
+// if a rollout is allowed at the given time: no window is configured or one of them is open
+func (r *KbsConfigReconciler) nextMaintenanceWindow(now time.Time) (time.Time, error) {
+	now = now.UTC()
+	var next time.Time
+	for _, window := range r.kbsConfig.Spec.KbsMaintenanceWindows {
+		parsed, err := parseMaintenanceWindow(window)
+		if err != nil {
+			return time.Time{}, err
+		}
+		// a window started the day before may still be open
+		for offset := -1; offset <= 7; offset++ {
+			start, ok := parsed.startsOn(now.AddDate(0, 0, offset))
+			if !ok {
+				continue
+			}
+			if !now.Before(start) && now.Before(start.Add(parsed.duration)) {
+				return time.Time{}, nil
+			}
+			if start.After(now) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next, nil
+}
+
+// podTemplateHash returns the hash of the pod template of the KBS deployment
+func podTemplateHash(template *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// deferRollout returns true if the rollout of the desired deployment must be deferred to the next
+// maintenance window, and records the outcome in the RolloutDeferred condition of the KbsConfig status
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deferRollout(ctx context.Context, found *appsv1.Deployment, desired *appsv1.Deployment) (bool, error) {
+	if len(r.kbsConfig.Spec.KbsMaintenanceWindows) == 0 {
+		if !meta.RemoveStatusCondition(&r.kbsConfig.Status.Conditions, confidentialcontainersorgv1alpha1.ConditionRolloutDeferred) {
+			return false, nil
+		}
+		return false, r.Status().Update(ctx, r.kbsConfig)
+	}
+
+	condition := metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionRolloutDeferred,
+		ObservedGeneration: r.kbsConfig.Generation,
+		Status:             metav1.ConditionFalse,
+		Reason:             "UpToDate",
+		Message:            "The KBS deployment is up to date",
+	}
+	deferred := false
+	if found.Annotations[podTemplateHashAnnotation] != desired.Annotations[podTemplateHashAnnotation] {
+		next, err := r.nextMaintenanceWindow(time.Now())
+		if err != nil {
+			return false, err
+		}
+		if next.IsZero() {
+			condition.Reason = "InMaintenanceWindow"
+			condition.Message = "The KBS deployment is rolled out during the maintenance window"
+		} else {
+			r.log.Info("Deferring the KBS rollout to the next maintenance window", "window", next)
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = "OutsideMaintenanceWindow"
+			condition.Message = "The KBS rollout is deferred to the maintenance window starting at " + next.Format(time.RFC3339)
+			deferred = true
+		}
+	}
+	if !meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, condition) {
+		return deferred, nil
+	}
+	return deferred, r.Status().Update(ctx, r.kbsConfig)
+}
+
+// keepPodTemplate sets the pod template of the found deployment in the desired one, so that
+// applying it updates the replicas, the strategy and the owner references without a rollout
+func keepPodTemplate(found *appsv1.Deployment, desired *appsv1.Deployment) {
+	desired.Spec.Template = *found.Spec.Template.DeepCopy()
+	desired.Annotations[podTemplateHashAnnotation] = found.Annotations[podTemplateHashAnnotation]
+}
+
+// deferredRolloutRequeueAfter returns the time after which a deferred rollout is retried,
+// at the start of the next maintenance window, or zero if no rollout is deferred
+func (r *KbsConfigReconciler) deferredRolloutRequeueAfter() (time.Duration, error) {
+	if !meta.IsStatusConditionTrue(r.kbsConfig.Status.Conditions, confidentialcontainersorgv1alpha1.ConditionRolloutDeferred) {
+		return 0, nil
+	}
+	next, err := r.nextMaintenanceWindow(time.Now())
+	if err != nil || next.IsZero() {
+		return 0, err
+	}
+	return time.Until(next), nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestNextMaintenanceWindow(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	// no window: the rollouts are always allowed
+	now := time.Date(2024, time.May, 15, 12, 0, 0, 0, time.UTC) // Wednesday
+	if next, err := r.nextMaintenanceWindow(now); err != nil || !next.IsZero() {
+		t.Errorf("expected the rollouts to be allowed without windows, got %v, %v", next, err)
+	}
+
+	r.kbsConfig.Spec.KbsMaintenanceWindows = []confidentialcontainersorgv1alpha1.KbsMaintenanceWindow{
+		{Days: []string{"Sat"}, Start: "22:00", Duration: "4h"},
+		{Days: []string{"Wed"}, Start: "02:00", Duration: "1h"},
+	}
+	for _, test := range []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		// Wednesday noon: next window on Saturday evening
+		{now, time.Date(2024, time.May, 18, 22, 0, 0, 0, time.UTC)},
+		// Wednesday 02:30: in the Wednesday window
+		{time.Date(2024, time.May, 15, 2, 30, 0, 0, time.UTC), time.Time{}},
+		// Sunday 01:00: the Saturday window is still open
+		{time.Date(2024, time.May, 19, 1, 0, 0, 0, time.UTC), time.Time{}},
+		// Sunday 02:00: next window on Wednesday
+		{time.Date(2024, time.May, 19, 2, 0, 0, 0, time.UTC), time.Date(2024, time.May, 22, 2, 0, 0, 0, time.UTC)},
+	} {
+		next, err := r.nextMaintenanceWindow(test.now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !next.Equal(test.expected) {
+			t.Errorf("at %v expected the next window at %v, got %v", test.now, test.expected, next)
+		}
+	}
+}
+
+func TestValidateKbsMaintenanceWindows(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	for _, window := range []confidentialcontainersorgv1alpha1.KbsMaintenanceWindow{
+		{Days: []string{"Monday"}, Start: "02:00", Duration: "1h"},
+		{Start: "2am", Duration: "1h"},
+		{Start: "02:00", Duration: "25h"},
+	} {
+		r.kbsConfig.Spec.KbsMaintenanceWindows = []confidentialcontainersorgv1alpha1.KbsMaintenanceWindow{window}
+		if err := r.validateKbsMaintenanceWindows(); err == nil {
+			t.Errorf("expected an error for the window %v", window)
+		}
+	}
+}
+
+func TestReconcileDeferredRollout(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	// a daily window which opens in two hours
+	start := time.Now().UTC().Add(2 * time.Hour)
+	kbsConfig.Spec.KbsMaintenanceWindows = []confidentialcontainersorgv1alpha1.KbsMaintenanceWindow{
+		{Start: start.Format("15:04"), Duration: "1h"},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	// the deployment is created regardless of the windows
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	image := getTestDeployment(t, r).Spec.Template.Spec.Containers[0].Image
+
+	// a change requiring a rollout is deferred, the scaling is applied
+	kbsConfig = &confidentialcontainersorgv1alpha1.KbsConfig{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsConfigName}, kbsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsImageName = "quay.io/example/kbs:v0.10.1"
+	kbsConfig.Spec.KbsReplicas = pointer(int32(3))
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: KbsOperatorNamespace, Name: testKbsConfigName},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RequeueAfter <= time.Hour || result.RequeueAfter > 2*time.Hour {
+		t.Errorf("expected a requeue at the start of the window, got %v", result.RequeueAfter)
+	}
+	deployment := getTestDeployment(t, r)
+	if current := deployment.Spec.Template.Spec.Containers[0].Image; current != image {
+		t.Errorf("the rollout must be deferred, got image %s", current)
+	}
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected the deployment to be scaled while the rollout is deferred, got %v", deployment.Spec.Replicas)
+	}
+	err = r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsConfigName}, kbsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(kbsConfig.Status.Conditions, confidentialcontainersorgv1alpha1.ConditionRolloutDeferred) {
+		t.Errorf("expected the RolloutDeferred condition, got %v", kbsConfig.Status.Conditions)
+	}
+
+	// the maintenance windows removed, the rollout is applied
+	kbsConfig.Spec.KbsMaintenanceWindows = nil
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if current := getTestDeployment(t, r).Spec.Template.Spec.Containers[0].Image; current != kbsConfig.Spec.KbsImageName {
+		t.Errorf("expected the rollout of the new image, got %s", current)
+	}
+}
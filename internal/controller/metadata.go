@@ -0,0 +1,93 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// deployOrUpdateKbsMetadata publishes the non-sensitive KBS connection details
+// in a ConfigMap owned by the KbsConfig instance
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsMetadata(ctx context.Context) error {
+	data, err := r.kbsMetadata()
+	if err != nil {
+		return err
+	}
+	return r.createOrUpdateOwnedConfigMap(ctx, r.kbsMetadataConfigMapName(), data)
+}
+
+// kbsMetadata returns the content of the metadata ConfigMap for the KBS instance
+func (r *KbsConfigReconciler) kbsMetadata() (map[string]string, error) {
+	kbsDeploymentType := r.kbsConfig.Spec.KbsDeploymentType
+	if kbsDeploymentType == "" {
+		kbsDeploymentType = confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices
+	}
+
+	scheme := "http"
+	if r.isHttpsConfigPresent() {
+		scheme = "https"
+	}
+
+	kbsImageName, err := getImageName(r.kbsConfig.Spec.KbsImageName, "KBS_IMAGE_NAME", DefaultKbsImageName)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"endpoint":       fmt.Sprintf("%s://%s.%s.svc:%d", scheme, r.kbsServiceName(), r.namespace, kbsServicePort),
+		"serviceName":    r.kbsServiceName(),
+		"port":           strconv.Itoa(kbsServicePort),
+		"deploymentType": string(kbsDeploymentType),
+		"kbsImage":       kbsImageName,
+	}
+
+	if kbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices {
+		asImageName, err := getImageName(r.kbsConfig.Spec.KbsAsImageName, "AS_IMAGE_NAME", DefaultAsImageName)
+		if err != nil {
+			return nil, err
+		}
+		rvpsImageName, err := getImageName(r.kbsConfig.Spec.KbsRvpsImageName, "RVPS_IMAGE_NAME", DefaultRvpsImageName)
+		if err != nil {
+			return nil, err
+		}
+		data["asImage"] = asImageName
+		data["rvpsImage"] = rvpsImageName
+	}
+
+	return data, nil
+}
+
+// deleteKbsMetadata deletes the metadata ConfigMap, if present
+func (r *KbsConfigReconciler) deleteKbsMetadata(ctx context.Context) error {
+	r.log.Info("Deleting the metadata ConfigMap")
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.kbsMetadataConfigMapName(),
+			Namespace: r.namespace,
+		},
+	}
+	return client.IgnoreNotFound(r.Client.Delete(ctx, configMap))
+}
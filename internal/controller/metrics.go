@@ -0,0 +1,116 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// Kind label values of the referenced resource resolution metric
+	resourceKindConfigMap = "ConfigMap"
+	resourceKindSecret    = "Secret"
+	resourceKindVolumes   = "Volumes"
+)
+
+// referencedResourceResolutionSeconds measures the time spent resolving the resources
+// referenced by a KbsConfig while reconciling it. The "Volumes" kind covers the whole
+// build of the KBS deployment volumes, the other kinds a single API lookup
+var referencedResourceResolutionSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "kbsconfig_referenced_resource_resolution_seconds",
+		Help:    "Time spent resolving the resources referenced by a KbsConfig, by resource kind",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	},
+	[]string{"kind"},
+)
+
+const (
+	// Result label values of the reconciliation metric
+	reconcileResultSuccess = "success"
+	reconcileResultRequeue = "requeue"
+	reconcileResultError   = "error"
+
+	// Operation label values of the KBS deployment operations metric
+	deploymentOperationCreate = "create"
+	deploymentOperationUpdate = "update"
+)
+
+// reconcileTotal counts the reconciliations of the KbsConfig instances by result. The requeue result
+// covers the reconciliations held off (e.g. waiting for missing references), which aren't errors
+var reconcileTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kbsconfig_reconcile_total",
+		Help: "Number of reconciliations of the KbsConfig instances, by result",
+	},
+	[]string{"result"},
+)
+
+// reconcileErrorsTotal counts the reconciliation failures reported in the KbsConfig status, by reason
+// (e.g. InvalidSpec, DeploymentFailed)
+var reconcileErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kbsconfig_reconcile_errors_total",
+		Help: "Number of reconciliation failures of the KbsConfig instances, by reason",
+	},
+	[]string{"reason"},
+)
+
+// deploymentOperationsTotal counts the creations and the updates of the KBS deployments,
+// a high update rate reveals a churn of the KBS pods
+var deploymentOperationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kbsconfig_deployment_operations_total",
+		Help: "Number of creations and updates of the KBS deployments, by operation",
+	},
+	[]string{"operation"},
+)
+
+// missingReferencesTotal counts the reconciliations held off by missing referenced ConfigMaps and Secrets
+var missingReferencesTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "kbsconfig_missing_references_total",
+		Help: "Number of reconciliations of the KbsConfig instances waiting for missing referenced resources",
+	},
+)
+
+func init() {
+	// Register the metrics with the controller-runtime registry, served by the manager metrics endpoint
+	metrics.Registry.MustRegister(referencedResourceResolutionSeconds, reconcileTotal, reconcileErrorsTotal,
+		deploymentOperationsTotal, missingReferencesTotal)
+}
+
+// observeReconcile records the result of a reconciliation
+func observeReconcile(result ctrl.Result, err error) {
+	switch {
+	case err != nil:
+		reconcileTotal.WithLabelValues(reconcileResultError).Inc()
+	case result.Requeue || result.RequeueAfter > 0:
+		reconcileTotal.WithLabelValues(reconcileResultRequeue).Inc()
+	default:
+		reconcileTotal.WithLabelValues(reconcileResultSuccess).Inc()
+	}
+}
+
+// observeResolution records the time elapsed since start for the given resource kind
+func observeResolution(kind string, start time.Time) {
+	referencedResourceResolutionSeconds.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,95 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileMetrics(t *testing.T) {
+	counters := func() map[string]float64 {
+		return map[string]float64{
+			"error":       testutil.ToFloat64(reconcileTotal.WithLabelValues(reconcileResultError)),
+			"success":     testutil.ToFloat64(reconcileTotal.WithLabelValues(reconcileResultSuccess)),
+			"requeue":     testutil.ToFloat64(reconcileTotal.WithLabelValues(reconcileResultRequeue)),
+			"InvalidSpec": testutil.ToFloat64(reconcileErrorsTotal.WithLabelValues("InvalidSpec")),
+			"create":      testutil.ToFloat64(deploymentOperationsTotal.WithLabelValues(deploymentOperationCreate)),
+			"update":      testutil.ToFloat64(deploymentOperationsTotal.WithLabelValues(deploymentOperationUpdate)),
+			"missing":     testutil.ToFloat64(missingReferencesTotal),
+		}
+	}
+	expectIncrements := func(before map[string]float64, expected map[string]float64) {
+		t.Helper()
+		after := counters()
+		for name := range after {
+			if increment := after[name] - before[name]; increment != expected[name] {
+				t.Errorf("expected the %s counter to increase by %v, got %v", name, expected[name], increment)
+			}
+		}
+	}
+
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsServiceType = "Unknown"
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+	update := func(modify func(*confidentialcontainersorgv1alpha1.KbsConfig)) {
+		t.Helper()
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+			t.Fatal(err)
+		}
+		modify(kbsConfig)
+		if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an invalid spec is a reconcile error
+	before := counters()
+	if err := reconcileKbsConfig(t, r); err == nil {
+		t.Fatalf("expected an error for the invalid service type")
+	}
+	expectIncrements(before, map[string]float64{"error": 1, "InvalidSpec": 1})
+
+	// the deployment is created, then updated
+	update(func(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig) { kbsConfig.Spec.KbsServiceType = "" })
+	before = counters()
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectIncrements(before, map[string]float64{"success": 1, "create": 1})
+
+	update(func(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig) { kbsConfig.Spec.KbsLogLevel = "debug" })
+	before = counters()
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectIncrements(before, map[string]float64{"success": 1, "update": 1})
+
+	// a missing reference requeues the reconciliation
+	update(func(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig) {
+		kbsConfig.Spec.KbsAuthSecretName = "missing-secret"
+	})
+	before = counters()
+	reconcileWaitingKbsConfig(t, r)
+	expectIncrements(before, map[string]float64{"requeue": 1, "missing": 1})
+}
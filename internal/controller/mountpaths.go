@@ -0,0 +1,72 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// kbsConfigMountPath returns the directory where the KBS configuration is mounted in the KBS container
+func (r *KbsConfigReconciler) kbsConfigMountPath() string {
+	if r.kbsConfig.Spec.KbsConfigMountPath != "" {
+		return r.kbsConfig.Spec.KbsConfigMountPath
+	}
+	return filepath.Join(kbsDefaultConfigPath, "kbs-config")
+}
+
+// kbsSecretResourcesMountPath returns the directory where the secret resources are mounted in the KBS container
+func (r *KbsConfigReconciler) kbsSecretResourcesMountPath() string {
+	if r.kbsConfig.Spec.KbsSecretResourcesMountPath != "" {
+		return r.kbsConfig.Spec.KbsSecretResourcesMountPath
+	}
+	return kbsResourcesPath
+}
+
+// asConfigMountPath returns the directory where the AS configuration is mounted in the AS container
+func (r *KbsConfigReconciler) asConfigMountPath() string {
+	if r.kbsConfig.Spec.KbsAsConfigMountPath != "" {
+		return r.kbsConfig.Spec.KbsAsConfigMountPath
+	}
+	return filepath.Join(asDefaultConfigPath, "as-config")
+}
+
+// validateKbsMountPaths checks that the mount paths are clean absolute paths, distinct from the paths
+// of the in-memory KBS storage and the temporary files which they would shadow
+func (r *KbsConfigReconciler) validateKbsMountPaths() error {
+	for name, path := range map[string]string{
+		"KbsConfigMountPath":          r.kbsConfig.Spec.KbsConfigMountPath,
+		"KbsSecretResourcesMountPath": r.kbsConfig.Spec.KbsSecretResourcesMountPath,
+		"KbsAsConfigMountPath":        r.kbsConfig.Spec.KbsAsConfigMountPath,
+	} {
+		if path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) || filepath.Clean(path) != path || path == "/" {
+			return fmt.Errorf("invalid %s %q: must be a clean absolute path", name, path)
+		}
+		for _, reserved := range []string{confidentialContainersPath, repositoryPath, tmpPath} {
+			if path == reserved {
+				return fmt.Errorf("invalid %s %q: the path is reserved", name, path)
+			}
+		}
+	}
+	if r.kbsConfigMountPath() == r.kbsSecretResourcesMountPath() {
+		return fmt.Errorf("KbsConfigMountPath and KbsSecretResourcesMountPath must be different")
+	}
+	return nil
+}
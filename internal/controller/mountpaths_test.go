@@ -0,0 +1,78 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// volumeMountPath returns the mount path of the volume in the container, if mounted
+func volumeMountPath(container corev1.Container, volumeName string) string {
+	for _, volumeMount := range container.VolumeMounts {
+		if volumeMount.Name == volumeName {
+			return volumeMount.MountPath
+		}
+	}
+	return ""
+}
+
+func TestReconcileMountPaths(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsConfigMountPath = "/etc/trustee/kbs"
+	kbsConfig.Spec.KbsSecretResources = []string{"kbsres1"}
+	kbsConfig.Spec.KbsSecretResourcesMountPath = "/srv/kbs/resources"
+	resource := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "kbsres1", Namespace: KbsOperatorNamespace},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig, resource)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	kbsContainer := getTestDeployment(t, r).Spec.Template.Spec.Containers[0]
+	if path := volumeMountPath(kbsContainer, "kbs-config"); path != "/etc/trustee/kbs" {
+		t.Errorf("expected the KBS configuration in /etc/trustee/kbs, got %q", path)
+	}
+	if !contains(kbsContainer.Command, "/etc/trustee/kbs/kbs-config.json") {
+		t.Errorf("expected KBS to read the configuration from the mount path, got %v", kbsContainer.Command)
+	}
+	if path := volumeMountPath(kbsContainer, "kbsres1"); path != "/srv/kbs/resources/kbsres1" {
+		t.Errorf("expected the secret resource in /srv/kbs/resources, got %q", path)
+	}
+}
+
+func TestValidateKbsMountPaths(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	if err := r.validateKbsMountPaths(); err != nil {
+		t.Errorf("unexpected error for the default mount paths: %v", err)
+	}
+	for _, path := range []string{"etc/kbs", "/etc/kbs/", "/", tmpPath, confidentialContainersPath, kbsResourcesPath} {
+		r.kbsConfig.Spec.KbsConfigMountPath = path
+		if err := r.validateKbsMountPaths(); err == nil {
+			t.Errorf("expected an error for KbsConfigMountPath %q", path)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kbsResourceName returns the name of a resource deployed for the KbsConfig instance.
+// The name is prefixed by the KbsConfig name, so that the resources of multiple instances don't collide
+func (r *KbsConfigReconciler) kbsResourceName(name string) string {
+	return r.kbsConfig.Name + "-" + name
+}
+
+// kbsDeploymentName returns the name of the KBS deployment
+func (r *KbsConfigReconciler) kbsDeploymentName() string {
+	return r.kbsResourceName(KbsDeploymentName)
+}
+
+// kbsServiceName returns the name of the KBS service
+func (r *KbsConfigReconciler) kbsServiceName() string {
+	return r.kbsResourceName(KbsServiceName)
+}
+
+// kbsMetadataConfigMapName returns the name of the KBS metadata ConfigMap
+func (r *KbsConfigReconciler) kbsMetadataConfigMapName() string {
+	return r.kbsResourceName(KbsMetadataConfigMapName)
+}
+
+// kbsDryRunConfigMapName returns the name of the ConfigMap holding the manifests rendered in dry-run mode
+func (r *KbsConfigReconciler) kbsDryRunConfigMapName() string {
+	return r.kbsResourceName(KbsDryRunConfigMapName)
+}
+
+// kbsPodLabels returns the labels of the KBS pods, selected by the KBS deployment and services
+func (r *KbsConfigReconciler) kbsPodLabels() map[string]string {
+	return map[string]string{
+		"app":          "kbs",
+		kbsConfigLabel: r.kbsConfig.Name,
+	}
+}
+
+// validateKbsResourceNames checks that the names of the KBS resources derived from the KbsConfig name are valid:
+// the service name is a DNS label, which is shorter than the names of the other resources
+func (r *KbsConfigReconciler) validateKbsResourceNames() error {
+	if errs := validation.IsDNS1035Label(r.kbsServiceName()); len(errs) != 0 {
+		return fmt.Errorf("the KbsConfig name %q is not suitable for naming the KBS service %s: %v",
+			r.kbsConfig.Name, r.kbsServiceName(), errs)
+	}
+	if errs := validation.IsValidLabelValue(r.kbsConfig.Name); len(errs) != 0 {
+		return fmt.Errorf("the KbsConfig name %q is not suitable for labelling the KBS pods: %v", r.kbsConfig.Name, errs)
+	}
+	return nil
+}
+
+// deleteLegacyKbsResources deletes the resources deployed with the fixed names used before the names
+// were prefixed by the KbsConfig name, so that an upgraded operator doesn't leave a stale KBS running.
+// Only the resources controlled by the KbsConfig instance are deleted
+func (r *KbsConfigReconciler) deleteLegacyKbsResources(ctx context.Context) error {
+	legacyResources := map[string]client.Object{
+		KbsDeploymentName:        &appsv1.Deployment{},
+		KbsServiceName:           &corev1.Service{},
+		KbsMetadataConfigMapName: &corev1.ConfigMap{},
+		KbsServiceAccountName:    &corev1.ServiceAccount{},
+	}
+	for name, obj := range legacyResources {
+		err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: name}, obj)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !metav1.IsControlledBy(obj, r.kbsConfig) {
+			continue
+		}
+		r.log.Info("Deleting a legacy KBS resource", "Namespace", r.namespace, "Name", name)
+		err = client.IgnoreNotFound(r.Client.Delete(ctx, obj))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
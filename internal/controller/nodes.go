@@ -0,0 +1,90 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// nodeLabelPredicate filters the node events changing the set of nodes carrying the given label:
+// nodes created or deleted with the label, and nodes whose label is added, removed or changed
+func nodeLabelPredicate(label string) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			_, ok := e.Object.GetLabels()[label]
+			return ok
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldValue, oldOk := e.ObjectOld.GetLabels()[label]
+			newValue, newOk := e.ObjectNew.GetLabels()[label]
+			return oldOk != newOk || oldValue != newValue
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			_, ok := e.Object.GetLabels()[label]
+			return ok
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// create mapper to transform from Node to all the KbsConfig instances
+func nodeToKbsConfigMapper(c client.Client, log logr.Logger) (handler.MapFunc, error) {
+	mapperFunc := func(ctx context.Context, o client.Object) []reconcile.Request {
+		log.Info("nodeToKbsConfigMapper")
+		node, ok := o.(*corev1.Node)
+		if !ok {
+			log.Info("Expected a Node, but got another type", "objectType", o.GetObjectKind())
+			return nil
+		}
+
+		// Get the KbsConfig object
+		kbsConfigList := &confidentialcontainersorgv1alpha1.KbsConfigList{}
+		err := c.List(ctx, kbsConfigList)
+		if err != nil {
+			log.Info("Error in listing KbsConfig", "err", err)
+			return nil
+		}
+
+		log.Info("Checking KbsConfig", "Node.Name", node.Name, "KbsConfigList", kbsConfigList.Items)
+
+		var requests []reconcile.Request
+		for _, kbsConfig := range kbsConfigList.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: kbsConfig.Namespace,
+					Name:      kbsConfig.Name,
+				},
+			})
+		}
+		return requests
+	}
+
+	return mapperFunc, nil
+}
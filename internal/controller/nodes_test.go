@@ -0,0 +1,79 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func newTestNode(labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels},
+	}
+}
+
+func TestNodeLabelPredicate(t *testing.T) {
+	label := "amd.com/sev-snp"
+	p := nodeLabelPredicate(label)
+
+	if !p.Create(event.CreateEvent{Object: newTestNode(map[string]string{label: "true"})}) {
+		t.Errorf("expected the creation of a labelled node to be processed")
+	}
+	if p.Create(event.CreateEvent{Object: newTestNode(nil)}) {
+		t.Errorf("expected the creation of an unlabelled node to be ignored")
+	}
+	if !p.Delete(event.DeleteEvent{Object: newTestNode(map[string]string{label: "true"})}) {
+		t.Errorf("expected the deletion of a labelled node to be processed")
+	}
+
+	for _, test := range []struct {
+		old, new map[string]string
+		expected bool
+	}{
+		{nil, map[string]string{label: "true"}, true},
+		{map[string]string{label: "true"}, nil, true},
+		{map[string]string{label: "true"}, map[string]string{label: "false"}, true},
+		{map[string]string{label: "true"}, map[string]string{label: "true", "other": "value"}, false},
+		{nil, map[string]string{"other": "value"}, false},
+	} {
+		e := event.UpdateEvent{ObjectOld: newTestNode(test.old), ObjectNew: newTestNode(test.new)}
+		if p.Update(e) != test.expected {
+			t.Errorf("unexpected result for the update from %v to %v", test.old, test.new)
+		}
+	}
+}
+
+func TestNodeToKbsConfigMapper(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	r := newTestReconciler(t, kbsConfig)
+
+	mapper, err := nodeToKbsConfigMapper(r.Client, r.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := mapper(context.TODO(), newTestNode(nil))
+	if len(requests) != 1 || requests[0].Name != testKbsConfigName {
+		t.Errorf("expected the KbsConfig to be reconciled, got %v", requests)
+	}
+}
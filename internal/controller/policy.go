@@ -0,0 +1,207 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// regoPackageRegexp matches the package declaration a rego policy must start with
+var regoPackageRegexp = regexp.MustCompile(`(?m)^\s*package\s+[a-zA-Z_][a-zA-Z0-9_.]*\s*$`)
+
+// teeRegexp matches a TEE type of the attestation claims (e.g. "snp", "az-snp-vtpm")
+var teeRegexp = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// validateAttestationPolicy checks that the inline attestation policy looks like a rego policy
+func (r *KbsConfigReconciler) validateAttestationPolicy() error {
+	policy := r.kbsConfig.Spec.KbsAttestationPolicy
+	if policy == "" {
+		return nil
+	}
+	if strings.TrimSpace(policy) == "" {
+		return fmt.Errorf("KbsAttestationPolicy is empty")
+	}
+	if !regoPackageRegexp.MatchString(policy) {
+		return fmt.Errorf("KbsAttestationPolicy is not a valid rego policy: missing package declaration")
+	}
+	return nil
+}
+
+// createAttestationPolicyVolume stores the inline attestation policy in a ConfigMap
+// owned by the KbsConfig instance and returns the volume for mounting it
+func (r *KbsConfigReconciler) createAttestationPolicyVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	if r.kbsConfig.Spec.KbsAttestationPolicy == "" {
+		return nil, fmt.Errorf("KbsAttestationPolicy hasn't been provided")
+	}
+
+	configMapName := r.kbsDeploymentName() + "-" + volumeName
+	err := r.createOrUpdateOwnedConfigMap(ctx, configMapName, map[string]string{
+		attestationPolicyFileName: r.kbsConfig.Spec.KbsAttestationPolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMapName,
+				},
+			},
+		},
+	}
+	return &volume, nil
+}
+
+// validateResourcePolicyRules checks the paths and the TEE types of the resource policy rules
+func (r *KbsConfigReconciler) validateResourcePolicyRules() error {
+	for i, rule := range r.kbsConfig.Spec.KbsResourcePolicyRules {
+		segments := strings.Split(rule.Path, "/")
+		if len(segments) != 3 {
+			return fmt.Errorf("invalid KbsResourcePolicyRules[%d] path %q: must be <repository>/<type>/<tag>", i, rule.Path)
+		}
+		for _, segment := range segments {
+			if segment != "*" && !resourcePathSegmentRegexp.MatchString(segment) {
+				return fmt.Errorf("invalid KbsResourcePolicyRules[%d] path %q: invalid segment %q", i, rule.Path, segment)
+			}
+		}
+		for _, tee := range rule.Tees {
+			if !teeRegexp.MatchString(tee) {
+				return fmt.Errorf("invalid KbsResourcePolicyRules[%d] TEE type %q", i, tee)
+			}
+		}
+	}
+	return nil
+}
+
+// validateKbsPolicyConfigMap checks that the resource policy is provided either by the rules or by a ConfigMap
+func (r *KbsConfigReconciler) validateKbsPolicyConfigMap() error {
+	if r.kbsConfig.Spec.KbsPolicyConfigMapName != "" && len(r.kbsConfig.Spec.KbsResourcePolicyRules) > 0 {
+		return fmt.Errorf("KbsPolicyConfigMapName and KbsResourcePolicyRules are mutually exclusive")
+	}
+	return nil
+}
+
+// usesResourcePolicy returns true if a resource policy is mounted in the KBS container
+func (r *KbsConfigReconciler) usesResourcePolicy() bool {
+	return len(r.kbsConfig.Spec.KbsResourcePolicyRules) > 0 || r.kbsConfig.Spec.KbsPolicyConfigMapName != ""
+}
+
+// renderResourcePolicy renders the resource policy rules into a KBS resource policy (rego)
+// Every rule is an "allowed" clause matching the requested resource path and the TEE of the attestation claims,
+// KBS evaluating data.policy.allowed
+func renderResourcePolicy(rules []confidentialcontainersorgv1alpha1.KbsResourcePolicyRule) string {
+	var policy strings.Builder
+	policy.WriteString("package policy\n\ndefault allowed = false\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&policy, "\n# %s\nallowed {\n", rule.Path)
+		policy.WriteString("\tpath := split(trim_left(data[\"resource-path\"], \"/\"), \"/\")\n")
+		policy.WriteString("\tcount(path) == 3\n")
+		for i, segment := range strings.Split(rule.Path, "/") {
+			if segment != "*" {
+				fmt.Fprintf(&policy, "\tpath[%d] == %q\n", i, segment)
+			}
+		}
+		if len(rule.Tees) > 0 {
+			tees := make([]string, len(rule.Tees))
+			for i, tee := range rule.Tees {
+				tees[i] = fmt.Sprintf("%q", tee)
+			}
+			fmt.Fprintf(&policy, "\ttees := {%s}\n", strings.Join(tees, ", "))
+			policy.WriteString("\ttees[input[\"tee\"]]\n")
+		}
+		policy.WriteString("}\n")
+	}
+	return policy.String()
+}
+
+// createResourcePolicyVolume stores the resource policy rendered from the rules in a ConfigMap
+// owned by the KbsConfig instance and returns the volume for mounting it
+func (r *KbsConfigReconciler) createResourcePolicyVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	if len(r.kbsConfig.Spec.KbsResourcePolicyRules) == 0 {
+		return nil, fmt.Errorf("KbsResourcePolicyRules haven't been provided")
+	}
+
+	configMapName := r.kbsDeploymentName() + "-" + volumeName
+	err := r.createOrUpdateOwnedConfigMap(ctx, configMapName, map[string]string{
+		resourcePolicyFileName: renderResourcePolicy(r.kbsConfig.Spec.KbsResourcePolicyRules),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMapName,
+				},
+			},
+		},
+	}
+	return &volume, nil
+}
+
+// processKbsPolicyConfigMapVolume checks the resource policy ConfigMap and returns the volume for mounting it
+func (r *KbsConfigReconciler) processKbsPolicyConfigMapVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	configMapName := r.kbsConfig.Spec.KbsPolicyConfigMapName
+	if configMapName == "" {
+		return nil, fmt.Errorf("KbsPolicyConfigMapName hasn't been provided")
+	}
+
+	r.log.Info("Retrieving KbsPolicyConfigMapName", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name", configMapName)
+	foundConfigMap := &corev1.ConfigMap{}
+	err := r.getReferencedObject(ctx, configMapName, foundConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	policy, ok := foundConfigMap.Data[resourcePolicyFileName]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s doesn't contain the %s key", configMapName, resourcePolicyFileName)
+	}
+	if !regoPackageRegexp.MatchString(policy) {
+		return nil, fmt.Errorf("ConfigMap %s doesn't contain a valid rego policy: missing package declaration", configMapName)
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: configMapName,
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key:  resourcePolicyFileName,
+						Path: resourcePolicyFileName,
+					},
+				},
+			},
+		},
+	}
+	return &volume, nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+const (
+	// missingReferencesMinRequeueAfter is the first delay after which a KbsConfig with missing references is reconciled again
+	missingReferencesMinRequeueAfter = 5 * time.Second
+	// missingReferencesMaxRequeueAfter caps the delay between two reconciliations of a KbsConfig with missing references
+	missingReferencesMaxRequeueAfter = 5 * time.Minute
+)
+
+// referencedObject is a ConfigMap or a Secret referenced by a field of the KbsConfig spec
+type referencedObject struct {
+	field string
+	name  string
+	obj   client.Object
+}
+
+// missingReferencesError reports all the missing referenced objects at once,
+// wrapping their NotFound errors
+type missingReferencesError struct {
+	message string
+	errs    []error
+}
+
+func (e *missingReferencesError) Error() string {
+	return e.message
+}
+
+func (e *missingReferencesError) Unwrap() []error {
+	return e.errs
+}
+
+// kind returns the kind of the referenced object
+func (o referencedObject) kind() string {
+	if _, ok := o.obj.(*corev1.ConfigMap); ok {
+		return resourceKindConfigMap
+	}
+	return resourceKindSecret
+}
+
+// referencedObjects returns the ConfigMaps and Secrets referenced by the KbsConfig spec which are
+// required by the current configuration. The missing mandatory fields are reported by the volume builders
+func (r *KbsConfigReconciler) referencedObjects() []referencedObject {
+	var objects []referencedObject
+	configMap := func(field string, name string) {
+		if name != "" {
+			objects = append(objects, referencedObject{field: field, name: name, obj: &corev1.ConfigMap{}})
+		}
+	}
+	secret := func(field string, name string) {
+		if name != "" {
+			objects = append(objects, referencedObject{field: field, name: name, obj: &corev1.Secret{}})
+		}
+	}
+
+	spec := &r.kbsConfig.Spec
+	configMap("KbsConfigMapName", spec.KbsConfigMapName)
+	if spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne || r.runsRvps() {
+		configMap("KbsRvpsRefValuesConfigMapName", spec.KbsRvpsRefValuesConfigMapName)
+	}
+	if r.runsAs() {
+		configMap("KbsAsConfigMapName", spec.KbsAsConfigMapName)
+	}
+	if r.runsRvps() {
+		configMap("KbsRvpsConfigMapName", spec.KbsRvpsConfigMapName)
+	}
+	configMap("KbsPolicyConfigMapName", spec.KbsPolicyConfigMapName)
+	secret("KbsAuthSecretName", spec.KbsAuthSecretName)
+	for _, name := range spec.KbsAuthSecretNames {
+		secret("KbsAuthSecretNames", name)
+	}
+	if r.isHttpsConfigPresent() {
+		secret("KbsHttpsKeySecretName", spec.KbsHttpsKeySecretName)
+		secret("KbsHttpsCertSecretName", spec.KbsHttpsCertSecretName)
+		if spec.KbsHttpsCertManager != nil {
+			secret("KbsHttpsCertManager", r.kbsHttpsCertificateName())
+		}
+	}
+	for _, name := range spec.KbsSecretResources {
+		secret("KbsSecretResources", name)
+	}
+	for _, mount := range spec.KbsSecretResourceMounts {
+		secret("KbsSecretResourceMounts", mount.SecretName)
+	}
+	for _, resource := range spec.KbsResources {
+		if resource.SecretKeyRef != nil {
+			secret("KbsResources", resource.SecretKeyRef.Name)
+		}
+	}
+	if spec.KbsExternalSecretStore != nil {
+		secret("KbsExternalSecretStore", spec.KbsExternalSecretStore.CredentialsSecretName)
+	}
+	if spec.KbsGitResources != nil {
+		secret("KbsGitResources", spec.KbsGitResources.CredentialsSecretName)
+	}
+	for _, pullSecret := range spec.KbsImagePullSecrets {
+		secret("KbsImagePullSecrets", pullSecret.Name)
+	}
+	return objects
+}
+
+// validateReferences checks up front that all the ConfigMaps and Secrets referenced by the KbsConfig
+// exist, reporting the missing ones at once in the returned error and in the ReferencesResolved condition
+// Errors are logged by the caller
+func (r *KbsConfigReconciler) validateReferences(ctx context.Context) error {
+	var missing []string
+	var notFoundErrs []error
+	for _, referenced := range r.referencedObjects() {
+		// The referenced objects are always looked up in the namespace of the KbsConfig,
+		// a KbsConfig can't reach the ConfigMaps and Secrets of another namespace
+		if errs := validation.IsDNS1123Subdomain(referenced.name); len(errs) != 0 {
+			return fmt.Errorf("invalid %s %q in %s: must be the name of a %s in namespace %s",
+				referenced.kind(), referenced.name, referenced.field, referenced.kind(), r.namespace)
+		}
+		err := r.getReferencedObject(ctx, referenced.name, referenced.obj)
+		var forbiddenErr *forbiddenReferenceError
+		if err != nil && k8serrors.IsNotFound(err) {
+			missing = append(missing, fmt.Sprintf("%s %s (%s)", referenced.kind(), referenced.name, referenced.field))
+			notFoundErrs = append(notFoundErrs, err)
+		} else if errors.As(err, &forbiddenErr) {
+			// the status is updated by the caller, along with the Degraded condition
+			meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, metav1.Condition{
+				Type:               confidentialcontainersorgv1alpha1.ConditionReferencesResolved,
+				ObservedGeneration: r.kbsConfig.Generation,
+				Status:             metav1.ConditionFalse,
+				Reason:             forbiddenErr.reason(),
+				Message:            fmt.Sprintf("%s %s (%s): %s", referenced.kind(), referenced.name, referenced.field, err),
+			})
+			return err
+		} else if err != nil {
+			return err
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionReferencesResolved,
+		ObservedGeneration: r.kbsConfig.Generation,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ReferencesFound",
+		Message:            "All the referenced ConfigMaps and Secrets exist",
+	}
+	if len(missing) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "MissingReferences"
+		condition.Message = "Missing referenced resources in namespace " + r.namespace + ": " + strings.Join(missing, ", ")
+		// the status is updated by the caller, along with the Waiting condition
+		meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, condition)
+		return &missingReferencesError{message: condition.Message, errs: notFoundErrs}
+	}
+	changed := meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, condition)
+	if meta.RemoveStatusCondition(&r.kbsConfig.Status.Conditions, confidentialcontainersorgv1alpha1.ConditionWaiting) {
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.Status().Update(ctx, r.kbsConfig)
+}
+
+// waitForReferences reports in the Waiting condition that the reconciliation is held off until the
+// missing references are created, and returns the delay after which the KbsConfig is reconciled again.
+// The creation of a referenced object triggers a reconciliation anyway, the requeue is a fallback
+// whose delay doubles with the waiting time, so that a KbsConfig waiting for long doesn't load the API server
+func (r *KbsConfigReconciler) waitForReferences(ctx context.Context, missingErr error) time.Duration {
+	requeueAfter := missingReferencesMinRequeueAfter
+	waiting := meta.FindStatusCondition(r.kbsConfig.Status.Conditions, confidentialcontainersorgv1alpha1.ConditionWaiting)
+	if waiting != nil && waiting.Status == metav1.ConditionTrue {
+		requeueAfter = max(requeueAfter, time.Since(waiting.LastTransitionTime.Time))
+	}
+	requeueAfter = min(requeueAfter, missingReferencesMaxRequeueAfter)
+
+	meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionWaiting,
+		ObservedGeneration: r.kbsConfig.Generation,
+		Status:             metav1.ConditionTrue,
+		Reason:             "MissingReferences",
+		Message:            missingErr.Error(),
+	})
+	r.recordEvent(corev1.EventTypeWarning, "MissingReferences", "%s", missingErr)
+	missingReferencesTotal.Inc()
+	err := r.Status().Update(ctx, r.kbsConfig)
+	if err != nil {
+		r.log.Info("Error in reporting the missing references in the KbsConfig status", "err", err)
+	}
+	return requeueAfter
+}
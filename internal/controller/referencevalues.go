@@ -0,0 +1,90 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// Name of the file containing the RVPS reference values
+const referenceValuesFileName = "reference-values.json"
+
+// referenceValuesValidators maps every supported schema version to its validation function
+var referenceValuesValidators = map[confidentialcontainersorgv1alpha1.RefValuesSchemaVersion]func([]byte) error{
+	confidentialcontainersorgv1alpha1.RefValuesSchemaVersionV1: validateReferenceValuesV1,
+}
+
+// referenceValueV1 is a reference value in the v1 format
+type referenceValueV1 struct {
+	Name       string        `json:"name"`
+	Expired    string        `json:"expired"`
+	HashValues []hashValueV1 `json:"hash-value"`
+}
+
+// hashValueV1 is a hash value of a reference value in the v1 format
+type hashValueV1 struct {
+	Alg   string `json:"alg"`
+	Value string `json:"value"`
+}
+
+// validateReferenceValues checks the content of reference-values.json against the schema of the given version
+func validateReferenceValues(version confidentialcontainersorgv1alpha1.RefValuesSchemaVersion, content string) error {
+	validator, ok := referenceValuesValidators[version]
+	if !ok {
+		return fmt.Errorf("unsupported reference values schema version %q", version)
+	}
+	err := validator([]byte(content))
+	if err != nil {
+		return fmt.Errorf("invalid reference values (schema %s): %w", version, err)
+	}
+	return nil
+}
+
+func validateReferenceValuesV1(content []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	var referenceValues []referenceValueV1
+	err := decoder.Decode(&referenceValues)
+	if err != nil {
+		return err
+	}
+
+	for i, referenceValue := range referenceValues {
+		if referenceValue.Name == "" {
+			return fmt.Errorf("reference value #%d: missing name", i)
+		}
+		if referenceValue.Expired != "" {
+			if _, err := time.Parse(time.RFC3339, referenceValue.Expired); err != nil {
+				return fmt.Errorf("reference value %s: invalid expiration %q", referenceValue.Name, referenceValue.Expired)
+			}
+		}
+		if len(referenceValue.HashValues) == 0 {
+			return fmt.Errorf("reference value %s: missing hash values", referenceValue.Name)
+		}
+		for _, hashValue := range referenceValue.HashValues {
+			if hashValue.Alg == "" || hashValue.Value == "" {
+				return fmt.Errorf("reference value %s: hash values require alg and value", referenceValue.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestValidateReferenceValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"empty list", `[]`, true},
+		{"valid", `[{"name": "svn", "expired": "2025-01-01T00:00:00Z", "hash-value": [{"alg": "sha256", "value": "1"}]}]`, true},
+		{"not a list", `{}`, false},
+		{"missing name", `[{"hash-value": [{"alg": "sha256", "value": "1"}]}]`, false},
+		{"invalid expiration", `[{"name": "svn", "expired": "tomorrow", "hash-value": [{"alg": "sha256", "value": "1"}]}]`, false},
+		{"missing hash values", `[{"name": "svn"}]`, false},
+		{"unknown field", `[{"name": "svn", "hash": "1", "hash-value": [{"alg": "sha256", "value": "1"}]}]`, false},
+	}
+	for _, test := range tests {
+		err := validateReferenceValues(confidentialcontainersorgv1alpha1.RefValuesSchemaVersionV1, test.content)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+
+	if err := validateReferenceValues("v0", `[]`); err == nil {
+		t.Errorf("expected an error for an unsupported schema version")
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// resourcePathSegmentRegexp matches a valid repository, type or tag of a KBS resource
+var resourcePathSegmentRegexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// kbsResourceGroup is the set of KBS resources sharing the same repository and type,
+// that are stored in the same secret and mounted in the same directory
+type kbsResourceGroup struct {
+	repository   string
+	resourceType string
+	resources    []confidentialcontainersorgv1alpha1.KbsResource
+}
+
+// name returns a name identifying the group, suitable for both secrets and volumes
+func (g *kbsResourceGroup) name() string {
+	hash := sha256.Sum256([]byte(g.repository + "/" + g.resourceType))
+	return "resource-" + hex.EncodeToString(hash[:])[:10]
+}
+
+func kbsResourceRepository(resource confidentialcontainersorgv1alpha1.KbsResource) string {
+	if resource.Repository == "" {
+		return defaultRepository
+	}
+	return resource.Repository
+}
+
+// validateKbsResources checks that every resource has a valid and unique path
+// and exactly one source for its content
+func (r *KbsConfigReconciler) validateKbsResources() error {
+	paths := map[string]bool{}
+	for _, resource := range r.kbsConfig.Spec.KbsResources {
+		repository := kbsResourceRepository(resource)
+		path := repository + "/" + resource.Type + "/" + resource.Tag
+		for _, segment := range []string{repository, resource.Type, resource.Tag} {
+			if !resourcePathSegmentRegexp.MatchString(segment) {
+				return fmt.Errorf("invalid KBS resource path %s", path)
+			}
+		}
+		if paths[path] {
+			return fmt.Errorf("duplicated KBS resource %s", path)
+		}
+		paths[path] = true
+
+		if (resource.Value == "") == (resource.SecretKeyRef == nil) {
+			return fmt.Errorf("KBS resource %s: exactly one of value and secretKeyRef must be provided", path)
+		}
+		// a KbsSecretResources secret is mounted as a type of the default repository
+		if repository == defaultRepository && contains(r.kbsConfig.Spec.KbsSecretResources, resource.Type) {
+			return fmt.Errorf("KBS resource %s collides with the KbsSecretResources secret %s", path, resource.Type)
+		}
+	}
+	return nil
+}
+
+// kbsResourceGroups groups the KBS resources by repository and type
+func (r *KbsConfigReconciler) kbsResourceGroups() []*kbsResourceGroup {
+	groups := map[string]*kbsResourceGroup{}
+	for _, resource := range r.kbsConfig.Spec.KbsResources {
+		repository := kbsResourceRepository(resource)
+		key := repository + "/" + resource.Type
+		group, ok := groups[key]
+		if !ok {
+			group = &kbsResourceGroup{repository: repository, resourceType: resource.Type}
+			groups[key] = group
+		}
+		group.resources = append(group.resources, resource)
+	}
+
+	var sortedGroups []*kbsResourceGroup
+	for _, group := range groups {
+		sortedGroups = append(sortedGroups, group)
+	}
+	// keep the volumes order stable across reconciliations
+	sort.Slice(sortedGroups, func(i, j int) bool {
+		return sortedGroups[i].name() < sortedGroups[j].name()
+	})
+	return sortedGroups
+}
+
+// createKbsResourcesVolumes stores the KBS resources in secrets owned by the KbsConfig instance
+// and returns the volumes and the KBS volume mounts for exposing them in the resource repository.
+// Secrets of resources that have been removed from the spec are deleted.
+// The resources are not registered through the KBS admin API: the updates reach KBS through the kubelet
+// refresh of the mounted secrets, or the rollout triggered by the config checksum
+func (r *KbsConfigReconciler) createKbsResourcesVolumes(ctx context.Context) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	desired := map[string]bool{}
+
+	for _, group := range r.kbsResourceGroups() {
+		data := map[string][]byte{}
+		for _, resource := range group.resources {
+			value, err := r.kbsResourceValue(ctx, resource)
+			if err != nil {
+				return nil, nil, err
+			}
+			data[resource.Tag] = value
+		}
+
+		secretName := r.kbsDeploymentName() + "-" + group.name()
+		err := r.createOrUpdateKbsResourceSecret(ctx, secretName, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		desired[secretName] = true
+
+		volumes = append(volumes, corev1.Volume{
+			Name: group.name(),
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: secretName,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts,
+			createVolumeMount(group.name(), filepath.Join(repositoryPath, group.repository, group.resourceType)))
+	}
+
+	err := r.deleteKbsResourceSecrets(ctx, desired)
+	if err != nil {
+		return nil, nil, err
+	}
+	return volumes, volumeMounts, nil
+}
+
+// kbsResourceValue returns the content of a KBS resource
+func (r *KbsConfigReconciler) kbsResourceValue(ctx context.Context, resource confidentialcontainersorgv1alpha1.KbsResource) ([]byte, error) {
+	if resource.SecretKeyRef == nil {
+		return []byte(resource.Value), nil
+	}
+	secret := &corev1.Secret{}
+	err := r.getReferencedObject(ctx, resource.SecretKeyRef.Name, secret)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[resource.SecretKeyRef.Key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in Secret %s", resource.SecretKeyRef.Key, resource.SecretKeyRef.Name)
+	}
+	return value, nil
+}
+
+// createOrUpdateKbsResourceSecret creates or updates a secret holding KBS resources
+func (r *KbsConfigReconciler) createOrUpdateKbsResourceSecret(ctx context.Context, name string, data map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace,
+			Labels: map[string]string{
+				kbsResourceLabel: "true",
+			},
+		},
+		Data: data,
+	}
+	// Set KbsConfig instance as the owner and controller
+	err := r.setKbsConfigOwner(secret)
+	if err != nil {
+		return err
+	}
+
+	found := &corev1.Secret{}
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(secret), found)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("Creating KBS resources secret", "Secret.Namespace", r.namespace, "Secret.Name", name)
+		return r.Client.Create(ctx, secret)
+	} else if err != nil {
+		return err
+	}
+
+	found.Labels = secret.Labels
+	found.Data = secret.Data
+	found.OwnerReferences = secret.OwnerReferences
+	r.log.Info("Updating KBS resources secret", "Secret.Namespace", r.namespace, "Secret.Name", name)
+	return r.Client.Update(ctx, found)
+}
+
+// deleteKbsResourceSecrets deletes the secrets holding KBS resources which are not in the keep set
+func (r *KbsConfigReconciler) deleteKbsResourceSecrets(ctx context.Context, keep map[string]bool) error {
+	secrets := &corev1.SecretList{}
+	err := r.Client.List(ctx, secrets, client.InNamespace(r.namespace), client.HasLabels{kbsResourceLabel})
+	if err != nil {
+		return err
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		// skip the ones of the other KbsConfig instances of the namespace
+		if keep[secret.Name] || !metav1.IsControlledBy(secret, r.kbsConfig) {
+			continue
+		}
+		r.log.Info("Deleting KBS resources secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+		err = client.IgnoreNotFound(r.Client.Delete(ctx, secret))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kbsResourceReferencesSecret returns true if any KBS resource of the KbsConfig reads its content from the secret
+func kbsResourceReferencesSecret(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig, secretName string) bool {
+	for _, resource := range kbsConfig.Spec.KbsResources {
+		if resource.SecretKeyRef != nil && resource.SecretKeyRef.Name == secretName {
+			return true
+		}
+	}
+	return false
+}
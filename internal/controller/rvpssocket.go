@@ -0,0 +1,94 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// customRvpsSocket returns true if the RVPS socket differs from the default one
+func (r *KbsConfigReconciler) customRvpsSocket() bool {
+	return r.kbsConfig.Spec.KbsRvpsPort != 0 || r.kbsConfig.Spec.KbsRvpsAddress != ""
+}
+
+// rvpsListenPort returns the port the RVPS container listens on
+func (r *KbsConfigReconciler) rvpsListenPort() int32 {
+	if r.kbsConfig.Spec.KbsRvpsPort != 0 {
+		return r.kbsConfig.Spec.KbsRvpsPort
+	}
+	return rvpsPort
+}
+
+// rvpsSocket returns the address the RVPS container listens on
+func (r *KbsConfigReconciler) rvpsSocket() string {
+	host := r.kbsConfig.Spec.KbsRvpsAddress
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(r.rvpsListenPort())))
+}
+
+// validateKbsRvpsSocket checks the RVPS address and port, which must not be used by the other containers of the KBS pods
+func (r *KbsConfigReconciler) validateKbsRvpsSocket() error {
+	if !r.customRvpsSocket() {
+		return nil
+	}
+	spec := &r.kbsConfig.Spec
+	if spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		return fmt.Errorf("KbsRvpsPort and KbsRvpsAddress require the %s deployment type", confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	}
+	if !r.runsRvps() {
+		return fmt.Errorf("KbsRvpsPort and KbsRvpsAddress can't be combined with KbsExternalAsAddress or KbsExternalRvpsAddress")
+	}
+	if spec.KbsRvpsAddress != "" && net.ParseIP(spec.KbsRvpsAddress) == nil {
+		return fmt.Errorf("invalid KbsRvpsAddress %q: must be an IP address", spec.KbsRvpsAddress)
+	}
+	if spec.KbsRvpsPort < 0 || spec.KbsRvpsPort > 65535 {
+		return fmt.Errorf("invalid KbsRvpsPort %d", spec.KbsRvpsPort)
+	}
+	for _, port := range []int32{kbsServicePort, r.asListenPort()} {
+		if r.rvpsListenPort() == port {
+			return fmt.Errorf("KbsRvpsPort %d collides with a port already used by the KBS pod", port)
+		}
+	}
+	return nil
+}
+
+// rvpsSocketArgs returns the arguments binding the RVPS container to the configured socket
+func (r *KbsConfigReconciler) rvpsSocketArgs() []string {
+	if !r.customRvpsSocket() {
+		return nil
+	}
+	return []string{"--address", r.rvpsSocket()}
+}
+
+// rvpsSocketConfigOverrides returns the overrides pointing the AS at the RVPS socket, when it differs from the default one
+func (r *KbsConfigReconciler) rvpsSocketConfigOverrides() []configOverride {
+	if !r.customRvpsSocket() || !r.runsRvps() {
+		return nil
+	}
+	return []configOverride{
+		{
+			path:  []string{"rvps_config", "remote_addr"},
+			value: podLocalURL(r.rvpsSocket()),
+		},
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestReconcileRvpsSocket(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsRvpsPort = 50013
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var rvps corev1.Container
+	for _, container := range getTestDeployment(t, r).Spec.Template.Spec.Containers {
+		if container.Name == "rvps" {
+			rvps = container
+		}
+	}
+	if len(rvps.Ports) != 1 || rvps.Ports[0].ContainerPort != 50013 {
+		t.Errorf("expected the RVPS container port 50013, got %v", rvps.Ports)
+	}
+	if port := rvps.ReadinessProbe.TCPSocket.Port.IntValue(); port != 50013 {
+		t.Errorf("expected the RVPS readiness probe on port 50013, got %d", port)
+	}
+	if command := strings.Join(rvps.Command, " "); !strings.Contains(command, "--address 0.0.0.0:50013") {
+		t.Errorf("expected the RVPS to listen on 0.0.0.0:50013, got %q", command)
+	}
+	// the AS follows the RVPS port
+	if config := getTestRenderedConfig(t, r, "as-config"); !strings.Contains(config, "http://127.0.0.1:50013") {
+		t.Errorf("expected the AS to be pointed at the RVPS socket, got %s", config)
+	}
+}
+
+func TestValidateKbsRvpsSocket(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		address  string
+		port     int32
+		asSocket string
+		valid    bool
+	}{
+		{"default", "", 0, "", true},
+		{"custom port", "", 50013, "", true},
+		{"custom address", "127.0.0.1", 0, "", true},
+		{"hostname", "localhost", 0, "", false},
+		{"KBS port", "", 8080, "", false},
+		{"AS port", "", 50004, "", false},
+		{"moved AS port", "", 50010, "0.0.0.0:50010", false},
+	} {
+		r := newTestReconciler(t)
+		r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+		r.kbsConfig.Spec.KbsRvpsAddress = tc.address
+		r.kbsConfig.Spec.KbsRvpsPort = tc.port
+		r.kbsConfig.Spec.KbsAsSocket = tc.asSocket
+		err := r.validateKbsRvpsSocket()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+
+	// the RVPS only runs in a dedicated container for the microservices deployment
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne)
+	r.kbsConfig.Spec.KbsRvpsPort = 50013
+	if err := r.validateKbsRvpsSocket(); err == nil {
+		t.Errorf("expected an error for the %s deployment type", confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne)
+	}
+}
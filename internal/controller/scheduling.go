@@ -0,0 +1,142 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultKbsAffinity returns the affinity spreading the KBS replicas across nodes, or nil
+// for a single replica. The anti-affinity is preferred rather than required, so that
+// the replicas can still be scheduled on clusters with fewer nodes than replicas
+func defaultKbsAffinity(replicas int32, labels map[string]string) *corev1.Affinity {
+	if replicas <= 1 {
+		return nil
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: labels,
+						},
+						TopologyKey: corev1.LabelHostname,
+					},
+				},
+			},
+		},
+	}
+}
+
+// kbsAffinity returns the affinity of the KBS pods: the one in the KbsConfig spec, completed
+// with the default anti-affinity spreading the replicas when it does not define one
+func (r *KbsConfigReconciler) kbsAffinity(replicas int32, labels map[string]string) *corev1.Affinity {
+	affinity := r.kbsConfig.Spec.KbsAffinity
+	if affinity == nil {
+		return defaultKbsAffinity(replicas, labels)
+	}
+	if affinity.PodAntiAffinity != nil {
+		return affinity
+	}
+	affinity = affinity.DeepCopy()
+	if defaultAffinity := defaultKbsAffinity(replicas, labels); defaultAffinity != nil {
+		affinity.PodAntiAffinity = defaultAffinity.PodAntiAffinity
+	}
+	return affinity
+}
+
+// kbsTopologySpreadConstraints returns the topology spread constraints of the KbsConfig spec,
+// selecting the KBS pods when they don't define a label selector
+func (r *KbsConfigReconciler) kbsTopologySpreadConstraints(labels map[string]string) []corev1.TopologySpreadConstraint {
+	var constraints []corev1.TopologySpreadConstraint
+	for _, constraint := range r.kbsConfig.Spec.KbsTopologySpreadConstraints {
+		constraint := *constraint.DeepCopy()
+		if constraint.LabelSelector == nil {
+			constraint.LabelSelector = &metav1.LabelSelector{
+				MatchLabels: labels,
+			}
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints
+}
+
+// validateKbsTopologySpreadConstraints checks the skew, the topology key and the policy of the constraints
+func (r *KbsConfigReconciler) validateKbsTopologySpreadConstraints() error {
+	for _, constraint := range r.kbsConfig.Spec.KbsTopologySpreadConstraints {
+		if constraint.MaxSkew <= 0 {
+			return fmt.Errorf("invalid KbsTopologySpreadConstraints maxSkew %d: must be greater than zero", constraint.MaxSkew)
+		}
+		if errs := validation.IsQualifiedName(constraint.TopologyKey); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsTopologySpreadConstraints topologyKey %q: %v", constraint.TopologyKey, errs)
+		}
+		switch constraint.WhenUnsatisfiable {
+		case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+		default:
+			return fmt.Errorf("invalid KbsTopologySpreadConstraints whenUnsatisfiable %q: must be %s or %s",
+				constraint.WhenUnsatisfiable, corev1.DoNotSchedule, corev1.ScheduleAnyway)
+		}
+	}
+	return nil
+}
+
+// validateKbsSchedulerName checks that the scheduler name, when provided, is a valid name
+func (r *KbsConfigReconciler) validateKbsSchedulerName() error {
+	schedulerName := r.kbsConfig.Spec.KbsSchedulerName
+	if schedulerName == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(schedulerName); len(errs) != 0 {
+		return fmt.Errorf("invalid KbsSchedulerName %q: %v", schedulerName, errs)
+	}
+	return nil
+}
+
+// validateKbsPriorityClassName checks that the priority class name, when provided, is a valid name
+func (r *KbsConfigReconciler) validateKbsPriorityClassName() error {
+	priorityClassName := r.kbsConfig.Spec.KbsPriorityClassName
+	if priorityClassName == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(priorityClassName); len(errs) != 0 {
+		return fmt.Errorf("invalid KbsPriorityClassName %q: %v", priorityClassName, errs)
+	}
+	return nil
+}
+
+// checkPriorityClass logs a warning if the PriorityClass doesn't exist, since the KBS pods
+// are rejected by the API server until it gets created
+func (r *KbsConfigReconciler) checkPriorityClass(ctx context.Context, priorityClassName string) {
+	priorityClass := &schedulingv1.PriorityClass{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: priorityClassName}, priorityClass)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("WARNING: the PriorityClass doesn't exist, the KBS pods won't be created until it's created",
+			"PriorityClass.Name", priorityClassName)
+	} else if err != nil {
+		r.log.Info("Unable to check the PriorityClass", "PriorityClass.Name", priorityClassName, "err", err)
+	}
+}
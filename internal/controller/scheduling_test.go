@@ -0,0 +1,204 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestDefaultKbsAffinity(t *testing.T) {
+	labels := map[string]string{"app": "kbs"}
+
+	if affinity := defaultKbsAffinity(1, labels); affinity != nil {
+		t.Errorf("expected no affinity for a single replica, got %v", affinity)
+	}
+
+	affinity := defaultKbsAffinity(3, labels)
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		t.Fatalf("expected a pod anti-affinity for multiple replicas")
+	}
+	if len(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) != 0 {
+		t.Errorf("expected the anti-affinity to be preferred, not required")
+	}
+	terms := affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+	if len(terms) != 1 || terms[0].PodAffinityTerm.TopologyKey != corev1.LabelHostname ||
+		terms[0].PodAffinityTerm.LabelSelector.MatchLabels["app"] != "kbs" {
+		t.Errorf("unexpected anti-affinity terms %v", terms)
+	}
+}
+
+func TestValidateKbsSchedulerName(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	for _, schedulerName := range []string{"", "tee-scheduler"} {
+		r.kbsConfig.Spec.KbsSchedulerName = schedulerName
+		if err := r.validateKbsSchedulerName(); err != nil {
+			t.Errorf("unexpected error for KbsSchedulerName %q: %v", schedulerName, err)
+		}
+	}
+
+	r.kbsConfig.Spec.KbsSchedulerName = "TEE Scheduler"
+	if err := r.validateKbsSchedulerName(); err == nil {
+		t.Errorf("expected an error for an invalid scheduler name")
+	}
+}
+
+func TestReconcileSchedulingConstraints(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsNodeSelector = map[string]string{"amd.feature.node.kubernetes.io/snp": "true"}
+	kbsConfig.Spec.KbsTolerations = []corev1.Toleration{
+		{Key: "confidential", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	podSpec := getTestDeployment(t, r).Spec.Template.Spec
+	if podSpec.NodeSelector["amd.feature.node.kubernetes.io/snp"] != "true" {
+		t.Errorf("expected the node selector in the pod spec, got %v", podSpec.NodeSelector)
+	}
+	if len(podSpec.Tolerations) != 1 || podSpec.Tolerations[0].Key != "confidential" {
+		t.Errorf("expected the toleration in the pod spec, got %v", podSpec.Tolerations)
+	}
+
+	// the scheduling constraints are updated in the existing deployment
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsNodeSelector = map[string]string{"intel.feature.node.kubernetes.io/tdx": "true"}
+	kbsConfig.Spec.KbsTolerations = nil
+	kbsConfig.Spec.KbsReplicas = pointer(int32(2))
+	kbsConfig.Spec.KbsAffinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchExpressions: []corev1.NodeSelectorRequirement{
+						{Key: corev1.LabelArchStable, Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64"}},
+					},
+				}},
+			},
+		},
+	}
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	podSpec = getTestDeployment(t, r).Spec.Template.Spec
+	if len(podSpec.NodeSelector) != 1 || podSpec.NodeSelector["intel.feature.node.kubernetes.io/tdx"] != "true" {
+		t.Errorf("expected the updated node selector in the pod spec, got %v", podSpec.NodeSelector)
+	}
+	if len(podSpec.Tolerations) != 0 {
+		t.Errorf("expected no tolerations, got %v", podSpec.Tolerations)
+	}
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil {
+		t.Errorf("expected the node affinity in the pod spec")
+	} else if podSpec.Affinity.PodAntiAffinity == nil {
+		t.Errorf("expected the replicas to still be spread across the nodes")
+	}
+}
+
+func TestReconcileTopologySpreadConstraints(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsTopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: corev1.ScheduleAnyway},
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	constraints := getTestDeployment(t, r).Spec.Template.Spec.TopologySpreadConstraints
+	if len(constraints) != 1 || constraints[0].TopologyKey != corev1.LabelTopologyZone {
+		t.Fatalf("expected the zone spread constraint in the pod spec, got %v", constraints)
+	}
+	if selector := constraints[0].LabelSelector; selector == nil || selector.MatchLabels[kbsConfigLabel] != testKbsConfigName {
+		t.Errorf("expected the constraint to select the KBS pods, got %v", selector)
+	}
+
+	// the constraints are updated in the existing deployment
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsTopologySpreadConstraints = nil
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if constraints := getTestDeployment(t, r).Spec.Template.Spec.TopologySpreadConstraints; len(constraints) != 0 {
+		t.Errorf("expected no spread constraints, got %v", constraints)
+	}
+
+	for _, constraint := range []corev1.TopologySpreadConstraint{
+		{MaxSkew: 0, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: corev1.DoNotSchedule},
+		{MaxSkew: 1, TopologyKey: "", WhenUnsatisfiable: corev1.DoNotSchedule},
+		{MaxSkew: 1, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: "Never"},
+	} {
+		r.kbsConfig.Spec.KbsTopologySpreadConstraints = []corev1.TopologySpreadConstraint{constraint}
+		if err := r.validateKbsTopologySpreadConstraints(); err == nil {
+			t.Errorf("expected an error for the spread constraint %v", constraint)
+		}
+	}
+}
+
+func TestReconcilePriorityClassName(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsPriorityClassName = "kbs-critical"
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	// a missing PriorityClass is only reported, the deployment is created anyway
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if podSpec := getTestDeployment(t, r).Spec.Template.Spec; podSpec.PriorityClassName != "kbs-critical" {
+		t.Errorf("expected the priority class in the pod spec, got %q", podSpec.PriorityClassName)
+	}
+
+	// the priority class is updated in the existing deployment
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsPriorityClassName = ""
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if podSpec := getTestDeployment(t, r).Spec.Template.Spec; podSpec.PriorityClassName != "" {
+		t.Errorf("expected no priority class in the pod spec, got %q", podSpec.PriorityClassName)
+	}
+
+	r.kbsConfig.Spec.KbsPriorityClassName = "Kbs Critical"
+	if err := r.validateKbsPriorityClassName(); err == nil {
+		t.Errorf("expected an error for an invalid priority class name")
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// secretStoreCredentialKeys are the keys of the credentials secret required by every external store type
+var secretStoreCredentialKeys = map[confidentialcontainersorgv1alpha1.SecretStoreType][]string{
+	confidentialcontainersorgv1alpha1.SecretStoreTypeVault:     {"token"},
+	confidentialcontainersorgv1alpha1.SecretStoreTypeAliyunKms: {"client-key", "password", "ca.pem"},
+}
+
+// Default mount path of the Vault KV secrets engine
+const defaultVaultPath = "secret"
+
+// validateKbsExternalSecretStore checks that the external store gets the settings its type requires,
+// and that the resources aren't also served by the local repository
+func (r *KbsConfigReconciler) validateKbsExternalSecretStore() error {
+	store := r.kbsConfig.Spec.KbsExternalSecretStore
+	if store == nil {
+		return nil
+	}
+
+	if len(r.kbsConfig.Spec.KbsSecretResources) > 0 || r.kbsConfig.Spec.KbsSecretResourcesSelector != nil ||
+		len(r.kbsConfig.Spec.KbsSecretResourceMounts) > 0 || len(r.kbsConfig.Spec.KbsResources) > 0 {
+		return fmt.Errorf("KbsExternalSecretStore is exclusive to KbsSecretResources, KbsSecretResourcesSelector, " +
+			"KbsSecretResourceMounts and KbsResources")
+	}
+
+	endpoint, err := url.Parse(store.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return fmt.Errorf("KbsExternalSecretStore: invalid endpoint %q, an HTTPS URL is required", store.Endpoint)
+	}
+	if errs := validation.IsDNS1123Subdomain(store.CredentialsSecretName); len(errs) != 0 {
+		return fmt.Errorf("KbsExternalSecretStore: invalid credentials secret name %q: %v", store.CredentialsSecretName, errs)
+	}
+
+	switch store.Type {
+	case confidentialcontainersorgv1alpha1.SecretStoreTypeVault:
+		if store.InstanceId != "" {
+			return fmt.Errorf("KbsExternalSecretStore: the Vault store doesn't accept an instance id")
+		}
+	case confidentialcontainersorgv1alpha1.SecretStoreTypeAliyunKms:
+		if store.Path != "" {
+			return fmt.Errorf("KbsExternalSecretStore: the AliyunKms store doesn't accept a path")
+		}
+		if store.InstanceId == "" {
+			return fmt.Errorf("KbsExternalSecretStore: the AliyunKms store requires an instance id")
+		}
+	default:
+		return fmt.Errorf("KbsExternalSecretStore: invalid type %q", store.Type)
+	}
+	return nil
+}
+
+// secretStoreConfigOverrides returns the override replacing the KBS local repository with the external store
+func (r *KbsConfigReconciler) secretStoreConfigOverrides() []configOverride {
+	store := r.kbsConfig.Spec.KbsExternalSecretStore
+	if store == nil {
+		return nil
+	}
+
+	var repositoryConfig map[string]interface{}
+	switch store.Type {
+	case confidentialcontainersorgv1alpha1.SecretStoreTypeVault:
+		path := store.Path
+		if path == "" {
+			path = defaultVaultPath
+		}
+		repositoryConfig = map[string]interface{}{
+			"type":       "Vault",
+			"vault_url":  store.Endpoint,
+			"mount_path": path,
+			"token_path": filepath.Join(secretStoreCredentialsPath, "token"),
+		}
+	case confidentialcontainersorgv1alpha1.SecretStoreTypeAliyunKms:
+		repositoryConfig = map[string]interface{}{
+			"type":            "Aliyun",
+			"endpoint":        store.Endpoint,
+			"kms_instance_id": store.InstanceId,
+			"client_key_path": filepath.Join(secretStoreCredentialsPath, "client-key"),
+			"password_path":   filepath.Join(secretStoreCredentialsPath, "password"),
+			"cert_pem_path":   filepath.Join(secretStoreCredentialsPath, "ca.pem"),
+		}
+	}
+	return []configOverride{
+		{path: []string{"repository_config"}, value: repositoryConfig},
+	}
+}
+
+// createSecretStoreCredentialsVolume checks that the credentials secret holds the keys required
+// by the external store and returns the volume for mounting it
+func (r *KbsConfigReconciler) createSecretStoreCredentialsVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	store := r.kbsConfig.Spec.KbsExternalSecretStore
+	if store == nil {
+		return nil, fmt.Errorf("KbsExternalSecretStore hasn't been provided")
+	}
+
+	r.log.Info("Retrieving the external secret store credentials", "Secret.Namespace", r.namespace,
+		"Secret.Name", store.CredentialsSecretName)
+	foundSecret := &corev1.Secret{}
+	err := r.getReferencedObject(ctx, store.CredentialsSecretName, foundSecret)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range secretStoreCredentialKeys[store.Type] {
+		if len(foundSecret.Data[key]) == 0 {
+			return nil, fmt.Errorf("secret %s misses the %q key required by the %s store",
+				store.CredentialsSecretName, key, store.Type)
+		}
+	}
+
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: store.CredentialsSecretName,
+			},
+		},
+	}
+	return &volume, nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// appArmorProfileNameRegexp matches the name of an AppArmor profile loaded on the nodes
+	appArmorProfileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+	// seLinuxLabelRegexp matches the user, role and type of an SELinux label
+	seLinuxLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9_.]*$`)
+	// seLinuxLevelRegexp matches the level of an SELinux label (e.g. s0:c123,c456)
+	seLinuxLevelRegexp = regexp.MustCompile(`^(s[0-9]+(-s[0-9]+)?(:c[0-9]+([.,]c[0-9]+)*)?)?$`)
+)
+
+// validateKbsSecurityProfiles checks the AppArmor profile and the SELinux options of the KBS pods
+func (r *KbsConfigReconciler) validateKbsSecurityProfiles() error {
+	profile := r.kbsConfig.Spec.KbsAppArmorProfile
+	switch {
+	case profile == "", profile == corev1.AppArmorBetaProfileRuntimeDefault, profile == corev1.AppArmorBetaProfileNameUnconfined:
+	case strings.HasPrefix(profile, corev1.AppArmorBetaProfileNamePrefix):
+		if !appArmorProfileNameRegexp.MatchString(strings.TrimPrefix(profile, corev1.AppArmorBetaProfileNamePrefix)) {
+			return fmt.Errorf("invalid KbsAppArmorProfile %q: invalid profile name", profile)
+		}
+	default:
+		return fmt.Errorf("invalid KbsAppArmorProfile %q: must be %s, %s<profile> or %s", profile,
+			corev1.AppArmorBetaProfileRuntimeDefault, corev1.AppArmorBetaProfileNamePrefix, corev1.AppArmorBetaProfileNameUnconfined)
+	}
+
+	options := r.kbsConfig.Spec.KbsSELinuxOptions
+	if options == nil {
+		return nil
+	}
+	for field, value := range map[string]string{"user": options.User, "role": options.Role, "type": options.Type} {
+		if !seLinuxLabelRegexp.MatchString(value) {
+			return fmt.Errorf("invalid KbsSELinuxOptions %s %q", field, value)
+		}
+	}
+	if !seLinuxLevelRegexp.MatchString(options.Level) {
+		return fmt.Errorf("invalid KbsSELinuxOptions level %q", options.Level)
+	}
+	return nil
+}
+
+// appArmorAnnotations returns the pod annotations setting the AppArmor profile of every container, if any
+func (r *KbsConfigReconciler) appArmorAnnotations(initContainers []corev1.Container, containers []corev1.Container) map[string]string {
+	profile := r.kbsConfig.Spec.KbsAppArmorProfile
+	if profile == "" {
+		return nil
+	}
+	annotations := map[string]string{}
+	for _, container := range append(append([]corev1.Container{}, initContainers...), containers...) {
+		annotations[corev1.AppArmorBetaContainerAnnotationKeyPrefix+container.Name] = profile
+	}
+	return annotations
+}
+
+// podSecurityContext returns the security context of the KBS pods, running as non-root by default
+func (r *KbsConfigReconciler) podSecurityContext() *corev1.PodSecurityContext {
+	if r.kbsConfig.Spec.KbsPodSecurityContext != nil {
+		return r.kbsConfig.Spec.KbsPodSecurityContext
+	}
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: pointer(true),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// containerSecurityContext returns the security context of the containers of the KBS pods.
+// The hardened default runs with a read-only root filesystem when readOnlyRootFilesystem is true
+func (r *KbsConfigReconciler) containerSecurityContext(readOnlyRootFilesystem bool) *corev1.SecurityContext {
+	var securityContext *corev1.SecurityContext
+	if r.kbsConfig.Spec.KbsContainerSecurityContext != nil {
+		securityContext = r.kbsConfig.Spec.KbsContainerSecurityContext.DeepCopy()
+	} else {
+		securityContext = createSecurityContext()
+		securityContext.ReadOnlyRootFilesystem = pointer(readOnlyRootFilesystem)
+	}
+	if securityContext.SELinuxOptions == nil {
+		securityContext.SELinuxOptions = r.kbsConfig.Spec.KbsSELinuxOptions
+	}
+	return securityContext
+}
@@ -0,0 +1,101 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// validateKbsServiceAccountName checks that the service account name, when provided, is a valid name
+func (r *KbsConfigReconciler) validateKbsServiceAccountName() error {
+	serviceAccountName := r.kbsConfig.Spec.KbsServiceAccountName
+	if serviceAccountName == "" {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(serviceAccountName); len(errs) != 0 {
+		return fmt.Errorf("invalid KbsServiceAccountName %q: %v", serviceAccountName, errs)
+	}
+	return nil
+}
+
+// kbsServiceAccountName returns the name of the service account of the KBS pods
+func (r *KbsConfigReconciler) kbsServiceAccountName() string {
+	if r.kbsConfig.Spec.KbsServiceAccountName != "" {
+		return r.kbsConfig.Spec.KbsServiceAccountName
+	}
+	return r.kbsResourceName(KbsServiceAccountName)
+}
+
+// deployKbsServiceAccount creates the service account of the KBS pods, owned by the KbsConfig instance
+// The service account is deleted when the KbsConfig provides its own
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployKbsServiceAccount(ctx context.Context) error {
+	if r.kbsConfig.Spec.KbsServiceAccountName != "" {
+		return r.deleteKbsServiceAccount(ctx)
+	}
+
+	name := r.kbsResourceName(KbsServiceAccountName)
+	found := &corev1.ServiceAccount{}
+	err := r.Client.Get(ctx, client.ObjectKey{
+		Namespace: r.namespace,
+		Name:      name,
+	}, found)
+	if err == nil || !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	r.log.Info("Creating the KBS service account", "ServiceAccount.Namespace", r.namespace,
+		"ServiceAccount.Name", name)
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.namespace,
+		},
+		// KBS doesn't access the Kubernetes API
+		AutomountServiceAccountToken: pointer(false),
+	}
+	err = r.setKbsConfigOwner(serviceAccount)
+	if err != nil {
+		return err
+	}
+	return r.Client.Create(ctx, serviceAccount)
+}
+
+// deleteKbsServiceAccount deletes the service account created for the KBS pods, if present
+func (r *KbsConfigReconciler) deleteKbsServiceAccount(ctx context.Context) error {
+	serviceAccount := &corev1.ServiceAccount{}
+	err := r.Client.Get(ctx, client.ObjectKey{
+		Namespace: r.namespace,
+		Name:      r.kbsResourceName(KbsServiceAccountName),
+	}, serviceAccount)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	// Never delete a service account the operator didn't create
+	if !metav1.IsControlledBy(serviceAccount, r.kbsConfig) {
+		return nil
+	}
+	r.log.Info("Deleting the KBS service account")
+	return client.IgnoreNotFound(r.Client.Delete(ctx, serviceAccount))
+}
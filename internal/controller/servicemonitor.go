@@ -0,0 +1,117 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The ServiceMonitor kind of the Prometheus operator, handled as unstructured
+// so that the operator doesn't depend on the Prometheus operator API
+var serviceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+// kbsMetricsPath returns the HTTP path of the KBS metrics scraped by Prometheus
+func (r *KbsConfigReconciler) kbsMetricsPath() string {
+	if metrics := r.kbsConfig.Spec.KbsMetrics; metrics != nil && metrics.Path != "" {
+		return metrics.Path
+	}
+	return defaultKbsMetricsPath
+}
+
+// newKbsServiceMonitor returns the ServiceMonitor scraping the metrics port of the KBS service
+func (r *KbsConfigReconciler) newKbsServiceMonitor() (*unstructured.Unstructured, error) {
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	serviceMonitor.SetNamespace(r.namespace)
+	serviceMonitor.SetName(r.kbsServiceName())
+	selector := map[string]interface{}{}
+	for key, value := range r.kbsPodLabels() {
+		selector[key] = value
+	}
+	serviceMonitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": selector,
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port": "kbs-metrics-port",
+				"path": r.kbsMetricsPath(),
+			},
+		},
+	}
+	err := r.setKbsConfigOwner(serviceMonitor)
+	if err != nil {
+		return nil, err
+	}
+	return serviceMonitor, nil
+}
+
+// deployOrUpdateKbsServiceMonitor creates or updates the ServiceMonitor of the KBS metrics,
+// or deletes it when the metrics are not enabled.
+// Nothing is done when the ServiceMonitor CRD is not installed in the cluster
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsServiceMonitor(ctx context.Context) error {
+	metrics := r.kbsConfig.Spec.KbsMetrics
+	enabled := metrics != nil && metrics.Enabled
+
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(serviceMonitorGVK)
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsServiceName()}, found)
+	if meta.IsNoMatchError(err) {
+		if enabled {
+			r.log.Info("Warning: the ServiceMonitor CRD is not installed, the KBS metrics won't be scraped",
+				"KbsConfig.Name", r.kbsConfig.Name)
+		}
+		return nil
+	}
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	if !enabled {
+		if !exists || !metav1.IsControlledBy(found, r.kbsConfig) {
+			return nil
+		}
+		r.log.Info("Deleting the KBS ServiceMonitor", "ServiceMonitor.Namespace", r.namespace, "ServiceMonitor.Name", found.GetName())
+		return client.IgnoreNotFound(r.Client.Delete(ctx, found))
+	}
+
+	serviceMonitor, err := r.newKbsServiceMonitor()
+	if err != nil {
+		return err
+	}
+	if exists {
+		r.log.Info("Updating the KBS ServiceMonitor", "ServiceMonitor.Namespace", r.namespace, "ServiceMonitor.Name", found.GetName())
+		found.Object["spec"] = serviceMonitor.Object["spec"]
+		found.SetOwnerReferences(serviceMonitor.GetOwnerReferences())
+		return r.Client.Update(ctx, found)
+	}
+	r.log.Info("Creating the KBS ServiceMonitor", "ServiceMonitor.Namespace", r.namespace, "ServiceMonitor.Name", serviceMonitor.GetName())
+	return r.Client.Create(ctx, serviceMonitor)
+}
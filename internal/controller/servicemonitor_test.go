@@ -0,0 +1,122 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// withoutCRD rebuilds the client of the reconciler so that the given kind is unknown,
+// as if its CRD was not installed.
+// The fake client otherwise accepts the unstructured objects of any kind
+func withoutCRD(r *KbsConfigReconciler, gvk schema.GroupVersionKind, objs ...client.Object) {
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(objs...).
+		WithStatusSubresource(&confidentialcontainersorgv1alpha1.KbsConfig{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: emulateApplyPatch,
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if obj.GetObjectKind().GroupVersionKind() == gvk {
+					return &meta.NoKindMatchError{GroupKind: gvk.GroupKind(), SearchedVersions: []string{gvk.Version}}
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		}).Build()
+}
+
+func getTestServiceMonitor(r *KbsConfigReconciler) (*unstructured.Unstructured, error) {
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsServiceName}, serviceMonitor)
+	return serviceMonitor, err
+}
+
+func TestReconcileServiceMonitor(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsMetrics = &confidentialcontainersorgv1alpha1.KbsMetrics{Enabled: true}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	service := getTestService(t, r)
+	if len(service.Spec.Ports) != 2 || service.Spec.Ports[1].Port != defaultKbsMetricsPort {
+		t.Errorf("expected the default metrics port on the KBS service, got %v", service.Spec.Ports)
+	}
+	serviceMonitor, err := getTestServiceMonitor(r)
+	if err != nil {
+		t.Fatalf("getting the KBS ServiceMonitor: %v", err)
+	}
+	selector, _, _ := unstructured.NestedStringMap(serviceMonitor.Object, "spec", "selector", "matchLabels")
+	for key, value := range selector {
+		if service.Labels[key] != value {
+			t.Errorf("the ServiceMonitor selector %v doesn't match the KBS service labels %v", selector, service.Labels)
+		}
+	}
+	endpoints, _, _ := unstructured.NestedSlice(serviceMonitor.Object, "spec", "endpoints")
+	if len(endpoints) != 1 || endpoints[0].(map[string]interface{})["path"] != defaultKbsMetricsPath {
+		t.Errorf("unexpected ServiceMonitor endpoints %v", endpoints)
+	}
+	if len(serviceMonitor.GetOwnerReferences()) != 1 || serviceMonitor.GetOwnerReferences()[0].Name != testKbsConfigName {
+		t.Errorf("expected the ServiceMonitor to be owned by the KbsConfig, got %v", serviceMonitor.GetOwnerReferences())
+	}
+
+	// disabling the metrics deletes the ServiceMonitor
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsMetrics.Enabled = false
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := getTestServiceMonitor(r); err == nil {
+		t.Errorf("expected the KBS ServiceMonitor to be deleted")
+	}
+}
+
+func TestReconcileServiceMonitorWithoutCRD(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsMetrics = &confidentialcontainersorgv1alpha1.KbsMetrics{Enabled: true, Port: 9100}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t)
+	withoutCRD(r, serviceMonitorGVK, objs...)
+
+	// the metrics are still exposed, only the ServiceMonitor is skipped
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	servicePorts := getTestService(t, r).Spec.Ports
+	if len(servicePorts) != 2 || servicePorts[1].Port != 9100 {
+		t.Errorf("expected the metrics port on the KBS service, got %v", servicePorts)
+	}
+	if _, err := getTestServiceMonitor(r); !meta.IsNoMatchError(err) {
+		t.Errorf("expected no ServiceMonitor kind, got %v", err)
+	}
+}
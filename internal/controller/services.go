@@ -0,0 +1,280 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// Annotation of the services holding the keys of the annotations set by the operator, so that the ones
+// removed from the spec are deleted while the annotations set by other tools are preserved
+const managedAnnotationsAnnotation = "confidentialcontainers.org/managed-annotations"
+
+// createOrUpdateService creates the service or, if it already exists, updates it with the desired state
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) createOrUpdateService(ctx context.Context, service *corev1.Service) error {
+	found := &corev1.Service{}
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(service), found)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("Creating a new service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		service.Annotations = mergeServiceAnnotations(nil, service.Annotations)
+		err = r.Client.Create(ctx, service)
+		if err != nil {
+			return err
+		}
+		r.recordEvent(corev1.EventTypeNormal, eventReasonServiceCreated, "Created service %s", service.Name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	changed := serviceChanged(found, service)
+	if !changed && equality.Semantic.DeepEqual(found.Labels, service.Labels) &&
+		equality.Semantic.DeepEqual(found.OwnerReferences, service.OwnerReferences) {
+		return nil
+	}
+
+	// Apply the desired state to the found service, so that the fields
+	// assigned by the cluster (e.g. the ClusterIP) are preserved
+	r.log.Info("Updating the service", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+	found.Labels = service.Labels
+	found.Annotations = mergeServiceAnnotations(found.Annotations, service.Annotations)
+	found.Spec.Selector = service.Spec.Selector
+	found.Spec.Type = service.Spec.Type
+	preserveNodePorts(found, service)
+	found.Spec.Ports = service.Spec.Ports
+	found.Spec.LoadBalancerSourceRanges = service.Spec.LoadBalancerSourceRanges
+	found.OwnerReferences = service.OwnerReferences
+	err = r.Client.Update(ctx, found)
+	if err != nil {
+		return err
+	}
+	if changed {
+		r.recordEvent(corev1.EventTypeNormal, eventReasonServiceUpdated, "Updated service %s", service.Name)
+	}
+	return nil
+}
+
+// mergeServiceAnnotations returns the annotations of the found service updated with the desired ones.
+// The annotations previously set by the operator and no longer desired are removed, the others are preserved
+func mergeServiceAnnotations(found map[string]string, desired map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range found {
+		merged[key] = value
+	}
+	if managed, ok := found[managedAnnotationsAnnotation]; ok {
+		for _, key := range strings.Split(managed, ",") {
+			delete(merged, key)
+		}
+		delete(merged, managedAnnotationsAnnotation)
+	}
+
+	keys := make([]string, 0, len(desired))
+	for key, value := range desired {
+		merged[key] = value
+		keys = append(keys, key)
+	}
+	if len(keys) != 0 {
+		sort.Strings(keys)
+		merged[managedAnnotationsAnnotation] = strings.Join(keys, ",")
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// kbsServiceType returns the type of the KBS service, ClusterIP by default
+func (r *KbsConfigReconciler) kbsServiceType() corev1.ServiceType {
+	if r.kbsConfig.Spec.KbsServiceType == "" {
+		return corev1.ServiceTypeClusterIP
+	}
+	return r.kbsConfig.Spec.KbsServiceType
+}
+
+// validateKbsServiceType checks the type of the KBS service, its node port and load balancer source ranges
+func (r *KbsConfigReconciler) validateKbsServiceType() error {
+	switch r.kbsServiceType() {
+	case corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf("unknown KbsServiceType %q: must be %s, %s or %s", r.kbsConfig.Spec.KbsServiceType,
+			corev1.ServiceTypeClusterIP, corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer)
+	}
+	for _, sourceRange := range r.kbsConfig.Spec.KbsLoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(sourceRange); err != nil {
+			return fmt.Errorf("invalid KbsLoadBalancerSourceRanges entry %q: %w", sourceRange, err)
+		}
+	}
+	nodePort := r.kbsConfig.Spec.KbsNodePort
+	if nodePort == 0 {
+		return nil
+	}
+	if r.kbsServiceType() != corev1.ServiceTypeNodePort {
+		return fmt.Errorf("KbsNodePort requires KbsServiceType %s", corev1.ServiceTypeNodePort)
+	}
+	if nodePort < 0 || nodePort > 65535 {
+		return fmt.Errorf("invalid KbsNodePort %d", nodePort)
+	}
+	return nil
+}
+
+// preserveNodePorts keeps the node ports assigned by the cluster to the found service
+// for the desired ports that don't request a specific node port
+func preserveNodePorts(found *corev1.Service, desired *corev1.Service) {
+	if desired.Spec.Type != corev1.ServiceTypeNodePort && desired.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return
+	}
+	for i, port := range desired.Spec.Ports {
+		if port.NodePort != 0 {
+			continue
+		}
+		for _, foundPort := range found.Spec.Ports {
+			if foundPort.Name == port.Name {
+				desired.Spec.Ports[i].NodePort = foundPort.NodePort
+			}
+		}
+	}
+}
+
+// deleteKbsService deletes the KBS service, if present
+func (r *KbsConfigReconciler) deleteKbsService(ctx context.Context) error {
+	r.log.Info("Deleting the service", "Service.Namespace", r.namespace, "Service.Name", r.kbsServiceName())
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Name:      r.kbsServiceName(),
+		},
+	}
+	return client.IgnoreNotFound(r.Client.Delete(ctx, service))
+}
+
+// kbsServiceEndpointName returns the name of an additional KBS service endpoint
+func (r *KbsConfigReconciler) kbsServiceEndpointName(endpoint confidentialcontainersorgv1alpha1.KbsServiceEndpoint) string {
+	return r.kbsServiceName() + "-" + endpoint.NameSuffix
+}
+
+// validateKbsServiceEndpoints checks that the additional service endpoints have valid and unique names
+func (r *KbsConfigReconciler) validateKbsServiceEndpoints() error {
+	names := map[string]bool{
+		r.kbsServiceName(): true,
+	}
+	for _, endpoint := range r.kbsConfig.Spec.KbsServiceEndpoints {
+		name := r.kbsServiceEndpointName(endpoint)
+		if errs := validation.IsDNS1035Label(name); len(errs) != 0 {
+			return fmt.Errorf("invalid name suffix %q for KBS service endpoint: %v", endpoint.NameSuffix, errs)
+		}
+		if names[name] {
+			return fmt.Errorf("duplicated KBS service endpoint %s", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// deployOrUpdateKbsServiceEndpoints creates or updates the additional KBS service endpoints
+// and deletes the ones which have been removed from the spec
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsServiceEndpoints(ctx context.Context) error {
+	desired := map[string]bool{}
+	for _, endpoint := range r.kbsConfig.Spec.KbsServiceEndpoints {
+		service, err := r.newKbsServiceEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+		err = r.createOrUpdateService(ctx, service)
+		if err != nil {
+			return err
+		}
+		desired[service.Name] = true
+	}
+	return r.deleteKbsServiceEndpoints(ctx, desired)
+}
+
+// deleteKbsServiceEndpoints deletes the additional KBS service endpoints which are not in the keep set
+func (r *KbsConfigReconciler) deleteKbsServiceEndpoints(ctx context.Context, keep map[string]bool) error {
+	services := &corev1.ServiceList{}
+	err := r.Client.List(ctx, services, client.InNamespace(r.namespace), client.HasLabels{kbsServiceEndpointLabel})
+	if err != nil {
+		return err
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		// skip the ones of the other KbsConfig instances of the namespace
+		if keep[service.Name] || !metav1.IsControlledBy(service, r.kbsConfig) {
+			continue
+		}
+		r.log.Info("Deleting KBS service endpoint", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		err = client.IgnoreNotFound(r.Client.Delete(ctx, service))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newKbsServiceEndpoint returns the service for an additional KBS service endpoint
+func (r *KbsConfigReconciler) newKbsServiceEndpoint(endpoint confidentialcontainersorgv1alpha1.KbsServiceEndpoint) (*corev1.Service, error) {
+	serviceType := endpoint.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+	ports := endpoint.Ports
+	if len(ports) == 0 {
+		ports = []corev1.ServicePort{
+			{
+				Name:       "kbs-port",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       kbsServicePort,
+				TargetPort: intstr.FromInt(kbsServicePort),
+			},
+		}
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: r.namespace,
+			Name:      r.kbsServiceEndpointName(endpoint),
+			Labels: map[string]string{
+				kbsServiceEndpointLabel: endpoint.NameSuffix,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: r.kbsPodLabels(),
+			Type:     serviceType,
+			Ports:    ports,
+		},
+	}
+	// Set KbsConfig instance as the owner and controller
+	err := r.setKbsConfigOwner(service)
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// startupGatePollInterval is the interval between two checks of the required API group versions
+	startupGatePollInterval = 5 * time.Second
+	// startupGateRequeueAfter is the delay after which a KbsConfig is reconciled again while the startup gate is closed
+	startupGateRequeueAfter = 10 * time.Second
+)
+
+// StartupGate holds off the reconciliation until the startup delay has elapsed and the API group
+// versions the enabled features depend on (e.g. cert-manager.io/v1) are served by the API server
+// It runs as a manager runnable and reports the operator readiness
+type StartupGate struct {
+	// Delay is the time waited at startup before checking the required API group versions
+	Delay time.Duration
+	// RequiredGroupVersions are the API group versions which must be served before reconciling
+	RequiredGroupVersions []string
+	// Discovery is the client checking the API group versions served by the API server
+	Discovery discovery.DiscoveryInterface
+
+	log  logr.Logger
+	open atomic.Bool
+}
+
+// NewStartupGate returns a startup gate for the given delay and required API group versions
+func NewStartupGate(discoveryClient discovery.DiscoveryInterface, delay time.Duration,
+	requiredGroupVersions []string, log logr.Logger) *StartupGate {
+	return &StartupGate{
+		Delay:                 delay,
+		RequiredGroupVersions: requiredGroupVersions,
+		Discovery:             discoveryClient,
+		log:                   log,
+	}
+}
+
+// Start waits for the startup delay and then polls the API server until the required
+// API group versions are served, opening the gate
+func (g *StartupGate) Start(ctx context.Context) error {
+	if g.Delay > 0 {
+		g.log.Info("Delaying the reconciliation at startup", "delay", g.Delay)
+		select {
+		case <-time.After(g.Delay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	err := wait.PollUntilContextCancel(ctx, startupGatePollInterval, true, func(ctx context.Context) (bool, error) {
+		for _, groupVersion := range g.RequiredGroupVersions {
+			_, err := g.Discovery.ServerResourcesForGroupVersion(groupVersion)
+			if err != nil {
+				g.log.Info("Waiting for a required API group version", "groupVersion", groupVersion, "err", err)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		// the manager is stopping
+		return nil
+	}
+
+	g.log.Info("The startup dependencies are available, starting the reconciliation")
+	g.open.Store(true)
+	return nil
+}
+
+// NeedLeaderElection returns false, so that the gate reports the readiness of every replica
+func (g *StartupGate) NeedLeaderElection() bool {
+	return false
+}
+
+// IsOpen returns true once the startup dependencies are confirmed
+func (g *StartupGate) IsOpen() bool {
+	return g.open.Load()
+}
+
+// Check is a readiness check failing until the startup dependencies are confirmed
+func (g *StartupGate) Check(_ *http.Request) error {
+	if !g.IsOpen() {
+		return fmt.Errorf("waiting for the startup dependencies")
+	}
+	return nil
+}
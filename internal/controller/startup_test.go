@@ -0,0 +1,68 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestStartupGate(t *testing.T) {
+	discoveryClient := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	gate := NewStartupGate(discoveryClient, 0, []string{"cert-manager.io/v1"}, logr.Discard())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	if err := gate.Start(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gate.IsOpen() || gate.Check(nil) == nil {
+		t.Fatalf("the gate must stay closed while the required API is not served")
+	}
+
+	// the reconciliation is held off while the gate is closed
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+	r.StartupGate = gate
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsDeploymentName}, &appsv1.Deployment{})
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("the KBS deployment must not be created while the gate is closed, got %v", err)
+	}
+
+	discoveryClient.Resources = []*metav1.APIResourceList{{GroupVersion: "cert-manager.io/v1"}}
+	if err := gate.Start(context.TODO()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gate.IsOpen() || gate.Check(nil) != nil {
+		t.Errorf("the gate must open once the required API is served")
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// deploymentReplicas returns the desired number of replicas of a deployment
+func deploymentReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas != nil {
+		return *deployment.Spec.Replicas
+	}
+	return 1
+}
+
+// isKbsDeploymentReady returns true when the latest KBS deployment has been rolled out
+// and all its replicas are ready
+func isKbsDeploymentReady(deployment *appsv1.Deployment) bool {
+	replicas := deploymentReplicas(deployment)
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.ReadyReplicas == replicas
+}
+
+// notReadyContainers returns the trustee containers reported not ready by the running KBS pods
+// In the microservices deployment, KBS is only ready along with its AS and RVPS backends
+func (r *KbsConfigReconciler) notReadyContainers(ctx context.Context) ([]string, error) {
+	required := []string{"kbs"}
+	if r.runsAs() {
+		required = append(required, "as")
+	}
+	if r.runsRvps() {
+		required = append(required, "rvps")
+	}
+	pods := &corev1.PodList{}
+	err := r.Client.List(ctx, pods, client.InNamespace(r.namespace), client.MatchingLabels(r.kbsPodLabels()))
+	if err != nil {
+		return nil, err
+	}
+	var notReady []string
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			if !containerStatus.Ready && contains(required, containerStatus.Name) && !contains(notReady, containerStatus.Name) {
+				notReady = append(notReady, containerStatus.Name)
+			}
+		}
+	}
+	sort.Strings(notReady)
+	return notReady, nil
+}
+
+// kbsAccessURL returns the URL KBS is reachable at from outside of the cluster through the ingress
+// host or the service, or an empty string if KBS is not exposed externally or its load balancer is
+// still pending
+func (r *KbsConfigReconciler) kbsAccessURL(service *corev1.Service) string {
+	// The ingress host is the entry point chosen for the clients, with TLS terminated by the ingress
+	if kbsIngress := r.kbsConfig.Spec.KbsIngress; kbsIngress != nil {
+		if kbsIngress.TLSSecretName != "" {
+			return "https://" + kbsIngress.Host
+		}
+		return "http://" + kbsIngress.Host
+	}
+	if service.Spec.Type != corev1.ServiceTypeLoadBalancer {
+		return ""
+	}
+	ingress := service.Status.LoadBalancer.Ingress
+	if len(ingress) == 0 {
+		r.log.Info("The KBS load balancer is being provisioned", "Service.Namespace", service.Namespace, "Service.Name", service.Name)
+		return ""
+	}
+	host := ingress[0].Hostname
+	if host == "" {
+		host = ingress[0].IP
+	}
+	scheme := "http"
+	if r.isHttpsConfigPresent() {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(kbsServicePort))
+}
+
+// updateKbsConfigStatus reports the phase, the readiness, the deployment and service types
+// and the access URL of KBS in the KbsConfig status
+// It's called once all the KBS resources have been reconciled, hence it clears the Degraded condition
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) updateKbsConfigStatus(ctx context.Context) error {
+	status := r.kbsConfig.Status.DeepCopy()
+
+	status.DeploymentType = r.kbsConfig.Spec.KbsDeploymentType
+	if status.DeploymentType == "" {
+		status.DeploymentType = confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices
+	}
+	status.ServiceType = r.kbsServiceType()
+
+	deployment := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsDeploymentName()}, deployment)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	var notReady []string
+	if err == nil {
+		notReady, err = r.notReadyContainers(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	readyCondition := metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionReady,
+		ObservedGeneration: r.kbsConfig.Generation,
+	}
+	switch {
+	case err != nil:
+		status.IsReady = false
+		status.ReadyReplicas = 0
+		status.Phase = confidentialcontainersorgv1alpha1.KbsConfigPhasePending
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = "DeploymentNotFound"
+		readyCondition.Message = "The KBS deployment hasn't been created yet"
+	case isKbsDeploymentReady(deployment) && len(notReady) > 0:
+		status.IsReady = false
+		status.ReadyReplicas = deployment.Status.ReadyReplicas
+		status.Phase = confidentialcontainersorgv1alpha1.KbsConfigPhaseDeploying
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = "ContainersNotReady"
+		readyCondition.Message = fmt.Sprintf("The %s containers of the KBS pods are not ready", strings.Join(notReady, ", "))
+	case isKbsDeploymentReady(deployment):
+		status.IsReady = true
+		status.ReadyReplicas = deployment.Status.ReadyReplicas
+		status.Phase = confidentialcontainersorgv1alpha1.KbsConfigPhaseReady
+		readyCondition.Status = metav1.ConditionTrue
+		readyCondition.Reason = "DeploymentReady"
+		readyCondition.Message = "All the KBS replicas are up to date and ready"
+	default:
+		status.IsReady = false
+		status.ReadyReplicas = deployment.Status.ReadyReplicas
+		status.Phase = confidentialcontainersorgv1alpha1.KbsConfigPhaseDeploying
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = "DeploymentInProgress"
+		readyCondition.Message = fmt.Sprintf("%d of %d KBS replicas are up to date and ready",
+			deployment.Status.ReadyReplicas, deploymentReplicas(deployment))
+	}
+	meta.SetStatusCondition(&status.Conditions, readyCondition)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionDegraded,
+		ObservedGeneration: r.kbsConfig.Generation,
+		Status:             metav1.ConditionFalse,
+		Reason:             "ReconcileSucceeded",
+		Message:            "The KBS resources have been reconciled",
+	})
+
+	service := &corev1.Service{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: r.namespace, Name: r.kbsServiceName()}, service)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	status.KbsAccessURL = ""
+	if err == nil {
+		status.KbsAccessURL = r.kbsAccessURL(service)
+	}
+
+	if equality.Semantic.DeepEqual(*status, r.kbsConfig.Status) {
+		return nil
+	}
+	r.kbsConfig.Status = *status
+	return r.Status().Update(ctx, r.kbsConfig)
+}
+
+// reportReconcileFailure sets the Failed phase and the Degraded condition in the KbsConfig status,
+// so that the failure is visible to the users. The status update errors are only logged, the
+// reconciliation failure being returned to the caller anyway
+func (r *KbsConfigReconciler) reportReconcileFailure(ctx context.Context, reason string, reconcileErr error) {
+	// A stale write is not a failure, the reconciliation gets requeued by Reconcile
+	if isStaleObjectConflict(reconcileErr) {
+		return
+	}
+	// The missing RBAC permissions get a distinct reason, as they're fixed by the cluster administrator
+	var forbiddenErr *forbiddenReferenceError
+	if errors.As(reconcileErr, &forbiddenErr) {
+		reason = forbiddenErr.reason()
+	}
+	reconcileErrorsTotal.WithLabelValues(reason).Inc()
+	r.kbsConfig.Status.Phase = confidentialcontainersorgv1alpha1.KbsConfigPhaseFailed
+	meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionDegraded,
+		ObservedGeneration: r.kbsConfig.Generation,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            reconcileErr.Error(),
+	})
+	r.recordEvent(corev1.EventTypeWarning, reason, "%s", reconcileErr)
+	err := r.Status().Update(ctx, r.kbsConfig)
+	if err != nil {
+		r.log.Info("Error in reporting the reconcile failure in the KbsConfig status", "err", err)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kbsStorageClaimName returns the name of the PersistentVolumeClaim backing the KBS storage,
+// or an empty string if the KBS storage is in memory
+func (r *KbsConfigReconciler) kbsStorageClaimName() string {
+	if r.kbsConfig.Spec.KbsStorageClaimName != "" {
+		return r.kbsConfig.Spec.KbsStorageClaimName
+	}
+	if r.kbsConfig.Spec.KbsStorage != nil {
+		return r.kbsResourceName(KbsStorageClaimName)
+	}
+	return ""
+}
+
+// validateKbsStorage checks the volume claim name or the template of the persistent KBS storage
+func (r *KbsConfigReconciler) validateKbsStorage() error {
+	spec := &r.kbsConfig.Spec
+	if spec.KbsStorageClaimName != "" && spec.KbsStorage != nil {
+		return fmt.Errorf("KbsStorageClaimName and KbsStorage are mutually exclusive")
+	}
+	if spec.KbsStorageClaimName != "" {
+		if errs := validation.IsDNS1123Subdomain(spec.KbsStorageClaimName); len(errs) != 0 {
+			return fmt.Errorf("invalid KbsStorageClaimName %q: %v", spec.KbsStorageClaimName, errs)
+		}
+	}
+	if spec.KbsStorage == nil {
+		return nil
+	}
+	if spec.KbsStorage.Size.Sign() <= 0 {
+		return fmt.Errorf("invalid KbsStorage size %s: must be positive", spec.KbsStorage.Size.String())
+	}
+	for _, accessMode := range spec.KbsStorage.AccessModes {
+		switch accessMode {
+		case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany, corev1.ReadWriteOncePod:
+		default:
+			return fmt.Errorf("invalid KbsStorage access mode %q", accessMode)
+		}
+	}
+	return nil
+}
+
+// kbsStorageAccessMode returns the default access mode of the KBS storage: ReadWriteOnce, bound by most
+// storage classes, for a single KBS replica and ReadWriteMany for the volume to be shared by multiple replicas
+func (r *KbsConfigReconciler) kbsStorageAccessMode() corev1.PersistentVolumeAccessMode {
+	replicas := r.kbsConfig.Spec.KbsReplicas
+	if r.kbsConfig.Spec.KbsAutoscaling != nil || (replicas != nil && *replicas > 1) {
+		return corev1.ReadWriteMany
+	}
+	return corev1.ReadWriteOnce
+}
+
+// newKbsStorageClaim returns the PersistentVolumeClaim of the KBS storage rendered from the KbsStorage template
+func (r *KbsConfigReconciler) newKbsStorageClaim() (*corev1.PersistentVolumeClaim, error) {
+	storage := r.kbsConfig.Spec.KbsStorage
+	accessModes := storage.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{r.kbsStorageAccessMode()}
+	}
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.kbsStorageClaimName(),
+			Namespace: r.namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: storage.StorageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: storage.Size,
+				},
+			},
+		},
+	}
+	err := r.setKbsConfigOwner(claim)
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// deployOrUpdateKbsStorage creates the PersistentVolumeClaim of the KBS storage from the KbsStorage template
+// The claim spec is immutable once created, except for the size which is increased for the volume expansion
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) deployOrUpdateKbsStorage(ctx context.Context) error {
+	if r.kbsConfig.Spec.KbsStorage == nil {
+		return nil
+	}
+	claim, err := r.newKbsStorageClaim()
+	if err != nil {
+		return err
+	}
+
+	found := &corev1.PersistentVolumeClaim{}
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(claim), found)
+	if err != nil && k8serrors.IsNotFound(err) {
+		r.log.Info("Creating the KBS storage volume claim", "PersistentVolumeClaim.Namespace", r.namespace,
+			"PersistentVolumeClaim.Name", claim.Name)
+		return r.Client.Create(ctx, claim)
+	}
+	if err != nil {
+		return err
+	}
+
+	size := r.kbsConfig.Spec.KbsStorage.Size
+	if size.Cmp(found.Spec.Resources.Requests[corev1.ResourceStorage]) <= 0 {
+		return nil
+	}
+	r.log.Info("Expanding the KBS storage volume claim", "PersistentVolumeClaim.Namespace", r.namespace,
+		"PersistentVolumeClaim.Name", claim.Name, "Size", size.String())
+	if found.Spec.Resources.Requests == nil {
+		found.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	found.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	return r.Client.Update(ctx, found)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// getTestVolume returns the volume of the KBS pods with the given name
+func getTestVolume(t *testing.T, r *KbsConfigReconciler, name string) *corev1.Volume {
+	t.Helper()
+	for _, volume := range getTestDeployment(t, r).Spec.Template.Spec.Volumes {
+		if volume.Name == name {
+			return &volume
+		}
+	}
+	return nil
+}
+
+func TestReconcileStorageClaim(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	// the KBS storage is in memory by default
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	volume := getTestVolume(t, r, confidentialContainers)
+	if volume == nil || volume.EmptyDir == nil || volume.EmptyDir.Medium != corev1.StorageMediumMemory {
+		t.Fatalf("expected an in-memory volume, got %+v", volume)
+	}
+	if getTestVolume(t, r, defaultRepository) == nil {
+		t.Errorf("expected the in-memory default repository volume")
+	}
+
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsStorageClaimName = "kbs-data"
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	volume = getTestVolume(t, r, confidentialContainers)
+	if volume == nil || volume.EmptyDir != nil || volume.PersistentVolumeClaim == nil ||
+		volume.PersistentVolumeClaim.ClaimName != "kbs-data" {
+		t.Fatalf("expected the kbs-data volume claim instead of the emptyDir, got %+v", volume)
+	}
+	// the default repository is persisted along with the rest of the KBS storage
+	if volume := getTestVolume(t, r, defaultRepository); volume != nil {
+		t.Errorf("unexpected default repository volume %+v", volume)
+	}
+	// the claim provided by the user isn't managed by the operator
+	claim := &corev1.PersistentVolumeClaim{}
+	err := r.Client.Get(context.TODO(), client.ObjectKey{Namespace: KbsOperatorNamespace, Name: "kbs-data"}, claim)
+	if !k8serrors.IsNotFound(err) {
+		t.Errorf("expected the volume claim not to be created, got %v", err)
+	}
+}
+
+func TestReconcileStorageTemplate(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	kbsConfig.Spec.KbsStorage = &confidentialcontainersorgv1alpha1.KbsStorage{
+		Size:             resource.MustParse("1Gi"),
+		StorageClassName: pointer("nfs"),
+	}
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	getClaim := func() *corev1.PersistentVolumeClaim {
+		t.Helper()
+		claim := &corev1.PersistentVolumeClaim{}
+		key := client.ObjectKey{Namespace: KbsOperatorNamespace, Name: testKbsConfigName + "-" + KbsStorageClaimName}
+		if err := r.Client.Get(context.TODO(), key, claim); err != nil {
+			t.Fatalf("getting the KBS storage volume claim: %v", err)
+		}
+		return claim
+	}
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claim := getClaim()
+	if len(claim.Spec.AccessModes) != 1 || claim.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+		t.Errorf("expected the ReadWriteOnce access mode by default, got %v", claim.Spec.AccessModes)
+	}
+	if claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName != "nfs" {
+		t.Errorf("expected the nfs storage class, got %v", claim.Spec.StorageClassName)
+	}
+	if size := claim.Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != "1Gi" {
+		t.Errorf("expected a 1Gi volume, got %s", size.String())
+	}
+	if len(claim.OwnerReferences) != 1 || claim.OwnerReferences[0].Name != testKbsConfigName {
+		t.Errorf("expected the volume claim to be owned by the KbsConfig, got %v", claim.OwnerReferences)
+	}
+	volume := getTestVolume(t, r, confidentialContainers)
+	if volume == nil || volume.PersistentVolumeClaim == nil || volume.PersistentVolumeClaim.ClaimName != claim.Name {
+		t.Fatalf("expected the KBS storage to use the %s volume claim, got %+v", claim.Name, volume)
+	}
+
+	// the volume can only be expanded
+	for _, tc := range []struct {
+		size     string
+		expected string
+	}{
+		{"2Gi", "2Gi"},
+		{"500Mi", "2Gi"},
+	} {
+		if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+			t.Fatal(err)
+		}
+		kbsConfig.Spec.KbsStorage.Size = resource.MustParse(tc.size)
+		if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+			t.Fatal(err)
+		}
+		if err := reconcileKbsConfig(t, r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if size := getClaim().Spec.Resources.Requests[corev1.ResourceStorage]; size.String() != tc.expected {
+			t.Errorf("expected a %s volume after requesting %s, got %s", tc.expected, tc.size, size.String())
+		}
+	}
+}
+
+func TestValidateKbsStorage(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		spec  confidentialcontainersorgv1alpha1.KbsConfigSpec
+		valid bool
+	}{
+		{"in memory", confidentialcontainersorgv1alpha1.KbsConfigSpec{}, true},
+		{"claim", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorageClaimName: "kbs-data"}, true},
+		{"invalid claim", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorageClaimName: "KBS_data"}, false},
+		{"template", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorage: &confidentialcontainersorgv1alpha1.KbsStorage{
+			Size: resource.MustParse("1Gi"), AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}}}, true},
+		{"empty template", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorage: &confidentialcontainersorgv1alpha1.KbsStorage{}}, false},
+		{"invalid access mode", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorage: &confidentialcontainersorgv1alpha1.KbsStorage{
+			Size: resource.MustParse("1Gi"), AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteAll"}}}, false},
+		{"claim and template", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsStorageClaimName: "kbs-data",
+			KbsStorage: &confidentialcontainersorgv1alpha1.KbsStorage{Size: resource.MustParse("1Gi")}}, false},
+	} {
+		r := newTestReconciler(t)
+		r.kbsConfig = &confidentialcontainersorgv1alpha1.KbsConfig{Spec: tc.spec}
+		err := r.validateKbsStorage()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+	}
+}
+
+func TestKbsStorageAccessMode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     confidentialcontainersorgv1alpha1.KbsConfigSpec
+		expected corev1.PersistentVolumeAccessMode
+	}{
+		{"single replica", confidentialcontainersorgv1alpha1.KbsConfigSpec{}, corev1.ReadWriteOnce},
+		{"one replica", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsReplicas: pointer(int32(1))}, corev1.ReadWriteOnce},
+		{"multiple replicas", confidentialcontainersorgv1alpha1.KbsConfigSpec{KbsReplicas: pointer(int32(3))}, corev1.ReadWriteMany},
+		{"autoscaling", confidentialcontainersorgv1alpha1.KbsConfigSpec{
+			KbsAutoscaling: &confidentialcontainersorgv1alpha1.KbsAutoscaling{MaxReplicas: 3}}, corev1.ReadWriteMany},
+	} {
+		r := newTestReconciler(t)
+		r.kbsConfig = &confidentialcontainersorgv1alpha1.KbsConfig{Spec: tc.spec}
+		if accessMode := r.kbsStorageAccessMode(); accessMode != tc.expected {
+			t.Errorf("%s: expected the %s access mode, got %s", tc.name, tc.expected, accessMode)
+		}
+	}
+}
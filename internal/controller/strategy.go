@@ -0,0 +1,68 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultKbsDeploymentStrategy returns the strategy rolling out the KBS pods one at a time
+func defaultKbsDeploymentStrategy() appsv1.DeploymentStrategy {
+	maxUnavailable := intstr.FromInt32(1)
+	return appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+}
+
+// kbsDeploymentStrategy returns the deployment strategy in the KbsConfig spec, defaulted to the
+// rolling update of one pod at a time, also when the rolling update parameters are not provided
+func (r *KbsConfigReconciler) kbsDeploymentStrategy() appsv1.DeploymentStrategy {
+	strategy := r.kbsConfig.Spec.KbsDeploymentStrategy
+	if strategy == nil || strategy.Type == "" {
+		return defaultKbsDeploymentStrategy()
+	}
+	if strategy.Type == appsv1.RollingUpdateDeploymentStrategyType && strategy.RollingUpdate == nil {
+		return defaultKbsDeploymentStrategy()
+	}
+	return *strategy.DeepCopy()
+}
+
+// validateKbsDeploymentStrategy checks the strategy type and that the rolling update parameters
+// are only provided for the RollingUpdate strategy
+func (r *KbsConfigReconciler) validateKbsDeploymentStrategy() error {
+	strategy := r.kbsConfig.Spec.KbsDeploymentStrategy
+	if strategy == nil {
+		return nil
+	}
+	switch strategy.Type {
+	case "", appsv1.RollingUpdateDeploymentStrategyType:
+	case appsv1.RecreateDeploymentStrategyType:
+		if strategy.RollingUpdate != nil {
+			return fmt.Errorf("KbsDeploymentStrategy: rollingUpdate can't be set with the %s strategy", strategy.Type)
+		}
+	default:
+		return fmt.Errorf("unknown KbsDeploymentStrategy type %q: must be %s or %s", strategy.Type,
+			appsv1.RecreateDeploymentStrategyType, appsv1.RollingUpdateDeploymentStrategyType)
+	}
+	return nil
+}
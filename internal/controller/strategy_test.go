@@ -0,0 +1,109 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+func TestValidateKbsDeploymentStrategy(t *testing.T) {
+	r := newTestReconciler(t)
+	r.kbsConfig = newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+
+	maxSurge := intstr.FromString("50%")
+	for _, strategy := range []*appsv1.DeploymentStrategy{
+		nil,
+		{Type: appsv1.RecreateDeploymentStrategyType},
+		{Type: appsv1.RollingUpdateDeploymentStrategyType},
+		{Type: appsv1.RollingUpdateDeploymentStrategyType, RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge}},
+	} {
+		r.kbsConfig.Spec.KbsDeploymentStrategy = strategy
+		if err := r.validateKbsDeploymentStrategy(); err != nil {
+			t.Errorf("unexpected error for strategy %v: %v", strategy, err)
+		}
+	}
+
+	for _, strategy := range []*appsv1.DeploymentStrategy{
+		{Type: "BlueGreen"},
+		{Type: appsv1.RecreateDeploymentStrategyType, RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge}},
+	} {
+		r.kbsConfig.Spec.KbsDeploymentStrategy = strategy
+		if err := r.validateKbsDeploymentStrategy(); err == nil {
+			t.Errorf("expected an error for strategy %v", strategy)
+		}
+	}
+}
+
+func TestReconcileKbsDeploymentStrategy(t *testing.T) {
+	kbsConfig := newTestKbsConfig(confidentialcontainersorgv1alpha1.DeploymentTypeMicroservices)
+	objs := append(newTestReferencedObjects(), kbsConfig)
+	r := newTestReconciler(t, objs...)
+
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	strategy := getTestDeployment(t, r).Spec.Strategy
+	if strategy.Type != appsv1.RollingUpdateDeploymentStrategyType || strategy.RollingUpdate == nil ||
+		strategy.RollingUpdate.MaxUnavailable.IntValue() != 1 {
+		t.Errorf("expected the default rolling update strategy, got %v", strategy)
+	}
+
+	// the Recreate strategy replaces the rolling update in the existing deployment
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	kbsConfig.Spec.KbsDeploymentStrategy = &appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	strategy = getTestDeployment(t, r).Spec.Strategy
+	if strategy.Type != appsv1.RecreateDeploymentStrategyType || strategy.RollingUpdate != nil {
+		t.Errorf("expected the Recreate strategy, got %v", strategy)
+	}
+
+	// the rolling update parameters are applied
+	if err := r.Client.Get(context.TODO(), client.ObjectKeyFromObject(kbsConfig), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	maxSurge := intstr.FromString("50%")
+	maxUnavailable := intstr.FromInt32(0)
+	kbsConfig.Spec.KbsDeploymentStrategy = &appsv1.DeploymentStrategy{
+		Type:          appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable},
+	}
+	if err := r.Client.Update(context.TODO(), kbsConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := reconcileKbsConfig(t, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	strategy = getTestDeployment(t, r).Spec.Strategy
+	if strategy.Type != appsv1.RollingUpdateDeploymentStrategyType || strategy.RollingUpdate == nil ||
+		strategy.RollingUpdate.MaxSurge.String() != "50%" || strategy.RollingUpdate.MaxUnavailable.IntValue() != 0 {
+		t.Errorf("expected the rolling update parameters, got %v", strategy)
+	}
+}
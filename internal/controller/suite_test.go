@@ -17,6 +17,7 @@ limitations under the License.
 package controllers
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -50,9 +51,15 @@ func TestAPIs(t *testing.T) {
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
+	// The API server and etcd binaries are set up by "make test"
+	if !envtestAssetsAvailable() {
+		Skip("the envtest binaries are not available, set KUBEBUILDER_ASSETS or run make test")
+	}
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		// the CRDs of the operator, and the CRDs of the optional integrations (e.g. the Prometheus operator)
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases"), filepath.Join("testdata", "crds")},
 		ErrorIfCRDPathMissing: true,
 	}
 
@@ -74,7 +81,21 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
+	if testEnv == nil {
+		return
+	}
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
 })
+
+// envtestAssetsAvailable returns true if the envtest binaries are found in KUBEBUILDER_ASSETS,
+// or in the default location of envtest
+func envtestAssetsAvailable() bool {
+	assets := os.Getenv("KUBEBUILDER_ASSETS")
+	if assets == "" {
+		assets = filepath.Join("/usr", "local", "kubebuilder", "bin")
+	}
+	_, err := os.Stat(filepath.Join(assets, "kube-apiserver"))
+	return err == nil
+}
@@ -0,0 +1,279 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+// validateKbsConfig checks the KbsConfig spec before any resource gets created or updated
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) validateKbsConfig() error {
+	// names of the KBS resources
+	err := r.validateKbsResourceNames()
+	if err != nil {
+		return err
+	}
+
+	// auth secrets
+	err = r.validateKbsAuthSecretNames()
+	if err != nil {
+		return err
+	}
+
+	// certificate issued by cert-manager
+	err = r.validateKbsHttpsCertManager()
+	if err != nil {
+		return err
+	}
+
+	// certificate SANs
+	_, _, err = r.certificateSANs()
+	if err != nil {
+		return err
+	}
+
+	// external AS and RVPS
+	err = r.validateKbsExternalEndpoints()
+	if err != nil {
+		return err
+	}
+
+	// persistent KBS storage
+	err = r.validateKbsStorage()
+	if err != nil {
+		return err
+	}
+
+	// AS socket
+	err = r.validateKbsAsSocket()
+	if err != nil {
+		return err
+	}
+
+	// RVPS socket
+	err = r.validateKbsRvpsSocket()
+	if err != nil {
+		return err
+	}
+
+	// service type
+	err = r.validateKbsServiceType()
+	if err != nil {
+		return err
+	}
+
+	// metrics port
+	err = r.validateKbsMetricsPort()
+	if err != nil {
+		return err
+	}
+
+	// additional KBS ports
+	err = r.validateKbsPorts()
+	if err != nil {
+		return err
+	}
+
+	// attestation policy
+	err = r.validateAttestationPolicy()
+	if err != nil {
+		return err
+	}
+
+	// resource policy
+	err = r.validateResourcePolicyRules()
+	if err != nil {
+		return err
+	}
+	err = r.validateKbsPolicyConfigMap()
+	if err != nil {
+		return err
+	}
+
+	// service endpoints
+	err = r.validateKbsServiceEndpoints()
+	if err != nil {
+		return err
+	}
+
+	// KBS resources
+	err = r.validateKbsResources()
+	if err != nil {
+		return err
+	}
+
+	// host aliases
+	err = r.validateKbsHostAliases()
+	if err != nil {
+		return err
+	}
+
+	// log level
+	err = r.validateKbsLogLevel()
+	if err != nil {
+		return err
+	}
+
+	// mount paths
+	err = r.validateKbsMountPaths()
+	if err != nil {
+		return err
+	}
+
+	// environment variables
+	err = r.validateKbsEnvVars()
+	if err != nil {
+		return err
+	}
+
+	// container commands
+	err = r.validateKbsCommands()
+	if err != nil {
+		return err
+	}
+
+	// init containers
+	err = r.validateKbsInitContainers()
+	if err != nil {
+		return err
+	}
+
+	// extra volumes
+	err = r.validateKbsExtraVolumes()
+	if err != nil {
+		return err
+	}
+
+	// image pull policy
+	err = r.validateKbsImagePullPolicy()
+	if err != nil {
+		return err
+	}
+
+	// container resources
+	err = r.validateKbsContainerResources()
+	if err != nil {
+		return err
+	}
+
+	// topology spread constraints
+	err = r.validateKbsTopologySpreadConstraints()
+	if err != nil {
+		return err
+	}
+
+	// scheduler name
+	err = r.validateKbsSchedulerName()
+	if err != nil {
+		return err
+	}
+
+	// priority class name
+	err = r.validateKbsPriorityClassName()
+	if err != nil {
+		return err
+	}
+
+	// service account name
+	err = r.validateKbsServiceAccountName()
+	if err != nil {
+		return err
+	}
+
+	// security profiles
+	err = r.validateKbsSecurityProfiles()
+	if err != nil {
+		return err
+	}
+
+	// adoption label
+	err = r.validateKbsAdoptionLabel()
+	if err != nil {
+		return err
+	}
+
+	// audit log
+	err = r.validateKbsAuditLog()
+	if err != nil {
+		return err
+	}
+
+	// secret resources discovery
+	err = r.validateKbsSecretResourcesSelector()
+	if err != nil {
+		return err
+	}
+
+	// secret resources with a sub-path and key-to-path mapping
+	err = r.validateKbsSecretResourceMounts()
+	if err != nil {
+		return err
+	}
+
+	// external secret store
+	err = r.validateKbsExternalSecretStore()
+	if err != nil {
+		return err
+	}
+
+	// Git repository of the resources
+	err = r.validateKbsGitResources()
+	if err != nil {
+		return err
+	}
+
+	// maintenance windows
+	err = r.validateKbsMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+
+	// drain on termination
+	err = r.validateKbsDrain()
+	if err != nil {
+		return err
+	}
+
+	// images of the trustee components
+	err = r.kbsConfig.Spec.ValidateImageNames()
+	if err != nil {
+		return err
+	}
+
+	// KBS HTTP server settings
+	err = r.kbsConfig.Spec.ValidateKbsServerSettings()
+	if err != nil {
+		return err
+	}
+
+	// horizontal pod autoscaling
+	err = r.validateKbsAutoscaling()
+	if err != nil {
+		return err
+	}
+
+	// deployment strategy
+	err = r.validateKbsDeploymentStrategy()
+	if err != nil {
+		return err
+	}
+
+	// ingress
+	err = r.validateKbsIngress()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
+)
+
+// imageVersionRegexp matches the image tags carrying a release version (e.g. v0.10.1 or 0.10)
+var imageVersionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)(\.\d+)?$`)
+
+// imageVersion returns the major.minor release version of an image, taken from its tag.
+// It returns false for the images referenced by digest or by a tag which is not a version (e.g. latest)
+func imageVersion(imageName string) (string, bool) {
+	if strings.Contains(imageName, "@") {
+		return "", false
+	}
+	// the tag follows the last colon, unless the colon belongs to the registry host (e.g. localhost:5000/kbs)
+	i := strings.LastIndex(imageName, ":")
+	if i < 0 || strings.Contains(imageName[i+1:], "/") {
+		return "", false
+	}
+	match := imageVersionRegexp.FindStringSubmatch(imageName[i+1:])
+	if match == nil {
+		return "", false
+	}
+	return match[1] + "." + match[2], true
+}
+
+// imageVersionsCondition returns the condition reporting whether the images of the trustee components
+// share the same release version
+func (r *KbsConfigReconciler) imageVersionsCondition() (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:               confidentialcontainersorgv1alpha1.ConditionImageVersionsAligned,
+		ObservedGeneration: r.kbsConfig.Generation,
+	}
+	// in the DeploymentTypeAllInOne case a single image runs all the components
+	if r.kbsConfig.Spec.KbsDeploymentType == confidentialcontainersorgv1alpha1.DeploymentTypeAllInOne {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SingleImage"
+		condition.Message = "All the trustee components run from the same image"
+		return condition, nil
+	}
+
+	images := map[string]struct {
+		specImageName    string
+		envVariable      string
+		defaultImageName string
+	}{
+		"kbs":  {r.kbsConfig.Spec.KbsImageName, "KBS_IMAGE_NAME", DefaultKbsImageName},
+		"as":   {r.kbsConfig.Spec.KbsAsImageName, "AS_IMAGE_NAME", DefaultAsImageName},
+		"rvps": {r.kbsConfig.Spec.KbsRvpsImageName, "RVPS_IMAGE_NAME", DefaultRvpsImageName},
+	}
+	versions := map[string]string{}
+	var components []string
+	for component, image := range images {
+		imageName, err := getImageName(image.specImageName, image.envVariable, image.defaultImageName)
+		if err != nil {
+			return condition, err
+		}
+		version, ok := imageVersion(imageName)
+		if !ok {
+			condition.Status = metav1.ConditionUnknown
+			condition.Reason = "VersionUnknown"
+			condition.Message = fmt.Sprintf("The release version of the %s image %s can't be determined from its tag",
+				component, imageName)
+			return condition, nil
+		}
+		versions[component] = version
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		if versions[component] != versions[components[0]] {
+			var details []string
+			for _, component := range components {
+				details = append(details, component+" "+versions[component])
+			}
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "VersionSkew"
+			condition.Message = "The trustee component images have different release versions (" +
+				strings.Join(details, ", ") + "), which may be incompatible"
+			return condition, nil
+		}
+	}
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = "VersionsAligned"
+	condition.Message = "The trustee component images share the release version " + versions[components[0]]
+	return condition, nil
+}
+
+// updateImageVersionsCondition checks the release versions of the trustee component images.
+// A version skew is reported as a warning and in the KbsConfig status, without blocking the deployment
+// Errors are logged by the callee and hence no error is logged in this method
+func (r *KbsConfigReconciler) updateImageVersionsCondition(ctx context.Context) error {
+	condition, err := r.imageVersionsCondition()
+	if err != nil {
+		return err
+	}
+	if condition.Status == metav1.ConditionFalse {
+		r.log.Info("WARNING: possible version skew between the trustee components", "details", condition.Message)
+	}
+	if !meta.SetStatusCondition(&r.kbsConfig.Status.Conditions, condition) {
+		return nil
+	}
+	return r.Status().Update(ctx, r.kbsConfig)
+}
@@ -0,0 +1,37 @@
+/*
+Copyright Confidential Containers Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+)
+
+func TestImageVersion(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/confidential-containers/key-broker-service:v0.10.1": "0.10",
+		"localhost:5000/kbs:1.2":                     "1.2",
+		"ghcr.io/confidential-containers/kbs:latest": "",
+		"localhost:5000/kbs":                         "",
+		"ghcr.io/confidential-containers/kbs@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef": "",
+	}
+	for imageName, expected := range tests {
+		version, ok := imageVersion(imageName)
+		if version != expected || ok != (expected != "") {
+			t.Errorf("imageVersion(%q) = %q, %v, expected %q", imageName, version, ok, expected)
+		}
+	}
+}
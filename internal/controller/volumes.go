@@ -19,12 +19,99 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	confidentialcontainersorgv1alpha1 "github.com/confidential-containers/trustee-operator/api/v1alpha1"
 )
 
+// forbiddenReferenceError reports a referenced object the operator lacks the RBAC permissions to read,
+// wrapping the Forbidden error
+type forbiddenReferenceError struct {
+	kind      string
+	namespace string
+	name      string
+	err       error
+}
+
+func (e *forbiddenReferenceError) Error() string {
+	return fmt.Sprintf("the operator lacks the RBAC permissions to read %s %s/%s, "+
+		"please grant get/list/watch on %ss to the operator service account: %v", e.kind, e.namespace, e.name, e.kind, e.err)
+}
+
+func (e *forbiddenReferenceError) Unwrap() error {
+	return e.err
+}
+
+// reason returns the condition reason of the error, e.g. ReferencedSecretForbidden
+func (e *forbiddenReferenceError) reason() string {
+	return "Referenced" + e.kind + "Forbidden"
+}
+
+// getReferencedObject retrieves a ConfigMap or a Secret referenced by the KbsConfig instance.
+// The object is read from the API server when the APIReader is set: the informers of the cache
+// don't sync without the list/watch permissions, which would stall the read instead of failing it
+// A Forbidden error is turned into a forbiddenReferenceError, since it's caused by
+// missing RBAC permissions rather than by a wrong reference
+func (r *KbsConfigReconciler) getReferencedObject(ctx context.Context, name string, obj client.Object) error {
+	kind := "object"
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		kind = resourceKindConfigMap
+	case *corev1.Secret:
+		kind = resourceKindSecret
+	}
+	defer observeResolution(kind, time.Now())
+
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	err := reader.Get(ctx, client.ObjectKey{
+		Namespace: r.namespace,
+		Name:      name,
+	}, obj)
+	if err != nil && k8serrors.IsForbidden(err) {
+		r.log.Info("The operator lacks the RBAC permissions to read a referenced resource", "Kind", kind,
+			"Namespace", r.namespace, "Name", name)
+		return &forbiddenReferenceError{kind: kind, namespace: r.namespace, name: name, err: err}
+	}
+	return err
+}
+
 func (r *KbsConfigReconciler) createConfidentialContainersVolume(volumeName string) (*corev1.Volume, error) {
+	// the KBS storage is persisted in a volume claim when configured, otherwise it's in memory
+	if claimName := r.kbsStorageClaimName(); claimName != "" {
+		volume := corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: claimName,
+				},
+			},
+		}
+		return &volume, nil
+	}
+	volume := corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium: corev1.StorageMediumMemory,
+			},
+		},
+	}
+	return &volume, nil
+}
+
+func (r *KbsConfigReconciler) createTmpVolume(volumeName string) (*corev1.Volume, error) {
 	volume := corev1.Volume{
 		Name: volumeName,
 		VolumeSource: corev1.VolumeSource{
@@ -52,10 +139,21 @@ func (r *KbsConfigReconciler) createKbsConfigMapVolume(ctx context.Context, volu
 	if r.kbsConfig.Spec.KbsConfigMapName != "" {
 		r.log.Info("Retrieving details for KbsConfigMap", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name", r.kbsConfig.Spec.KbsConfigMapName)
 		foundConfigMap := &corev1.ConfigMap{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsConfigMapName,
-		}, foundConfigMap)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsConfigMapName, foundConfigMap)
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.validateKbsConfigContent(foundConfigMap, volumeName+".json")
+		if err != nil {
+			return nil, err
+		}
+
+		overrides, err := r.kbsConfigOverrides(ctx)
+		if err != nil {
+			return nil, err
+		}
+		configMapName, err := r.renderConfigMap(ctx, foundConfigMap, volumeName, overrides)
 		if err != nil {
 			return nil, err
 		}
@@ -65,7 +163,7 @@ func (r *KbsConfigReconciler) createKbsConfigMapVolume(ctx context.Context, volu
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: r.kbsConfig.Spec.KbsConfigMapName,
+						Name: configMapName,
 					},
 				},
 			},
@@ -79,10 +177,7 @@ func (r *KbsConfigReconciler) createAuthSecretVolume(ctx context.Context, volume
 	if r.kbsConfig.Spec.KbsAuthSecretName != "" {
 		r.log.Info("Retrieving details for KbsAuthSecret", "Secret.Namespace", r.namespace, "Secret.Name", r.kbsConfig.Spec.KbsAuthSecretName)
 		foundSecret := &corev1.Secret{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsAuthSecretName,
-		}, foundSecret)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsAuthSecretName, foundSecret)
 		if err != nil {
 			return nil, err
 		}
@@ -101,15 +196,15 @@ func (r *KbsConfigReconciler) createAuthSecretVolume(ctx context.Context, volume
 }
 
 func (r *KbsConfigReconciler) createHttpsKeyVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	if r.kbsConfig.Spec.KbsHttpsCertManager != nil {
+		return r.createCertManagerHttpsVolume(volumeName, corev1.TLSPrivateKeyKey, httpsKeyFileName), nil
+	}
 	if r.kbsConfig.Spec.KbsHttpsKeySecretName != "" {
 		r.log.Info("Retrieving details for KbsHttpsKeySecret", "Secret.Namespace", r.namespace, "Secret.Name",
 			r.kbsConfig.Spec.KbsHttpsKeySecretName)
 		// get the https key and append to volumes
 		foundHttpsKeySecret := &corev1.Secret{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsHttpsKeySecretName,
-		}, foundHttpsKeySecret)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsHttpsKeySecretName, foundHttpsKeySecret)
 		if err != nil {
 			return nil, err
 		}
@@ -129,6 +224,9 @@ func (r *KbsConfigReconciler) createHttpsKeyVolume(ctx context.Context, volumeNa
 }
 
 func (r *KbsConfigReconciler) createHttpsCertVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
+	if r.kbsConfig.Spec.KbsHttpsCertManager != nil {
+		return r.createCertManagerHttpsVolume(volumeName, corev1.TLSCertKey, httpsCertFileName), nil
+	}
 	if r.kbsConfig.Spec.KbsHttpsCertSecretName != "" {
 		// get the https key and append to volumes
 		r.log.Info("Retrieving details for KbsHttpsCertSecret", "Secret.Namespace", r.namespace, "Secret.Name",
@@ -136,10 +234,7 @@ func (r *KbsConfigReconciler) createHttpsCertVolume(ctx context.Context, volumeN
 
 		// get the https certificate and append to volumes
 		foundHttpsCertSecret := &corev1.Secret{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsHttpsCertSecretName,
-		}, foundHttpsCertSecret)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsHttpsCertSecretName, foundHttpsCertSecret)
 		if err != nil {
 			return nil, err
 		}
@@ -159,47 +254,210 @@ func (r *KbsConfigReconciler) createHttpsCertVolume(ctx context.Context, volumeN
 }
 
 // Method to add KbsSecretResources to the KBS volumes
+// The secrets matching KbsSecretResourcesSelector are added along with the listed ones
 func (r *KbsConfigReconciler) createKbsSecretResourcesVolume(ctx context.Context) ([]corev1.Volume, error) {
 	var secretVolumes []corev1.Volume
 	if r.kbsConfig.Spec.KbsSecretResources != nil {
 		for _, secretResource := range r.kbsConfig.Spec.KbsSecretResources {
 			r.log.Info("Retrieving KbsSecretResource", "Secret.Namespace", r.namespace, "Secret.Name", secretResource)
 			foundSecret := &corev1.Secret{}
-			err := r.Client.Get(ctx, client.ObjectKey{
-				Namespace: r.namespace,
-				Name:      secretResource,
-			}, foundSecret)
+			err := r.getReferencedObject(ctx, secretResource, foundSecret)
 			if err != nil {
 				return nil, err
 			}
 
-			secretVolumes = append(secretVolumes, corev1.Volume{
-				Name: secretResource,
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName: secretResource,
-					},
-				},
-			})
+			secretVolumes = append(secretVolumes, newSecretResourceVolume(secretResource))
 		}
 	}
+
+	discovered, err := r.discoverKbsSecretResources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, secretResource := range discovered {
+		secretVolumes = append(secretVolumes, newSecretResourceVolume(secretResource))
+	}
 	return secretVolumes, nil
 }
 
+func newSecretResourceVolume(secretName string) corev1.Volume {
+	return corev1.Volume{
+		Name: secretName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secretName,
+			},
+		},
+	}
+}
+
+// secretResourceMountVolumeName returns the name of the volume of the i-th KbsSecretResourceMounts entry
+func secretResourceMountVolumeName(i int) string {
+	return fmt.Sprintf("secret-resource-mount-%d", i)
+}
+
+// secretResourceMountSubPath returns the directory of the secret, relative to the secret resources mount path
+func secretResourceMountSubPath(mount confidentialcontainersorgv1alpha1.KbsSecretResourceMount) string {
+	if mount.SubPath != "" {
+		return mount.SubPath
+	}
+	return mount.SecretName
+}
+
+// createKbsSecretResourceMountsVolumes returns the volumes and the KBS volume mounts of KbsSecretResourceMounts
+func (r *KbsConfigReconciler) createKbsSecretResourceMountsVolumes(ctx context.Context) ([]corev1.Volume, []corev1.VolumeMount, error) {
+	var volumes []corev1.Volume
+	var volumeMounts []corev1.VolumeMount
+	for i, mount := range r.kbsConfig.Spec.KbsSecretResourceMounts {
+		r.log.Info("Retrieving KbsSecretResourceMounts", "Secret.Namespace", r.namespace, "Secret.Name", mount.SecretName)
+		foundSecret := &corev1.Secret{}
+		err := r.getReferencedObject(ctx, mount.SecretName, foundSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		volumeName := secretResourceMountVolumeName(i)
+		volumes = append(volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: mount.SecretName,
+					Items:      mount.Items,
+				},
+			},
+		})
+		mountPath := filepath.Join(r.kbsSecretResourcesMountPath(), secretResourceMountSubPath(mount))
+		volumeMounts = append(volumeMounts, createVolumeMount(volumeName, mountPath))
+	}
+	return volumes, volumeMounts, nil
+}
+
+// validateKbsSecretResourceMounts checks that the secrets are mounted in distinct relative directories,
+// not shadowing the KbsSecretResources ones, with relative item paths
+func (r *KbsConfigReconciler) validateKbsSecretResourceMounts() error {
+	subPaths := map[string]bool{}
+	for _, name := range r.kbsConfig.Spec.KbsSecretResources {
+		subPaths[name] = true
+	}
+	for _, mount := range r.kbsConfig.Spec.KbsSecretResourceMounts {
+		if mount.SecretName == "" {
+			return fmt.Errorf("KbsSecretResourceMounts: the secret name is required")
+		}
+		subPath := secretResourceMountSubPath(mount)
+		if !isRelativeSubPath(subPath) {
+			return fmt.Errorf("invalid KbsSecretResourceMounts sub-path %q: must be a clean relative path", subPath)
+		}
+		if subPaths[subPath] {
+			return fmt.Errorf("duplicated KbsSecretResourceMounts sub-path %q", subPath)
+		}
+		subPaths[subPath] = true
+		for _, item := range mount.Items {
+			if item.Key == "" || !isRelativeSubPath(item.Path) {
+				return fmt.Errorf("invalid KbsSecretResourceMounts item %q of the secret %s: the key and a clean relative path are required",
+					item.Key, mount.SecretName)
+			}
+		}
+	}
+	return nil
+}
+
+// mountsSecretResource returns true if the secret is listed in the KbsSecretResourceMounts of the KbsConfig
+func mountsSecretResource(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig, secretName string) bool {
+	for _, mount := range kbsConfig.Spec.KbsSecretResourceMounts {
+		if mount.SecretName == secretName {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelativeSubPath returns true if the path is a clean relative path, not escaping its parent directory
+func isRelativeSubPath(path string) bool {
+	return path != "" && !filepath.IsAbs(path) && filepath.Clean(path) == path &&
+		path != ".." && !strings.HasPrefix(path, "../")
+}
+
+// validateKbsSecretResourcesSelector checks that the selector is valid and selects a subset of the secrets
+func (r *KbsConfigReconciler) validateKbsSecretResourcesSelector() error {
+	selector := r.kbsConfig.Spec.KbsSecretResourcesSelector
+	if selector == nil {
+		return nil
+	}
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return fmt.Errorf("invalid KbsSecretResourcesSelector: %w", err)
+	}
+	if parsed.Empty() {
+		return fmt.Errorf("invalid KbsSecretResourcesSelector: an empty selector would match all the secrets of the namespace")
+	}
+	return nil
+}
+
+// selectsSecretResource returns true if the secret matches the KbsSecretResourcesSelector of the KbsConfig
+func selectsSecretResource(kbsConfig *confidentialcontainersorgv1alpha1.KbsConfig, secret *corev1.Secret) bool {
+	if kbsConfig.Spec.KbsSecretResourcesSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(kbsConfig.Spec.KbsSecretResourcesSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(secret.Labels))
+}
+
+// discoverKbsSecretResources returns the names of the secrets matching KbsSecretResourcesSelector,
+// sorted by name, which are not already listed in KbsSecretResources
+func (r *KbsConfigReconciler) discoverKbsSecretResources(ctx context.Context) ([]string, error) {
+	if r.kbsConfig.Spec.KbsSecretResourcesSelector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.kbsConfig.Spec.KbsSecretResourcesSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := &corev1.SecretList{}
+	err = r.Client.List(ctx, secrets, client.InNamespace(r.namespace), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		// the secrets created by the operator are never KBS secret resources
+		if owner := metav1.GetControllerOf(secret); owner != nil && owner.UID == r.kbsConfig.UID {
+			continue
+		}
+		if contains(r.kbsConfig.Spec.KbsSecretResources, secret.Name) {
+			continue
+		}
+		names = append(names, secret.Name)
+	}
+	sort.Strings(names)
+	r.log.Info("Discovered KbsSecretResources", "Secret.Namespace", r.namespace, "Secret.Names", names)
+	return names, nil
+}
+
 func (r *KbsConfigReconciler) createRvpsRefValuesConfigMapVolume(ctx context.Context, volumeName string) (*corev1.Volume, error) {
 	referenceValuesMapName := r.kbsConfig.Spec.KbsRvpsRefValuesConfigMapName
 	if referenceValuesMapName != "" {
 		r.log.Info("Retrieving KbsRvpsReferenceValuesMapName", "ConfigMap.Namespace", r.namespace,
 			"ConfigMap.Name", referenceValuesMapName)
 		foundConfigMap := &corev1.ConfigMap{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      referenceValuesMapName,
-		}, foundConfigMap)
+		err := r.getReferencedObject(ctx, referenceValuesMapName, foundConfigMap)
 		if err != nil {
 			return nil, err
 		}
 
+		schemaVersion := r.kbsConfig.Spec.KbsRvpsRefValuesSchemaVersion
+		if schemaVersion != "" {
+			err = validateReferenceValues(schemaVersion, foundConfigMap.Data[referenceValuesFileName])
+			if err != nil {
+				return nil, fmt.Errorf("ConfigMap %s: %w", referenceValuesMapName, err)
+			}
+		}
+
 		volume := corev1.Volume{
 			Name: volumeName,
 			VolumeSource: corev1.VolumeSource{
@@ -220,10 +478,16 @@ func (r *KbsConfigReconciler) createAsConfigMapVolume(ctx context.Context, volum
 		r.log.Info("Retrieving KbsAsConfigMapName", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name",
 			r.kbsConfig.Spec.KbsAsConfigMapName)
 		foundConfigMap := &corev1.ConfigMap{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsAsConfigMapName,
-		}, foundConfigMap)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsAsConfigMapName, foundConfigMap)
+		if err != nil {
+			return nil, err
+		}
+
+		overrides, err := r.asConfigOverrides()
+		if err != nil {
+			return nil, err
+		}
+		configMapName, err := r.renderConfigMap(ctx, foundConfigMap, volumeName, overrides)
 		if err != nil {
 			return nil, err
 		}
@@ -233,7 +497,7 @@ func (r *KbsConfigReconciler) createAsConfigMapVolume(ctx context.Context, volum
 			VolumeSource: corev1.VolumeSource{
 				ConfigMap: &corev1.ConfigMapVolumeSource{
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: r.kbsConfig.Spec.KbsAsConfigMapName,
+						Name: configMapName,
 					},
 				},
 			},
@@ -248,10 +512,7 @@ func (r *KbsConfigReconciler) processRvpsConfigMapVolume(ctx context.Context, vo
 		r.log.Info("Retrieving KbsRvpsConfigMapName", "ConfigMap.Namespace", r.namespace, "ConfigMap.Name",
 			r.kbsConfig.Spec.KbsRvpsConfigMapName)
 		foundConfigMap := &corev1.ConfigMap{}
-		err := r.Client.Get(ctx, client.ObjectKey{
-			Namespace: r.namespace,
-			Name:      r.kbsConfig.Spec.KbsRvpsConfigMapName,
-		}, foundConfigMap)
+		err := r.getReferencedObject(ctx, r.kbsConfig.Spec.KbsRvpsConfigMapName, foundConfigMap)
 		if err != nil {
 			return nil, err
 		}